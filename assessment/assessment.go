@@ -36,9 +36,11 @@ var netClient = &http.Client{
 //          construction does not need to be done multiple times.
 type Instance struct {
 	Props                 schematree.IList
+	Limit                 int // number of recommendations the caller needs from the final procedure, all if 0
 	tree                  *schematree.SchemaTree
 	useOptimisticCache    bool // using cache will make an optimistic assumption that `props` are not altered
 	cachedRecommendations schematree.PropertyRecommendations
+	cachedK               int // number of recommendations that were asked for in the cache, all if 0
 }
 
 // NewInstance : constructor method
@@ -70,15 +72,28 @@ func (inst *Instance) CalcPropertyLength() int {
 }
 
 // CalcRecommendations : Will execute the core schematree recommender on the properties and return
-// the list of recommendations. Cache-enabled operation.
+// the list of all recommendations, regardless of the Limit. Cache-enabled operation.
 func (inst *Instance) CalcRecommendations() schematree.PropertyRecommendations {
-	if inst.useOptimisticCache == true {
-		if inst.cachedRecommendations == nil {
-			inst.cachedRecommendations = inst.tree.RecommendProperty(inst.Props)
-		}
-		return inst.cachedRecommendations
+	return inst.CalcTopRecommendations(0)
+}
+
+// CalcTopRecommendations : Will execute the core schematree recommender on the properties and return
+// the top k recommendations, all if k is 0. Conditions ask for as many as they need to decide, the final
+// procedure for Limit many. Cache-enabled operation: a cached list with at least k recommendations, or
+// with all of them, is reused.
+func (inst *Instance) CalcTopRecommendations(k int) schematree.PropertyRecommendations {
+	if inst.useOptimisticCache == false {
+		return inst.tree.RecommendTopK(inst.Props, k)
+	}
+	complete := inst.cachedK == 0 || len(inst.cachedRecommendations) < inst.cachedK
+	if inst.cachedRecommendations == nil || !complete && (k == 0 || k > inst.cachedK) {
+		inst.cachedRecommendations = inst.tree.RecommendTopK(inst.Props, k)
+		inst.cachedK = k
+	}
+	if k > 0 && len(inst.cachedRecommendations) > k {
+		return inst.cachedRecommendations[:k]
 	}
-	return inst.tree.RecommendProperty(inst.Props)
+	return inst.cachedRecommendations
 }
 
 // GetWikiRecs computes recommendations from a local wikidata PropertySuggester
@@ -21,6 +21,8 @@ type Layer struct {
 	Splitter           string  // needed for splitintosubsets backoff everySecondItem, twoSupportRanges
 	Stepsize           string  // needed for deletelowfrequentitmes backoff stepsizeLinear, stepsizeProportional
 	ParallelExecutions int     // needed for deletelowfrequentitmes backoff
+	Estimator          string  // probability estimator of the standard backoff raw, laplace, shrinkage, interpolation
+	Smoothing          float64 // alpha or strength of the estimator, its default if 0
 }
 
 //Configuration defines one workflow configuration
@@ -60,7 +62,7 @@ func ConfigToWorkflow(config *Configuration, tree *schematree.SchemaTree) (wf *s
 			cond = strategy.MakeAlwaysCondition()
 		default:
 			cond = strategy.MakeAlwaysCondition()
-			err = errors.Errorf("Condition not found: " + l.Condition)
+			err = errors.Errorf("Condition not found: %v", l.Condition)
 		}
 
 		//switch the backoffs
@@ -76,7 +78,16 @@ func ConfigToWorkflow(config *Configuration, tree *schematree.SchemaTree) (wf *s
 				return
 			}
 		case "standard":
-			back = strategy.MakeAssessmentAwareDirectProcedure()
+			if l.Estimator == "" || l.Estimator == "raw" {
+				back = strategy.MakeAssessmentAwareDirectProcedure()
+				break
+			}
+			var estimator schematree.Estimator
+			estimator, err = schematree.ParseEstimator(l.Estimator, l.Smoothing)
+			if err != nil {
+				return
+			}
+			back = strategy.MakeEstimatorProcedure(tree, estimator)
 		case "splitProperty":
 			var merger backoff.MergerFunc
 			var splitter backoff.SplitterFunc
@@ -106,7 +117,7 @@ func ConfigToWorkflow(config *Configuration, tree *schematree.SchemaTree) (wf *s
 			cond = strategy.MakeTooFewRecommendationsCondition(l.Threshold)
 		default:
 			cond = strategy.MakeAlwaysCondition()
-			err = errors.Errorf("Backoff not found: " + l.Backoff)
+			err = errors.Errorf("Backoff not found: %v", l.Backoff)
 		}
 		//create the wf layer
 		workflow.Push(cond, back, fmt.Sprintf("layer %v", i))
@@ -133,6 +144,10 @@ func (conf *Configuration) Test() (err error) {
 		if lay.Backoff == "deleteLowFrequency" && (lay.Stepsize == "" || lay.ParallelExecutions == 0) {
 			err = errors.Errorf("Configuration File Failure: Layer %v needs Stepsize Function and #parallel executions", i)
 		}
+		if lay.Estimator != "" && lay.Backoff != "standard" {
+			err = errors.Errorf("Configuration File Failure: Layer %v has an estimator, which only the standard backoff uses", i)
+			return
+		}
 	}
 	return nil
 }
@@ -34,7 +34,7 @@ func createConfigFiles(creater *string) (err error) {
 
 	createrConfig, err := readCreaterConfig(creater)
 
-	fallbackLayer := configuration.Layer{"always", "standard", 0, 0.0, "", "", "", 0}
+	fallbackLayer := configuration.Layer{"always", "standard", 0, 0.0, "", "", "", 0, "", 0}
 	backoffLayers := make([]configuration.Layer, 0, 0)
 
 	// create a bunch of layers
@@ -46,11 +46,11 @@ func createConfigFiles(creater *string) (err error) {
 				for _, s := range createrConfig.Splitter {
 					if con == "tooUnlikelyRecommendationsCondition" {
 						fthresh := (float32(thresh) / float32(createrConfig.MaxThreshold)) * createrConfig.MaxFloat
-						l := configuration.Layer{con, "splitProperty", thresh, fthresh, m, s, "", 0}
+						l := configuration.Layer{con, "splitProperty", thresh, fthresh, m, s, "", 0, "", 0}
 						backoffLayers = append(backoffLayers, l)
 
 					} else {
-						l := configuration.Layer{con, "splitProperty", thresh, 0.0, m, s, "", 0}
+						l := configuration.Layer{con, "splitProperty", thresh, 0.0, m, s, "", 0, "", 0}
 						backoffLayers = append(backoffLayers, l)
 					}
 				}
@@ -60,11 +60,11 @@ func createConfigFiles(creater *string) (err error) {
 				for _, s := range createrConfig.Steps {
 					if con == "tooUnlikelyRecommendationsCondition" {
 						fthresh := (float32(thresh) / float32(createrConfig.MaxThreshold)) * createrConfig.MaxFloat
-						l := configuration.Layer{con, "deleteLowFrequency", thresh, fthresh, "", "", s, parallel}
+						l := configuration.Layer{con, "deleteLowFrequency", thresh, fthresh, "", "", s, parallel, "", 0}
 						backoffLayers = append(backoffLayers, l)
 
 					} else {
-						l := configuration.Layer{con, "deleteLowFrequency", thresh, 0.00, "", "", s, parallel}
+						l := configuration.Layer{con, "deleteLowFrequency", thresh, 0.00, "", "", s, parallel, "", 0}
 						backoffLayers = append(backoffLayers, l)
 					}
 				}
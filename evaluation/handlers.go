@@ -62,6 +62,40 @@ func HandlerTakeOneButType(
 	return results
 }
 
+// HandlerTakeOneType will call the evaluator once per type of the subject. Each time it leaves
+// that type out and keeps all other properties and types, so that the type recommender is
+// evaluated on how well it recovers a single missing type. Subjects without types or without
+// anything else are skipped.
+func HandlerTakeOneType(
+	s *schematree.SubjectSummary,
+	evaluator func(schematree.IList, schematree.IList) *evalResult,
+) []*evalResult {
+
+	results := make([]*evalResult, 0)
+	if len(s.Properties) < 2 {
+		return results
+	}
+
+	for leftout := range s.Properties {
+		if !leftout.IsType() {
+			continue
+		}
+		// The recommenders sort their input, so every evaluation gets its own reduced set.
+		reducedSet := make(schematree.IList, 0, len(s.Properties)-1)
+		for key := range s.Properties {
+			if key != leftout {
+				reducedSet = append(reducedSet, key)
+			}
+		}
+		newResult := evaluator(reducedSet, schematree.IList{leftout})
+		if newResult != nil {
+			newResult.note = s.Str + " " + *leftout.Str
+			results = append(results, newResult)
+		}
+	}
+	return results
+}
+
 // HandlerTakeAllButBest will select the reduced set by ordering all properties by their
 // "best" criteria { isType() < !isType() < SortOrder } and then pick the first NumBest.
 //
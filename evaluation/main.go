@@ -28,7 +28,7 @@ func main() {
 	createConfigsCreater := flag.String("creater", "", "Json which defines the creater config file in ./configs")
 	numberConfigs := flag.Int("numberConfigs", 1, "CNumber of config files in ./configs")
 	typedEntities := flag.Bool("typed", false, "Use type information or not")
-	handlerType := flag.String("handler", "takeOneButType", "Choose the handler: takeOneButType, takeAllButBest, takeMoreButCommon")
+	handlerType := flag.String("handler", "takeOneButType", "Choose the handler: takeOneButType, takeAllButBest, takeMoreButCommon, takeOneType (evaluates the type recommender)")
 	groupBy := flag.String("groupBy", "setSize", "Choose groupBy: setSize, numTypes, numLeftOut, numNonTypes")
 	writeResults := flag.Bool("results", false, "Turn on to write an additional JSON file with all evaluation results")
 	loadResults := flag.Bool("loadResults", false, "Turn on to read results back from JSON file instead of running the actual evaluation")
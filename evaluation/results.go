@@ -30,15 +30,15 @@ type evalResult struct {
 }
 
 // evaluatePair will generate an evalResult for a pair of ( reducedProps , leftoutProps ).
-// This function will take a list of reduced properties, run the recommender workflow with
-// those reduced properties, generate evaluation result entries by using the recently adquired
-// recommendations and the leftout properties.
+// This function will take a list of reduced properties, run the recommender (the workflow for
+// properties, the type recommender for types) with those reduced properties, generate evaluation
+// result entries by using the recently adquired recommendations and the leftout properties.
 // The aim is to evaluate how well the leftout properties appear in the recommendations that are
 // generated using the reduced set of properties (from where the properties have been left out).
 // Note that 'nil' can be returned.
 func evaluatePair(
 	tree *schematree.SchemaTree,
-	workflow *strategy.Workflow,
+	recommend func(schematree.IList) schematree.PropertyRecommendations,
 	reducedProps schematree.IList,
 	leftoutProps schematree.IList,
 ) *evalResult {
@@ -50,8 +50,7 @@ func evaluatePair(
 
 	// Run the recommender with the input properties.
 	start := time.Now()
-	asm := assessment.NewInstance(reducedProps, tree, true)
-	recs := workflow.Recommend(asm)
+	recs := recommend(reducedProps)
 	duration := time.Since(start).Nanoseconds()
 
 	// hack for wikiEvaluation
@@ -204,13 +203,23 @@ func evaluateDataset(
 		handler = handlerTakeButType
 	} else if handlerName == "historicTakeButType" { // original workings of take all but types
 		handler = buildHistoricHandlerTakeButType()
+	} else if handlerName == "takeOneType" { // take one type out, recommended back by the type recommender
+		handler = HandlerTakeOneType
 	} else {
 		panic("No suitable handler has been selected.")
 	}
 
+	// The properties are recommended by the workflow, the leave-type-out handlers evaluate the type recommender.
+	recommend := func(reduced schematree.IList) schematree.PropertyRecommendations {
+		return workflow.Recommend(assessment.NewInstance(reduced, tree, true))
+	}
+	if handlerName == "takeOneType" {
+		recommend = tree.RecommendType
+	}
+
 	// We also construct the method that will evaluate a pair of property sets.
 	evaluator := func(reduced schematree.IList, leftout schematree.IList) *evalResult {
-		return evaluatePair(tree, workflow, reduced, leftout)
+		return evaluatePair(tree, recommend, reduced, leftout)
 	}
 
 	// Build the complete callback function for the subject summary reader.
@@ -230,7 +239,7 @@ func evaluateDataset(
 
 	// Start the subject summary reader and collect all results into resultList, using the
 	// process that is managing the resultQueue.
-	schematree.SubjectSummaryReader(filePath, tree.PropMap, subjectCallback, 0, isTyped)
+	schematree.SubjectSummaryReader(filePath, tree.PropMap, tree.Config(), subjectCallback, 0, isTyped)
 	close(resultQueue)     // mark the end of results channel
 	resultWaitGroup.Wait() // wait until the parallel process that manages the queue is terminated
 
@@ -6,7 +6,7 @@ import (
 )
 
 func TestReadWriteConfigFile(t *testing.T) {
-	l1 := configuration.Layer{"tooFewRecommendation", "splitProperty", 100, 0.6, "avg", "everySecondItem", "", 0}
+	l1 := configuration.Layer{"tooFewRecommendation", "splitProperty", 100, 0.6, "avg", "everySecondItem", "", 0, "", 0}
 	cOut := configuration.Configuration{"../testdata/10M.nt_1in2_test.gz", []configuration.Layer{l1, l1}}
 	fileName := "./configs/test.json"
 	writeConfigFile(&cOut, fileName)
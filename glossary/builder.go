@@ -5,7 +5,6 @@ import (
 	"encoding/gob"
 	"fmt"
 	"os"
-	"recommender/io"
 	recIO "recommender/io"
 )
 
@@ -31,17 +30,17 @@ type GlossaryStats struct {
 // Glossary holds an entire glossary.
 type Glossary map[Key]*Content // glossary[property,language]
 
-// BuildGlossary from a dataset of N-Triples
+// BuildGlossary from a dataset in any of the formats supported by the io module
 // todo: Should this method receive the filepath, a filehandler, or a tripleparser?
 func BuildGlossary(filePath string) (*Glossary, GlossaryStats, error) {
 	stats := GlossaryStats{0, 0, 0, make(map[string]uint64)}
 
 	// Setup property types of the wikidata ontology
-	var wdLabelPredicate = []byte("<http://schema.org/name>")
-	var wdDescriptionPredicate = []byte("<http://schema.org/description>")
+	var wdLabelPredicate = []byte("http://schema.org/name")
+	var wdDescriptionPredicate = []byte("http://schema.org/description")
 
-	// Get a N-Triple parser for the input file.
-	tParser, err := recIO.NewTripleParser(filePath)
+	// Get a triple reader for the input file.
+	tParser, err := recIO.OpenTripleReader(filePath)
 	if err != nil {
 		return nil, stats, err
 	}
@@ -59,30 +58,30 @@ func BuildGlossary(filePath string) (*Glossary, GlossaryStats, error) {
 
 	// Go through each triple and add it to the glossary, while also creating entries
 	// on-the-fly if they don't exist.
-	for trip, err := tParser.NextTriple(); trip != nil && err == nil; trip, err = tParser.NextTriple() {
+	var trip *recIO.Triple
+	for trip, err = tParser.NextTriple(); trip != nil && err == nil; trip, err = tParser.NextTriple() {
 		// Get the predicate and make sure its either a label or description.
 		thisType := miscType
-		if bytes.Equal(trip.Predicate, wdLabelPredicate) {
+		if bytes.Equal(trip.Predicate.Value, wdLabelPredicate) {
 			thisType = labelType
-		} else if bytes.Equal(trip.Predicate, wdDescriptionPredicate) {
+		} else if bytes.Equal(trip.Predicate.Value, wdDescriptionPredicate) {
 			thisType = descriptionType
 		} else { // skip if type is not important
 			continue
 		}
 
 		// Get the text and language of the triple object.
-		text, lang := io.InterpreteLangLiteral(trip.Object)
+		text, lang := trip.Object.Value, trip.Object.Lang
 
-		if len(text) == 0 || len(lang) == 0 { // Only accept entries where both text and lang exist.
+		if !trip.Object.IsLiteral() || len(text) == 0 || len(lang) == 0 { // Only accept entries where both text and lang exist.
 			continue
 		}
 
 		// IRIREFs get stripped of their enclosing '< >' when they are stored.
-		// TODO: See if the entire system (schematree as well) works with or without enclosing tags.
-		iri := io.InterpreteIriRef(trip.Subject)
+		iri := trip.Subject.Identifier()
 
 		// Create the entry if it doesn't exist yet.
-		thisKey := &Key{string(iri), string(lang)}
+		thisKey := &Key{iri, string(lang)}
 		thisContent, thisContentOk := glos[*thisKey]
 		if !thisContentOk {
 			thisContent = &Content{}
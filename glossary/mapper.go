@@ -2,6 +2,7 @@ package glossary
 
 import (
 	"recommender/schematree"
+	"strings"
 )
 
 // LabeledRecommendation are recommendations with glossary information attached
@@ -17,16 +18,33 @@ func TranslateRecommendations(glossary *Glossary, language string, recommendatio
 	for i, candidate := range recommendations {
 
 		property := candidate.Property
-		content, ok := (*glossary)[Key{*property.Str, language}]
+		iri := *property.Str
+		if property.IsInverse() { // incoming relations are described by their property
+			iri = strings.TrimPrefix(iri, "^")
+		}
+		_, value, isValue := property.ValueOf()
+		if isValue { // property=value items are described by their value
+			iri = value
+		}
+		typeIRI, isType := property.TypeOf()
+		if isType { // types are described by their class
+			iri = typeIRI
+		}
+		content, ok := (*glossary)[Key{iri, language}]
 		if !ok { // no reference in given language -> try english
-			content, ok = (*glossary)[Key{*property.Str, "en"}]
+			content, ok = (*glossary)[Key{iri, "en"}]
 			if !ok { //no english reference -> use template
 				content = &Content{"", ""}
 			}
 		}
+		if property.IsInverse() && content.Label != "" {
+			content = &Content{"inverse of " + content.Label, content.Description}
+		}
 
 		// Whenever the label does not exist, use the actual property url
-		if content.Label == "" {
+		if content.Label == "" && (isValue || isType) {
+			content = &Content{iri, content.Description}
+		} else if content.Label == "" {
 			content.Label = *property.Str
 		}
 
@@ -0,0 +1,49 @@
+// Package parallel runs work concurrently for the packages of the recommender.
+package parallel
+
+import (
+	"runtime"
+	"sync"
+)
+
+// InOrder runs work for the indexes 0 to n-1 on up to workers goroutines (one per CPU if workers is not
+// positive) and calls emit for each index in ascending order, as soon as the work of the index and all
+// earlier ones is done. The work of at most a few indexes per worker runs ahead of emit, so results that wait
+// for emission stay bounded. emit is called from the calling goroutine.
+func InOrder(n, workers int, work func(i int), emit func(i int)) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	window := make(chan struct{}, 4*workers) // indexes that are started but not emitted yet
+	done := make([]chan struct{}, n)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				work(i)
+				close(done[i])
+			}
+		}()
+	}
+	go func() {
+		for i := 0; i < n; i++ {
+			window <- struct{}{}
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	for i := 0; i < n; i++ {
+		<-done[i]
+		emit(i)
+		<-window
+	}
+	wg.Wait()
+}
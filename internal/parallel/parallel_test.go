@@ -0,0 +1,34 @@
+package parallel
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInOrder(t *testing.T) {
+	t.Run("order", func(t *testing.T) {
+		var emitted []int
+		var running, maxRunning int32
+		InOrder(100, 4, func(i int) {
+			if r := atomic.AddInt32(&running, 1); r > atomic.LoadInt32(&maxRunning) {
+				atomic.StoreInt32(&maxRunning, r)
+			}
+			time.Sleep(time.Duration(100-i) * time.Microsecond) // later indexes finish first
+			atomic.AddInt32(&running, -1)
+		}, func(i int) {
+			emitted = append(emitted, i)
+		})
+		assert.Len(t, emitted, 100)
+		for i, e := range emitted {
+			assert.Equal(t, i, e)
+		}
+		assert.LessOrEqual(t, maxRunning, int32(4))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		InOrder(0, 0, func(i int) { t.Fail() }, func(i int) { t.Fail() })
+	})
+}
@@ -127,21 +127,19 @@ func CreateAndOpenWithGzip(filePath string) io.WriteCloser {
 }
 
 // TrimExtensions will remove the extension of a fileName if it resembles an extension used by compression
-// algorithms (.gz, .bz2, .gbz). Then it will remove the Data format extension (.nt) if it follows next.
+// algorithms (.gz, .bz2, .gbz). Then it will remove the Data format extension (.nt, .ttl, ...) if it follows next.
 func TrimExtensions(fileName string) (fileBase string) {
-	var base, ext string
-	base = fileName
-
 	// Try to remove a compression extension.
-	ext = filepath.Ext(base)
-	if ext == ".bz2" || ext == ".gz" || ext == ".gbz" {
-		base = strings.TrimSuffix(base, ext)
-	}
+	base := trimCompressionExtension(fileName)
 
 	// Try to remove a data format extension.
-	ext = filepath.Ext(base)
-	if ext == ".nt" {
-		base = strings.TrimSuffix(base, ext)
+	ext := strings.ToLower(filepath.Ext(base))
+	for _, entry := range formats {
+		for _, e := range entry.extensions {
+			if e == ext {
+				return strings.TrimSuffix(base, filepath.Ext(base))
+			}
+		}
 	}
 
 	return base
@@ -0,0 +1,144 @@
+package io
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TripleReader produces triples from an RDF serialization. At the end of the input a nil
+// triple and nil error are returned.
+//
+// The optional argument of NextTriple is a hint on how many terms are needed, see
+// TripleParser.NextTriple(). Readers of non line-based formats ignore it and always
+// deliver complete triples.
+type TripleReader interface {
+	NextTriple(argNumTokens ...int) (*Triple, error)
+	Close() error
+}
+
+// Format is the name of an RDF serialization that can be read.
+type Format string
+
+// Formats that are supported out of the box.
+const (
+	NTriples Format = "ntriples"
+	NQuads   Format = "nquads"
+	Turtle   Format = "turtle"
+	RDFXML   Format = "rdfxml"
+)
+
+// DefaultFormat is used when opening input files. The empty format detects the format from
+// the file extension and falls back to N-Triples.
+var DefaultFormat Format
+
+// DefaultGraphs restricts line-based quad formats to the listed graphs. Use DefaultGraph to
+// select the triples that have no graph label. A nil list accepts all graphs.
+var DefaultGraphs []string
+
+// DefaultGraph is the identifier of the default (unnamed) graph in graph filters.
+const DefaultGraph = "@default"
+
+// formatEntry describes how a format is recognized and read.
+type formatEntry struct {
+	extensions []string
+	open       func(reader io.ReadCloser) TripleReader
+}
+
+var formats = map[Format]formatEntry{}
+
+// RegisterFormat makes a serialization available to OpenTripleReader. Extensions are given
+// with their leading dot and are matched after compression extensions are removed.
+func RegisterFormat(name Format, extensions []string, open func(reader io.ReadCloser) TripleReader) {
+	formats[name] = formatEntry{extensions, open}
+}
+
+func init() {
+	RegisterFormat(NTriples, []string{".nt", ".ntriples"}, func(r io.ReadCloser) TripleReader {
+		return NewTripleParserFromReader(r)
+	})
+	RegisterFormat(NQuads, []string{".nq", ".nquads"}, func(r io.ReadCloser) TripleReader {
+		tp := NewTripleParserFromReader(r)
+		tp.Quads = true
+		tp.SetGraphs(DefaultGraphs)
+		return tp
+	})
+	RegisterFormat(Turtle, []string{".ttl", ".turtle"}, func(r io.ReadCloser) TripleReader {
+		return NewTurtleParserFromReader(r)
+	})
+	RegisterFormat(RDFXML, []string{".rdf", ".owl", ".xml"}, func(r io.ReadCloser) TripleReader {
+		return NewRDFXMLParserFromReader(r)
+	})
+}
+
+// ParseFormat validates the name of a format. The empty name is valid and means auto-detection.
+func ParseFormat(name string) (Format, error) {
+	f := Format(strings.ToLower(name))
+	if _, ok := formats[f]; ok || f == "" {
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown input format '%v', expected one of: %v", name, strings.Join(FormatNames(), ", "))
+}
+
+// FormatNames lists the names of all registered formats.
+func FormatNames() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FormatFromFileName detects the format by the extension of the file, ignoring compression
+// extensions. N-Triples is assumed if the extension is unknown.
+func FormatFromFileName(fileName string) Format {
+	ext := strings.ToLower(filepath.Ext(trimCompressionExtension(fileName)))
+	for name, entry := range formats {
+		for _, e := range entry.extensions {
+			if e == ext {
+				return name
+			}
+		}
+	}
+	return NTriples
+}
+
+// OpenTripleReader opens a file with the reader of DefaultFormat, or with the reader detected
+// from the file extension if no default is set. If GroupUnsorted is set, the triples are returned
+// grouped by subject.
+func OpenTripleReader(filePath string) (TripleReader, error) {
+	if GroupUnsorted {
+		return openGrouped(filePath, false)
+	}
+	return openFormat(filePath)
+}
+
+// openFormat opens a file with the reader of its format.
+func openFormat(filePath string) (TripleReader, error) {
+	format := DefaultFormat
+	if format == "" {
+		format = FormatFromFileName(filePath)
+	}
+	entry, ok := formats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown input format '%v'", format)
+	}
+
+	reader, err := UniversalReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return entry.open(reader), nil
+}
+
+// trimCompressionExtension removes the extension used by compression algorithms, if any.
+func trimCompressionExtension(fileName string) string {
+	ext := filepath.Ext(fileName)
+	if ext == ".bz2" || ext == ".gz" || ext == ".bgz" || ext == ".gbz" {
+		return strings.TrimSuffix(fileName, ext)
+	}
+	return fileName
+}
@@ -0,0 +1,182 @@
+package io
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readAll collects the N-Triples serialization of all triples of a reader.
+func readAll(t *testing.T, reader TripleReader) []string {
+	defer reader.Close()
+	var lines []string
+	for {
+		trip, err := reader.NextTriple()
+		if !assert.NoError(t, err) || trip == nil {
+			return lines
+		}
+		lines = append(lines, string(trip.Line))
+	}
+}
+
+func stream(s string) io.ReadCloser {
+	return ioutil.NopCloser(strings.NewReader(s))
+}
+
+func TestFormats(t *testing.T) {
+	t.Run("detection", func(t *testing.T) {
+		assert.Equal(t, NTriples, FormatFromFileName("data.nt.gz"))
+		assert.Equal(t, NQuads, FormatFromFileName("data.nq.bz2"))
+		assert.Equal(t, Turtle, FormatFromFileName("data.TTL"))
+		assert.Equal(t, RDFXML, FormatFromFileName("ontology.owl"))
+		assert.Equal(t, NTriples, FormatFromFileName("data.unknown"))
+		assert.Equal(t, "data", TrimExtensions("data.ttl.gz"))
+	})
+
+	t.Run("parse format", func(t *testing.T) {
+		f, err := ParseFormat("Turtle")
+		assert.NoError(t, err)
+		assert.Equal(t, Turtle, f)
+		_, err = ParseFormat("json")
+		assert.Error(t, err)
+	})
+}
+
+func TestNQuads(t *testing.T) {
+	input := strings.Join([]string{
+		`<http://ex.org/a> <http://ex.org/p> "x" <http://ex.org/g1> .`,
+		`<http://ex.org/a> <http://ex.org/q> "y" .`,
+		`<http://ex.org/b> <http://ex.org/p> "z" _:g2 .`,
+	}, "\n")
+
+	t.Run("all graphs", func(t *testing.T) {
+		tp := NewTripleParserFromReader(stream(input))
+		tp.Quads = true
+		trip, err := tp.NextTriple()
+		assert.NoError(t, err)
+		assert.Equal(t, "x", string(trip.Object.Value))
+		assert.Equal(t, "http://ex.org/g1", trip.Graph.Identifier())
+		assert.Len(t, readAll(t, tp), 2)
+	})
+
+	t.Run("graph filter", func(t *testing.T) {
+		tp := NewTripleParserFromReader(stream(input))
+		tp.Quads = true
+		tp.SetGraphs([]string{DefaultGraph, "_:g2"})
+		lines := readAll(t, tp)
+		assert.Equal(t, []string{
+			`<http://ex.org/a> <http://ex.org/q> "y" .`,
+			`<http://ex.org/b> <http://ex.org/p> "z" _:g2 .`,
+		}, lines)
+	})
+}
+
+func TestTurtle(t *testing.T) {
+	t.Run("abbreviations", func(t *testing.T) {
+		input := `
+@prefix ex: <http://ex.org/> .
+PREFIX schema: <http://schema.org/>
+@base <http://base.org/dir/> .
+
+# comment
+ex:alice a schema:Person ;
+	schema:name "Alice"@en, 'Alicia'@es ;
+	schema:age 42 ;
+	schema:height 1.68 ;
+	ex:rel <bob>, ex:carol.
+ex:bob schema:knows [ schema:name """multi
+line""" ] ; ex:list ( 1 true ) .
+`
+		lines := readAll(t, NewTurtleParserFromReader(stream(input)))
+		assert.Equal(t, []string{
+			`<http://ex.org/alice> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://schema.org/Person> .`,
+			`<http://ex.org/alice> <http://schema.org/name> "Alice"@en .`,
+			`<http://ex.org/alice> <http://schema.org/name> "Alicia"@es .`,
+			`<http://ex.org/alice> <http://schema.org/age> "42"^^<http://www.w3.org/2001/XMLSchema#integer> .`,
+			`<http://ex.org/alice> <http://schema.org/height> "1.68"^^<http://www.w3.org/2001/XMLSchema#decimal> .`,
+			`<http://ex.org/alice> <http://ex.org/rel> <http://base.org/dir/bob> .`,
+			`<http://ex.org/alice> <http://ex.org/rel> <http://ex.org/carol> .`,
+			`<http://ex.org/bob> <http://schema.org/knows> _:genid1 .`,
+			`<http://ex.org/bob> <http://ex.org/list> _:genid2 .`,
+			`_:genid1 <http://schema.org/name> "multi\nline" .`,
+			`_:genid2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "1"^^<http://www.w3.org/2001/XMLSchema#integer> .`,
+			`_:genid2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> _:genid3 .`,
+			`_:genid3 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "true"^^<http://www.w3.org/2001/XMLSchema#boolean> .`,
+			`_:genid3 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> <http://www.w3.org/1999/02/22-rdf-syntax-ns#nil> .`,
+		}, lines)
+	})
+
+	t.Run("terms", func(t *testing.T) {
+		tp := NewTurtleParserFromReader(stream(`@prefix ex: <http://ex.org/> . ex:s\.x ex:p "a \"q\""^^ex:dt .`))
+		trip, err := tp.NextTriple()
+		assert.NoError(t, err)
+		assert.Equal(t, "http://ex.org/s.x", string(trip.Subject.Value))
+		assert.Equal(t, `a "q"`, string(trip.Object.Value))
+		assert.Equal(t, "http://ex.org/dt", string(trip.Object.Datatype))
+	})
+
+	input := strings.Join([]string{
+		`@prefix ex: <http://ex.org/> .`,
+		`ex:a ex:p "one" .`,
+		`ex:b ex:p unknown:x .`,
+		`ex:c ex:p "three" .`,
+	}, "\n")
+
+	t.Run("lenient", func(t *testing.T) {
+		tp := NewTurtleParserFromReader(stream(input))
+		tp.Mode = Lenient
+		lines := readAll(t, tp)
+		assert.Len(t, lines, 2)
+		assert.EqualValues(t, 1, tp.SkippedStatements())
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		tp := NewTurtleParserFromReader(stream(input))
+		tp.Mode = Strict
+		defer tp.Close()
+		_, err := tp.NextTriple()
+		assert.NoError(t, err)
+		_, err = tp.NextTriple()
+		if assert.Error(t, err) {
+			assert.EqualValues(t, 3, err.(*ParseError).Line)
+		}
+	})
+}
+
+func TestRDFXML(t *testing.T) {
+	input := `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+         xmlns:ex="http://ex.org/" xml:base="http://ex.org/" xml:lang="en">
+  <ex:Person rdf:about="alice" ex:nick="Ali">
+    <ex:name>Alice</ex:name>
+    <ex:age rdf:datatype="http://www.w3.org/2001/XMLSchema#integer">42</ex:age>
+    <ex:knows rdf:resource="#bob"/>
+    <ex:address rdf:parseType="Resource">
+      <ex:city xml:lang="de">München</ex:city>
+    </ex:address>
+    <ex:friend>
+      <rdf:Description rdf:nodeID="carol"><ex:name>Carol</ex:name></rdf:Description>
+    </ex:friend>
+  </ex:Person>
+  <rdf:Description rdf:about="http://ex.org/bob">
+    <rdf:type rdf:resource="http://ex.org/Person"/>
+  </rdf:Description>
+</rdf:RDF>`
+
+	lines := readAll(t, NewRDFXMLParserFromReader(stream(input)))
+	assert.Equal(t, []string{
+		`<http://ex.org/alice> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/Person> .`,
+		`<http://ex.org/alice> <http://ex.org/nick> "Ali"@en .`,
+		`<http://ex.org/alice> <http://ex.org/name> "Alice"@en .`,
+		`<http://ex.org/alice> <http://ex.org/age> "42"^^<http://www.w3.org/2001/XMLSchema#integer> .`,
+		`<http://ex.org/alice> <http://ex.org/knows> <http://ex.org/#bob> .`,
+		`<http://ex.org/alice> <http://ex.org/address> _:genid1 .`,
+		`<http://ex.org/alice> <http://ex.org/friend> _:carol .`,
+		`_:genid1 <http://ex.org/city> "München"@de .`,
+		`_:carol <http://ex.org/name> "Carol"@en .`,
+		`<http://ex.org/bob> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/Person> .`,
+	}, lines)
+}
@@ -0,0 +1,427 @@
+package io
+
+// External grouping of triples by subject for input files that are not sorted.
+//
+// The input is split into partitions by a hash of the subject, which are written to temporary files.
+// Each partition is then grouped in memory and appended to a single grouped N-Triples file. Partitions
+// that are larger than GroupMemory are split again with another hash before they are grouped, so the
+// memory needed is bounded by GroupMemory instead of the size of the dataset.
+//
+// The grouping can also add the inverse of every triple with an IRI object to the group of its object.
+// Inverse triples are written with a leading `^`, which is not valid N-Triples and only read by the
+// TripleParser with InverseMarkers set.
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GroupUnsorted makes OpenTripleReader group the triples of the input by subject before they are
+// returned. The grouped file is kept for further reads of the same input until RemoveGroupedFiles is called.
+var GroupUnsorted bool
+
+// GroupPartitions is the number of partitions used by the external grouping. Only one partition is
+// held in memory at a time, partitions above GroupMemory are split further.
+var GroupPartitions = 64
+
+// GroupMemory is the size of the N-Triples lines of a partition, in bytes, up to which the partition is
+// grouped in memory. Grouping takes about twice as much memory as the lines.
+var GroupMemory int64 = 1 << 30
+
+// maxSplits is the number of times an oversized partition is split again. Partitions that are still too large
+// afterwards consist of a few subjects with a lot of triples, which cannot be split by subject anyway.
+const maxSplits = 3
+
+// TempDir is the directory where temporary files are created. The empty string uses the default
+// directory of the operating system.
+var TempDir string
+
+// inverseMarker starts the lines of inverse triples in the files of the grouping.
+const inverseMarker = '^'
+
+var groupedFiles = struct {
+	sync.Mutex
+	paths map[string]groupedFile // maps input files to their grouped versions
+	dirs  []string               // directories that could not be removed right away
+}{paths: map[string]groupedFile{}}
+
+// groupedFile is the grouped version of an input file. It is read in the parse mode of the input, as the
+// partitions are written from the terms as they were accepted. The file is kept open and is removed from
+// the disk as soon as it is written where the system allows it, so it does not outlive the process even
+// if the process exits without calling RemoveGroupedFiles.
+type groupedFile struct {
+	file *os.File
+	size int64
+	mode ParseMode
+}
+
+// OpenTripleReaderWithInverses returns the triples of a file grouped by subject. In addition, every triple
+// whose object is an IRI is returned a second time in the group of its object, with subject and object swapped
+// and Inverse set. Objects that are not the subject of any triple of the input get no group, since they are
+// not described by the dataset. The input is always grouped on disk, see GroupUnsorted.
+func OpenTripleReaderWithInverses(filePath string) (TripleReader, error) {
+	return openGrouped(filePath, true)
+}
+
+// openGrouped returns a reader for the grouped version of the input file, creating it if necessary.
+func openGrouped(filePath string, inverse bool) (TripleReader, error) {
+	groupedFiles.Lock()
+	defer groupedFiles.Unlock()
+
+	key := filePath
+	if inverse {
+		key += "\x00inverse"
+	}
+	grouped, ok := groupedFiles.paths[key]
+	if !ok {
+		var err error
+		if grouped, err = createGrouped(filePath, inverse); err != nil {
+			return nil, err
+		}
+		groupedFiles.paths[key] = grouped
+	}
+
+	// every reader has its own section of the shared file, so the readers do not move each other's offset
+	gz, err := gzip.NewReader(bufio.NewReaderSize(io.NewSectionReader(grouped.file, 0, grouped.size), 1024*1024))
+	if err != nil {
+		return nil, err
+	}
+	tp := NewTripleParserFromReader(gz)
+	tp.Mode = grouped.mode
+	tp.InverseMarkers = true
+	return tp, nil
+}
+
+// createGrouped groups the input file in a new temporary directory, which is removed again.
+func createGrouped(filePath string, inverse bool) (groupedFile, error) {
+	dir, err := os.MkdirTemp(TempDir, "recommender-grouping-")
+	if err != nil {
+		return groupedFile{}, err
+	}
+	reader, err := openFormat(filePath)
+	if err != nil {
+		os.RemoveAll(dir)
+		return groupedFile{}, err
+	}
+	path := filepath.Join(dir, "grouped.nt.gz")
+	err = group(reader, path, dir, GroupPartitions, inverse)
+	mode := parseModeOf(reader)
+	reader.Close()
+	if err != nil {
+		os.RemoveAll(dir)
+		return groupedFile{}, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		return groupedFile{}, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		os.RemoveAll(dir)
+		return groupedFile{}, err
+	}
+	if os.RemoveAll(dir) != nil {
+		groupedFiles.dirs = append(groupedFiles.dirs, dir) // open files cannot be removed on some systems
+	}
+	return groupedFile{file, info.Size(), mode}, nil
+}
+
+// RemoveGroupedFiles closes and deletes all temporary files that have been created by the external grouping.
+func RemoveGroupedFiles() {
+	groupedFiles.Lock()
+	defer groupedFiles.Unlock()
+	for _, grouped := range groupedFiles.paths {
+		grouped.file.Close()
+	}
+	for _, dir := range groupedFiles.dirs {
+		os.RemoveAll(dir)
+	}
+	groupedFiles.dirs = nil
+	groupedFiles.paths = map[string]groupedFile{}
+}
+
+// GroupBySubject reads all triples and writes them to outPath as gzipped N-Triples in which all triples of
+// a subject are adjacent. Temporary partition files are created in tempDir and removed again.
+// Graph labels of quads are dropped; the order of the triples of a subject is kept.
+func GroupBySubject(reader TripleReader, outPath string, tempDir string, partitions int) error {
+	return group(reader, outPath, tempDir, partitions, false)
+}
+
+// GroupWithInverses works like GroupBySubject, but also writes the inverse of every triple with an IRI object
+// into the group of the object, see OpenTripleReaderWithInverses. The output has to be read by a TripleParser
+// with InverseMarkers set.
+func GroupWithInverses(reader TripleReader, outPath string, tempDir string, partitions int) error {
+	return group(reader, outPath, tempDir, partitions, true)
+}
+
+func group(reader TripleReader, outPath string, tempDir string, partitions int, inverse bool) error {
+	if partitions < 1 {
+		partitions = 1
+	}
+	t1 := time.Now()
+
+	// partitioning
+	parts := make([]*partitionFile, partitions)
+	for i := range parts {
+		p, err := createPartition(filepath.Join(tempDir, fmt.Sprintf("partition-%04d.nt.gz", i)))
+		if err != nil {
+			return err
+		}
+		defer os.Remove(p.path)
+		defer p.file.Close()
+		parts[i] = p
+	}
+
+	var count uint64
+	hash := fnv.New64a()
+	partition := func(subject []byte) *partitionFile {
+		hash.Reset()
+		hash.Write(subject)
+		return parts[hash.Sum64()%uint64(partitions)]
+	}
+	for {
+		trip, err := reader.NextTriple()
+		if err != nil {
+			return err
+		}
+		if trip == nil {
+			break
+		}
+		if err := partition(trip.Subject.Raw).write(trip); err != nil {
+			return err
+		}
+		if inverse && trip.Object.IsIRI() {
+			if err := partition(trip.Object.Raw).writeInverse(trip); err != nil {
+				return err
+			}
+		}
+		count++
+	}
+	for _, p := range parts {
+		if err := p.close(); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Partitioned %v triples into %v files for grouping (%v)\n", count, partitions, time.Since(t1))
+
+	// grouping of each partition
+	out, err := createPartition(outPath)
+	if err != nil {
+		return err
+	}
+	defer out.file.Close()
+	for _, p := range parts {
+		if err := p.groupInto(out, 0); err != nil {
+			return err
+		}
+	}
+	if err := out.close(); err != nil {
+		return err
+	}
+	fmt.Printf("Grouped triples by subject (%v)\n", time.Since(t1))
+	return nil
+}
+
+// partitionFile is a gzipped N-Triples file that is written sequentially.
+type partitionFile struct {
+	path   string
+	file   *os.File
+	buffer *bufio.Writer
+	gz     *gzip.Writer
+	size   int64 // bytes of the uncompressed lines
+}
+
+func createPartition(path string) (*partitionFile, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	buffer := bufio.NewWriterSize(file, 256*1024)
+	gz, _ := gzip.NewWriterLevel(buffer, gzip.BestSpeed) // the level is valid, so there is no error
+	return &partitionFile{path: path, file: file, buffer: buffer, gz: gz}, nil
+}
+
+// write appends the triple without its graph label.
+func (p *partitionFile) write(trip *Triple) error {
+	return p.writeLine(nil, trip.Subject.Raw, trip.Predicate.Raw, trip.Object.Raw)
+}
+
+// writeInverse appends the inverse of the triple, with subject and object swapped.
+func (p *partitionFile) writeInverse(trip *Triple) error {
+	return p.writeLine([]byte{inverseMarker}, trip.Object.Raw, trip.Predicate.Raw, trip.Subject.Raw)
+}
+
+func (p *partitionFile) writeLine(marker, subject, predicate, object []byte) error {
+	for _, term := range [][]byte{marker, subject, {' '}, predicate, {' '}, object, []byte(" .\n")} {
+		if _, err := p.gz.Write(term); err != nil {
+			return err
+		}
+		p.size += int64(len(term))
+	}
+	return nil
+}
+
+// writeRaw appends a line that has been written to another partition before.
+func (p *partitionFile) writeRaw(line []byte) error {
+	p.size += int64(len(line))
+	_, err := p.gz.Write(line)
+	return err
+}
+
+func (p *partitionFile) close() error {
+	if err := p.gz.Close(); err != nil {
+		return err
+	}
+	if err := p.buffer.Flush(); err != nil {
+		return err
+	}
+	return p.file.Close()
+}
+
+// groupInto writes the lines of the written and closed partition grouped by subject and removes it. The
+// lines are kept as they are, without parsing them again. Partitions above GroupMemory are split into
+// smaller ones first, with a hash that depends on the number of splits before.
+func (p *partitionFile) groupInto(out *partitionFile, splits int) error {
+	defer os.Remove(p.path)
+	if p.size > GroupMemory && splits < maxSplits {
+		return p.split(out, splits+1)
+	}
+
+	order := make(map[string]int)
+	var buckets [][]byte            // lines of every subject
+	described := make(map[int]bool) // buckets with at least one triple that is not inverse
+	err := p.eachLine(func(line []byte) error {
+		subject := subjectOf(line)
+		idx, ok := order[string(subject)]
+		if !ok {
+			idx = len(buckets)
+			order[string(subject)] = idx
+			buckets = append(buckets, nil)
+		}
+		buckets[idx] = append(buckets[idx], line...)
+		if line[0] != inverseMarker {
+			described[idx] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for idx, bucket := range buckets {
+		if !described[idx] {
+			continue // only the object of other triples, the subject is not part of the dataset
+		}
+		if _, err := out.gz.Write(bucket); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// split distributes the lines of the partition over enough partitions to bring them below GroupMemory and
+// groups these into out.
+func (p *partitionFile) split(out *partitionFile, splits int) error {
+	count := int(p.size/GroupMemory) + 1
+	parts := make([]*partitionFile, count)
+	base := strings.TrimSuffix(p.path, ".nt.gz")
+	for i := range parts {
+		part, err := createPartition(fmt.Sprintf("%v-%04d.nt.gz", base, i))
+		if err != nil {
+			return err
+		}
+		defer os.Remove(part.path)
+		defer part.file.Close()
+		parts[i] = part
+	}
+
+	hash := fnv.New64a()
+	err := p.eachLine(func(line []byte) error {
+		hash.Reset()
+		hash.Write([]byte{byte(splits)})
+		hash.Write(subjectOf(line))
+		// the low bits of the hash chose the partition before, the multiplication moves all bits to the high ones
+		spread := (hash.Sum64() * 0x9e3779b97f4a7c15) >> 32
+		return parts[spread%uint64(count)].writeRaw(line)
+	})
+	if err != nil {
+		return err
+	}
+	for _, part := range parts {
+		if err := part.close(); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Split a partition of %v MiB for grouping into %v files\n", p.size>>20, count)
+	for _, part := range parts {
+		if err := part.groupInto(out, splits); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eachLine calls visit for every line of the written and closed partition. The lines keep their line break.
+func (p *partitionFile) eachLine(visit func(line []byte) error) error {
+	file, err := os.Open(p.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(bufio.NewReaderSize(file, 256*1024))
+	if err != nil {
+		return err
+	}
+	lines := bufio.NewReaderSize(gz, 256*1024)
+	for {
+		line, err := lines.ReadBytes('\n')
+		if len(line) > 0 {
+			if err := visit(line); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// subjectOf returns the subject of a line that has been written by writeLine, which ends at the first space
+// after the inverse marker, or at the end of the IRI for IRIs, as lenient input may have spaces in IRIs.
+func subjectOf(line []byte) []byte {
+	if line[0] == inverseMarker {
+		line = line[1:]
+	}
+	end := bytes.IndexByte(line, ' ')
+	if line[0] == '<' {
+		end = bytes.IndexByte(line, '>') + 1
+	}
+	if end <= 0 {
+		return line
+	}
+	return line[:end]
+}
+
+// parseModeOf returns the mode in which the written terms of the input can be read again: the mode of an
+// N-Triples parser, Lenient for other readers, since e.g. Turtle keeps relative IRIs if no base is given.
+func parseModeOf(reader TripleReader) ParseMode {
+	if tp, ok := reader.(*TripleParser); ok {
+		return tp.Mode
+	}
+	return Lenient
+}
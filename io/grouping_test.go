@@ -0,0 +1,127 @@
+package io
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupBySubject(t *testing.T) {
+	input := strings.Join([]string{
+		`<http://ex.org/a> <http://ex.org/p> "1" .`,
+		`<http://ex.org/b> <http://ex.org/p> "2" .`,
+		`<http://ex.org/a> <http://ex.org/q> "3" <http://ex.org/g> .`,
+		`_:c <http://ex.org/p> "4" .`,
+		`<http://ex.org/b> <http://ex.org/q> "5" .`,
+		`<http://ex.org/a> <http://ex.org/r> "6" .`,
+	}, "\n")
+
+	defer func(memory int64) { GroupMemory = memory }(GroupMemory)
+	for _, memory := range []int64{GroupMemory, 60} { // 60 bytes split every partition again
+		GroupMemory = memory
+		for _, partitions := range []int{1, 3} {
+			checkGrouping(t, input, partitions)
+		}
+	}
+}
+
+// checkGrouping groups the input with the given number of partitions and checks the grouped file.
+func checkGrouping(t *testing.T, input string, partitions int) {
+	dir := t.TempDir()
+	reader := NewTripleParserFromReader(stream(input))
+	reader.Quads = true
+	outPath := filepath.Join(dir, "grouped.nt.gz")
+	assert.NoError(t, GroupBySubject(reader, outPath, dir, partitions))
+
+	tp, err := NewTripleParser(outPath)
+	assert.NoError(t, err)
+	lines := readAll(t, tp)
+	assert.Len(t, lines, 6)
+
+	// every subject forms one block and keeps the order of its triples
+	var subjects []string
+	for _, line := range lines {
+		subject := strings.Fields(line)[0]
+		if len(subjects) == 0 || subjects[len(subjects)-1] != subject {
+			assert.NotContains(t, subjects, subject)
+			subjects = append(subjects, subject)
+		}
+	}
+	assert.Len(t, subjects, 3)
+	assert.Contains(t, lines, `<http://ex.org/a> <http://ex.org/q> "3" .`)
+
+	// only the grouped file is left
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1)
+}
+
+func TestGroupWithInverses(t *testing.T) {
+	input := strings.Join([]string{
+		`<http://ex.org/a> <http://ex.org/p> <http://ex.org/b> .`,
+		`<http://ex.org/b> <http://ex.org/q> "1" .`,
+		`<http://ex.org/c> <http://ex.org/p> <http://ex.org/a> .`,
+		`<http://ex.org/a> <http://ex.org/r> <http://ex.org/undescribed> .`,
+	}, "\n")
+
+	dir := t.TempDir()
+	outPath := filepath.Join(dir, "grouped.nt.gz")
+	assert.NoError(t, GroupWithInverses(NewTripleParserFromReader(stream(input)), outPath, dir, 2))
+
+	tp, err := NewTripleParser(outPath)
+	assert.NoError(t, err)
+	tp.InverseMarkers = true
+	inverses := map[string][]string{}
+	var count int
+	for trip, err := tp.NextTriple(); trip != nil; trip, err = tp.NextTriple() {
+		assert.NoError(t, err)
+		count++
+		if trip.Inverse {
+			subject := trip.Subject.Identifier()
+			inverses[subject] = append(inverses[subject], trip.Predicate.Identifier()+" "+trip.Object.Identifier())
+		}
+	}
+
+	// the inverse triples are part of the groups of their objects, undescribed objects are left out
+	assert.Equal(t, 6, count)
+	assert.Equal(t, map[string][]string{
+		"http://ex.org/a": {"http://ex.org/p http://ex.org/c"},
+		"http://ex.org/b": {"http://ex.org/p http://ex.org/a"},
+	}, inverses)
+}
+
+func TestGroupLenientInput(t *testing.T) {
+	input := strings.Join([]string{
+		`<http://ex.org/a> <http://ex.org/p> "1" .`,
+		`<http://ex.org/b> <http://ex.org/p> <relative> .`,
+		`<http://ex.org/a> <http://ex.org/q> <http://ex.org/o>`,
+	}, "\n")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "input.nt")
+	assert.NoError(t, os.WriteFile(path, []byte(input), 0644))
+
+	GroupUnsorted = true
+	TempDir = t.TempDir()
+	defer func() {
+		GroupUnsorted = false
+		TempDir = ""
+		RemoveGroupedFiles()
+	}()
+
+	for _, open := range []func(string) (TripleReader, error){OpenTripleReader, OpenTripleReaderWithInverses} {
+		reader, err := open(path)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		// the lines that the lenient parser accepts are grouped and read again without errors
+		lines := readAll(t, reader)
+		assert.Len(t, lines, 3)
+		assert.Contains(t, lines, `<http://ex.org/b> <http://ex.org/p> <relative> .`)
+
+		// the grouped file is only kept open, nothing is left on the disk
+		entries, _ := os.ReadDir(TempDir)
+		assert.Empty(t, entries)
+	}
+}
@@ -4,24 +4,98 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"unicode"
-	"unicode/utf8"
 )
 
 // Triple represents an RDF entry in the N-Triple file.
-// TODO: Check if having []byte does not make problems with the buffer they are pointing to. What
-//       happens to the triple if the buffer flushes?
-type Triple struct { // TODO: Maybe I can use string instead of byte[]
-	Subject   []byte
-	Predicate []byte
-	Object    []byte
+// The terms point into Line, which is owned by the triple and stays valid after further reads.
+// Readers of formats that are not line-based fill Line with the N-Triples serialization.
+type Triple struct {
+	Subject   Term
+	Predicate Term
+	Object    Term
+	Graph     Term   // graph label of a quad, empty for the default graph
 	Line      []byte // Holds the entire line including terminating dot (but no newline)
+	Inverse   bool   // the subject and object of an input triple are swapped, see OpenTripleReaderWithInverses
 }
 
+// ParseTriple parses a single line in N-Triples syntax. Only the first `numTerms` terms are parsed,
+// the remaining terms are left empty. Blank lines and comments result in a nil triple and nil error.
+// Errors are always of type *ParseError and carry no line number.
+func ParseTriple(line []byte, numTerms int, mode ParseMode) (*Triple, error) {
+	return parseStatement(line, numTerms, mode, false)
+}
+
+// ParseQuad parses a single line in N-Quads syntax, which is a triple optionally followed by a graph label.
+// Quads are always parsed completely. Errors behave like those of ParseTriple.
+func ParseQuad(line []byte, mode ParseMode) (*Triple, error) {
+	return parseStatement(line, 3, mode, true)
+}
+
+func parseStatement(line []byte, numTerms int, mode ParseMode, quad bool) (*Triple, error) {
+	l := lexer{data: line, strict: mode == Strict}
+
+	// skip if line is empty or a comment
+	l.skipSpace()
+	if l.eof() || l.skipComment() {
+		return nil, nil
+	}
+
+	trip := &Triple{Line: line}
+	if numTerms == 0 {
+		return trip, nil
+	}
+
+	var err error
+	if trip.Subject, err = l.parseSubject(); err != nil {
+		return nil, err
+	}
+	if numTerms == 1 {
+		return trip, nil
+	}
+
+	l.skipSpace()
+	if trip.Predicate, err = l.parsePredicate(); err != nil {
+		return nil, err
+	}
+	if numTerms == 2 {
+		return trip, nil
+	}
+
+	l.skipSpace()
+	if trip.Object, err = l.parseObject(); err != nil {
+		return nil, err
+	}
+	if quad {
+		l.skipSpace()
+		if !l.eof() && (l.data[l.pos] == '<' || l.data[l.pos] == '_') {
+			if trip.Graph, err = l.parseSubject(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err = l.parseEnd(); err != nil {
+		return nil, err
+	}
+	return trip, nil
+}
+
+// maxLenientWarnings limits how many skipped lines are reported individually in lenient mode.
+const maxLenientWarnings = 100
+
 // TripleParser reads an internal file and produces triples from it.
 type TripleParser struct {
+	Mode  ParseMode // how malformed lines are treated, defaults to DefaultParseMode
+	Quads bool      // lines are N-Quads instead of N-Triples
+
+	// InverseMarkers makes lines that start with `^` inverse triples. Only used for the files of the grouping.
+	InverseMarkers bool
+
+	graphs map[string]bool // accepted graphs of quads, nil accepts all
+
 	reader  io.ReadCloser
 	scanner *bufio.Reader
+	lineNum uint64
+	skipped uint64
 }
 
 // NewTripleParser opens a file and returns the relevant triple parser that will produce triples.
@@ -32,223 +106,140 @@ func NewTripleParser(filePath string) (*TripleParser, error) {
 	if err != nil {
 		return nil, err
 	}
-	scanner := bufio.NewReaderSize(reader, 4*1024*1024) // 4MB line Buffer
 
-	return &TripleParser{reader, scanner}, nil
+	return NewTripleParserFromReader(reader), nil
+}
+
+// NewTripleParserFromReader returns a triple parser that reads from an already opened stream. The parser
+// takes ownership of the reader and closes it on Close().
+func NewTripleParserFromReader(reader io.ReadCloser) *TripleParser {
+	scanner := bufio.NewReaderSize(reader, 4*1024*1024) // 4MB line Buffer
+	return &TripleParser{Mode: DefaultParseMode, reader: reader, scanner: scanner}
 }
 
 // NextTriple returns the next triple that is read from the internal file.
+// At the end of the file a nil triple and nil error are returned.
 //
 // Arguments:
-//   numTokens int : by adding the optional argument you can decide how many tokens
-//                   should be parsed. With 0, no tokens are parsed. With 1, 2 or 3
-//                   all tokens up to including Subject, Predicate and Object are
-//                   parsed. Non-parsed tokens are nil slices.
+//
+//	numTokens int : by adding the optional argument you can decide how many tokens
+//	                should be parsed. With 0, no tokens are parsed. With 1, 2 or 3
+//	                all tokens up to including Subject, Predicate and Object are
+//	                parsed. Non-parsed tokens are empty terms.
 //
 // Example:
-//    triple, err := tripleParser.NextTriple(2)  // consume line and parse subject and predicate.
 //
-// TODO: The parsing is a subset of the actual N-Triple syntax.
+//	triple, err := tripleParser.NextTriple(2)  // consume line and parse subject and predicate.
+//
+// In strict mode the first malformed line terminates the parsing with a *ParseError. In lenient
+// mode malformed lines are reported and skipped.
 func (tp *TripleParser) NextTriple(argNumTokens ...int) (*Triple, error) {
 
 	// parse the optional arguments
-	var numTokens int
+	numTokens := 3 // per default, all tokens are parsed
 	if len(argNumTokens) > 0 {
 		numTokens = argNumTokens[0]
-	} else {
-		numTokens = 3 // per default, all tokens are parsed
-	}
-
-	// read one line from the file
-	origLine, isPrefix, err := tp.scanner.ReadLine()
-	if err != nil && err != io.EOF { // misc error
-		return nil, err
-	} else if err == io.EOF { // file has ended
-		return nil, nil
-	}
-
-	// whenever a line is to be skipped, it needs to deliver the next line instead
-
-	// skip because line too big
-	if isPrefix {
-		fmt.Printf("Line Buffer too small!!! Line prefix: %v\n", string(origLine[:200]))
-		return tp.NextTriple()
-	}
-
-	// early termination if no tokens are to be parsed.
-	if numTokens == 0 {
-		return &Triple{nil, nil, nil, origLine}, nil
-	}
-
-	// process subject
-	subjectBytes, subjectToken := retrieveToken(origLine)
-	line := origLine[subjectBytes:]
-
-	// skip if line is empty of a comment
-	if len(subjectToken) == 0 || subjectToken[0] == '#' {
-		return tp.NextTriple()
-	}
-
-	// early termination if only one token should be parsed
-	if numTokens == 1 {
-		return &Triple{subjectToken, nil, nil, origLine}, nil
-	}
-
-	// process predicate
-	predicateBytes, predicateToken := retrieveToken(line)
-	line = line[predicateBytes:]
-
-	// early termination if only two tokens should be parsed
-	if numTokens == 2 {
-		return &Triple{subjectToken, predicateToken, nil, origLine}, nil
 	}
 
-	// process object
-	// TODO: Check if strings with spaces work, like:  "New York"@gb
-	_, objectToken := retrieveToken(line)
-
-	// return the triple with all information
-	return &Triple{subjectToken, predicateToken, objectToken, origLine}, nil
-}
-
-// Close the handlers for the scanner and underlying file.
-func (tp *TripleParser) Close() error {
-	defer tp.reader.Close()
-	return nil
-}
-
-// Output the values of a triple to stdout.
-func (t *Triple) Output() {
-	fmt.Println("( " + string(t.Subject) + " , " + string(t.Predicate) + " , " + string(t.Object) + " )")
-}
+	for {
+		line, err := tp.readLine()
+		if err == io.EOF { // file has ended
+			return nil, nil
+		} else if err != nil { // misc error
+			return nil, err
+		}
 
-// InterpreteLangLiteral will interprete a literal with language tag and return the
-// text and language code.
-// It is assumed that the token has no leading nor trailing spaces.
-func InterpreteLangLiteral(token []byte) (text []byte, lang []byte) {
-	var sigil rune // current rune that is being checked
-	var width int  // width of sigil
-	var start int  // remember the position where the literal text started
-
-	// Similar approach to reading tokens, but with less nesting options.
-	// Will detect the limits of the quoted string, and then use the rest and lang tag.
-	var quoting bool
-	var escaping bool
-	for pos := 0; pos < len(token); pos += width {
-		sigil, width = identifyRune(token[pos:])
-
-		// if escaping, just jump to next rune
-		if escaping == true {
-			escaping = false
+		var trip *Triple
+		if tp.Quads {
+			trip, err = ParseQuad(line, tp.Mode)
+		} else if tp.InverseMarkers && len(line) > 0 && line[0] == inverseMarker {
+			if trip, err = ParseTriple(line[1:], numTokens, tp.Mode); trip != nil {
+				trip.Inverse = true
+				trip.Line = line
+			}
+		} else {
+			trip, err = ParseTriple(line, numTokens, tp.Mode)
+		}
+		if err != nil {
+			perr := err.(*ParseError)
+			perr.Line = tp.lineNum
+			if tp.Mode == Strict {
+				return nil, perr
+			}
+			tp.skipped++
+			warnSkipped(tp.skipped, "triple", perr)
 			continue
 		}
-
-		// a backslash will start escaping, no parsing will ever be done with this
-		if sigil == '\\' {
-			escaping = true
+		if trip == nil { // empty line or comment
 			continue
 		}
-
-		// non-nesting state: can enter a nesting state and spaces will terminate token
-		if quoting == false && sigil == '"' { // should always enter this at first position
-			quoting = true
-			start = pos + width
-		} else if quoting == true && sigil == '"' { // exit the quoted string, text is found
-			quoting = false
-			text = token[start:pos]
-		} else if quoting == false && sigil == '@' { // tells us that lang tags are being used
-			lang = token[pos+width:]
-			break
+		if tp.graphs != nil && !tp.graphs[graphIdentifier(trip)] { // quad is not in a selected graph
+			continue
 		}
+		return trip, nil
 	}
-
-	return // naked return
 }
 
-// InterpreteIriRef will interprete an IRIREF and return the string that is enclosed by the
-// tag delimiters.
-// It is assumed that the token has no leading nor trailing spaces.
-func InterpreteIriRef(token []byte) (text []byte) {
-	var firstSigil, lastSigil rune
-
-	// Because I assume no leading and trailing spaces, I can use this shortcut.
-	firstSigil, _ = identifyRune(token[0:])
-	lastSigil, _ = identifyRune(token[len(token)-1:])
-	if firstSigil == '<' && lastSigil == '>' {
-		text = token[1 : len(token)-1]
-	} else {
-		text = token
+// SetGraphs restricts the quads that are returned to the given graphs. See DefaultGraphs.
+func (tp *TripleParser) SetGraphs(graphs []string) {
+	if len(graphs) == 0 {
+		tp.graphs = nil
+		return
+	}
+	tp.graphs = make(map[string]bool, len(graphs))
+	for _, g := range graphs {
+		tp.graphs[g] = true
 	}
-
-	return // naked return
 }
 
-// Retrieves a token from a N-Triple entry.
-// TODO: Test multiple escaping cases
-// TODO: This could be improved by allowing an argument to specify the max number of tokens to parse.
-//       If only the subject is needed than parsing the predicate and object becomes unnecessary.
-func retrieveToken(data []byte) (advance int, token []byte) {
-	var sigil rune        // current rune that is being checked
-	var width int         // width of sigil
-	var start, length int // delimiters for the token
-
-	// Skip leading spaces.
-	for start < len(data) {
-		sigil, width = identifyRune(data[start:])
-		if unicode.IsSpace(sigil) { // Could also use the isSpaceOrBracket method (without the brackets)
-			start += width
-		} else {
-			break
-		}
+func graphIdentifier(trip *Triple) string {
+	if trip.Graph.Kind == 0 {
+		return DefaultGraph
 	}
+	return trip.Graph.Identifier()
+}
 
-	// Catch the entire token, while paying attention to IRIREF brackets and literal quotes.
-	var nesting rune
-	var escaping bool
-	for ; start+length < len(data); length += width {
-		sigil, width = identifyRune(data[start+length:])
-
-		// if escaping, just jump to next rune
-		if escaping == true {
-			escaping = false
-			continue
-		}
-
-		// a backslash will start escaping, no parsing will ever be done with this
-		if sigil == '\\' {
-			escaping = true
-			continue
+// readLine returns a copy of the next line that fits into the line buffer. Longer lines are skipped.
+func (tp *TripleParser) readLine() ([]byte, error) {
+	for {
+		line, isPrefix, err := tp.scanner.ReadLine()
+		if err != nil {
+			return nil, err
 		}
+		tp.lineNum++
 
-		// non-nesting state: can enter a nesting state and spaces will terminate token
-		if nesting == 0 {
-			if sigil == '<' || sigil == '"' {
-				nesting = sigil
-			} else if escaping == false && unicode.IsSpace(sigil) {
-				break
+		// skip because line too big
+		if isPrefix {
+			fmt.Printf("Line Buffer too small!!! Skipping line %v with prefix: %v\n", tp.lineNum, string(line[:200]))
+			for isPrefix && err == nil {
+				_, isPrefix, err = tp.scanner.ReadLine()
 			}
-		} else { // nesting state: can exit nesting state
-			if (nesting == '<' && sigil == '>') || (nesting == '"' && sigil == '"') {
-				nesting = 0
+			if err != nil {
+				return nil, err
 			}
+			continue
 		}
-	}
 
-	// if we're at EOL, and we have a final, non-empty, non-terminated word. Return it.
-	if start+length >= len(data) {
-		return len(data), data[start:]
+		return append([]byte(nil), line...), nil
 	}
+}
 
-	// return the token
-	return start + length, data[start : start+length]
+// LineNumber returns the number of the line that has been read last.
+func (tp *TripleParser) LineNumber() uint64 {
+	return tp.lineNum
 }
 
-// Identify rune and width.
-// Uses a special procedure to check for 1-width ASCII runes.
-// TODO: Check if this special procedure is really faster.
-func identifyRune(data []byte) (sigil rune, width int) {
-	if data[0] < utf8.RuneSelf {
-		return rune(data[0]), 1
-	}
-	return utf8.DecodeRune(data)
+// SkippedLines returns the number of malformed lines that have been skipped in lenient mode.
+func (tp *TripleParser) SkippedLines() uint64 {
+	return tp.skipped
+}
+
+// Close the handlers for the scanner and underlying file.
+func (tp *TripleParser) Close() error {
+	return tp.reader.Close()
+}
+
+// Output the values of a triple to stdout.
+func (t *Triple) Output() {
+	fmt.Println("( " + string(t.Subject.Raw) + " , " + string(t.Predicate.Raw) + " , " + string(t.Object.Raw) + " )")
 }
@@ -0,0 +1,148 @@
+package io
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTriple(t *testing.T) {
+
+	t.Run("IRIs", func(t *testing.T) {
+		trip, err := ParseTriple([]byte(`<http://ex.org/s> <http://ex.org/p> <http://ex.org/o> .`), 3, Strict)
+		assert.NoError(t, err)
+		assert.Equal(t, IRI, trip.Subject.Kind)
+		assert.Equal(t, "http://ex.org/s", string(trip.Subject.Value))
+		assert.Equal(t, "<http://ex.org/s>", string(trip.Subject.Raw))
+		assert.Equal(t, "http://ex.org/p", string(trip.Predicate.Value))
+		assert.Equal(t, "http://ex.org/o", string(trip.Object.Value))
+	})
+
+	t.Run("literal with spaces and language tag", func(t *testing.T) {
+		trip, err := ParseTriple([]byte(`<http://ex.org/s> <http://ex.org/p> "New York City"@en-US .`), 3, Strict)
+		assert.NoError(t, err)
+		assert.Equal(t, Literal, trip.Object.Kind)
+		assert.Equal(t, "New York City", string(trip.Object.Value))
+		assert.Equal(t, "en-US", string(trip.Object.Lang))
+		assert.Nil(t, trip.Object.Datatype)
+	})
+
+	t.Run("literal with datatype", func(t *testing.T) {
+		trip, err := ParseTriple([]byte(`<http://ex.org/s> <http://ex.org/p> "42"^^<http://www.w3.org/2001/XMLSchema#integer> .`), 3, Strict)
+		assert.NoError(t, err)
+		assert.Equal(t, "42", string(trip.Object.Value))
+		assert.Equal(t, "http://www.w3.org/2001/XMLSchema#integer", string(trip.Object.Datatype))
+	})
+
+	t.Run("escapes", func(t *testing.T) {
+		trip, err := ParseTriple([]byte(`<http://ex.org/é> <http://ex.org/p> "say \"hi\"\tand \U0001F600" .`), 3, Strict)
+		assert.NoError(t, err)
+		assert.Equal(t, "http://ex.org/é", string(trip.Subject.Value))
+		assert.Equal(t, "say \"hi\"\tand 😀", string(trip.Object.Value))
+	})
+
+	t.Run("blank nodes", func(t *testing.T) {
+		trip, err := ParseTriple([]byte(`_:b0 <http://ex.org/p> _:node.1.`), 3, Strict)
+		assert.NoError(t, err)
+		assert.Equal(t, BlankNode, trip.Subject.Kind)
+		assert.Equal(t, "b0", string(trip.Subject.Value))
+		assert.Equal(t, "_:b0", trip.Subject.Identifier())
+		assert.Equal(t, "node.1", string(trip.Object.Value))
+	})
+
+	t.Run("comments and empty lines", func(t *testing.T) {
+		trip, err := ParseTriple([]byte(`  # just a comment`), 3, Strict)
+		assert.NoError(t, err)
+		assert.Nil(t, trip)
+		trip, err = ParseTriple([]byte(``), 3, Strict)
+		assert.NoError(t, err)
+		assert.Nil(t, trip)
+		trip, err = ParseTriple([]byte(`<http://ex.org/s> <http://ex.org/p> "o" . # trailing`), 3, Strict)
+		assert.NoError(t, err)
+		assert.Equal(t, "o", string(trip.Object.Value))
+	})
+
+	t.Run("partial parsing", func(t *testing.T) {
+		trip, err := ParseTriple([]byte(`<http://ex.org/s> <http://ex.org/p> this is not parsed`), 2, Strict)
+		assert.NoError(t, err)
+		assert.Equal(t, "http://ex.org/p", string(trip.Predicate.Value))
+		assert.Equal(t, TermKind(0), trip.Object.Kind)
+	})
+
+	t.Run("missing dot", func(t *testing.T) {
+		_, err := ParseTriple([]byte(`<http://ex.org/s> <http://ex.org/p> "o"`), 3, Strict)
+		assert.Error(t, err)
+		trip, err := ParseTriple([]byte(`<http://ex.org/s> <http://ex.org/p> "o"`), 3, Lenient)
+		assert.NoError(t, err)
+		assert.Equal(t, "o", string(trip.Object.Value))
+	})
+
+	t.Run("relative IRI", func(t *testing.T) {
+		_, err := ParseTriple([]byte(`<local> <http://ex.org/p> <http://ex.org/o> .`), 3, Strict)
+		assert.Error(t, err)
+		_, err = ParseTriple([]byte(`<local> <http://ex.org/p> <http://ex.org/o> .`), 3, Lenient)
+		assert.NoError(t, err)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		for _, line := range []string{
+			`<http://ex.org/s> <http://ex.org/p> "unterminated .`,
+			`<http://ex.org/s <http://ex.org/p> <http://ex.org/o> .`,
+			`"literal" <http://ex.org/p> <http://ex.org/o> .`,
+			`<http://ex.org/s> _:p <http://ex.org/o> .`,
+			`<http://ex.org/s> <http://ex.org/p> "o"@ .`,
+			`<http://ex.org/s> <http://ex.org/p> <http://ex.org/o> . garbage`,
+		} {
+			_, err := ParseTriple([]byte(line), 3, Lenient)
+			assert.Error(t, err, line)
+			assert.IsType(t, &ParseError{}, err, line)
+		}
+	})
+}
+
+func TestTripleParser(t *testing.T) {
+	input := strings.Join([]string{
+		`<http://ex.org/a> <http://ex.org/p> "one two" .`,
+		`# comment`,
+		`<http://ex.org/a> <http://ex.org/q> "broken .`,
+		``,
+		`<http://ex.org/b> <http://ex.org/p> _:x .`,
+	}, "\n")
+
+	t.Run("lenient", func(t *testing.T) {
+		tp := NewTripleParserFromReader(ioutil.NopCloser(strings.NewReader(input)))
+		tp.Mode = Lenient
+		defer tp.Close()
+
+		first, err := tp.NextTriple()
+		assert.NoError(t, err)
+		second, err := tp.NextTriple()
+		assert.NoError(t, err)
+		assert.Equal(t, "http://ex.org/b", string(second.Subject.Value))
+		assert.EqualValues(t, 5, tp.LineNumber())
+		assert.EqualValues(t, 1, tp.SkippedLines())
+
+		// triples own their line and stay intact after further reads
+		assert.Equal(t, "one two", string(first.Object.Value))
+
+		last, err := tp.NextTriple()
+		assert.NoError(t, err)
+		assert.Nil(t, last)
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		tp := NewTripleParserFromReader(ioutil.NopCloser(strings.NewReader(input)))
+		tp.Mode = Strict
+		defer tp.Close()
+
+		_, err := tp.NextTriple()
+		assert.NoError(t, err)
+		_, err = tp.NextTriple()
+		if assert.Error(t, err) {
+			assert.EqualValues(t, 3, err.(*ParseError).Line)
+			assert.Contains(t, err.Error(), "line 3")
+		}
+	})
+}
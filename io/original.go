@@ -1,77 +0,0 @@
-package io
-
-// Original methods that were previously in the `schematree.io` file.
-
-import (
-	"unicode/utf8"
-)
-
-// Adapted from 'ScanWords' in https://golang.org/src/bufio/scan.go
-//
-// firstWord returns the first space-separated word of text, with
-// surrounding spaces & angle brackets deleted.
-func firstWord(data []byte) (advance int, token []byte) {
-	// Skip leading spaces.
-	start := 0
-	for width := 0; start < len(data); start += width {
-		if data[start] < utf8.RuneSelf {
-			if !isSpaceOrBracket(rune(data[start])) {
-				break
-			}
-			width = 1
-		} else {
-			var r rune
-			r, width = utf8.DecodeRune(data[start:])
-			if !isSpaceOrBracket(r) {
-				break
-			}
-		}
-	}
-	// Scan until space, marking end of word.
-	for width, i := 0, start; i < len(data); i += width {
-		// Fast path 1: ASCII.
-		if data[i] < utf8.RuneSelf {
-			if isSpaceOrBracket(rune(data[i])) {
-				return i + 1, data[start:i]
-			}
-			width = 1
-		} else {
-			var r rune
-			r, width = utf8.DecodeRune(data[i:])
-			if isSpaceOrBracket(r) {
-				return i + width, data[start:i]
-			}
-		}
-	}
-	// If we're at EOL, we have a final, non-empty, non-terminated word. Return it.
-	if len(data) > start {
-		return len(data), data[start:]
-	}
-	// Request more data.
-	return start, nil
-}
-
-// Adapted from 'isSpace' in https://golang.org/src/bufio/scan.go
-//
-// isSpace reports whether the character is a Unicode white space character .
-func isSpaceOrBracket(r rune) bool {
-	if r <= '\u00FF' {
-		// Obvious ASCII ones: \t through \r plus space. Plus two Latin-1 oddballs.
-		switch r {
-		case ' ', '\t', '\n', '\v', '\f', '\r', '<', '>':
-			return true
-		case '\u0085', '\u00A0':
-			return true
-		}
-		return false
-	}
-	// High-valued ones.
-	if '\u2000' <= r && r <= '\u200a' {
-		return true
-	}
-	switch r {
-	case '\u1680', '\u2028', '\u2029', '\u202f', '\u205f', '\u3000':
-		return true
-	}
-	return false
-}
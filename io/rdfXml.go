@@ -0,0 +1,415 @@
+package io
+
+// Streaming reader for the RDF/XML serialization as defined in https://www.w3.org/TR/rdf-syntax-grammar/.
+// Every top-level node element is turned into a batch of triples that is handed out grouped by subject.
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/url"
+	"strconv"
+)
+
+const xmlNS = "http://www.w3.org/XML/1998/namespace"
+
+// RDFXMLParser reads an RDF/XML file and produces triples from it.
+type RDFXMLParser struct {
+	Mode ParseMode // Strict enables the strict mode of the XML decoder, defaults to DefaultParseMode
+
+	reader   io.ReadCloser
+	decoder  *xml.Decoder
+	started  bool
+	root     rdfXMLContext // context given by the rdf:RDF element
+	blankIDs uint64
+	pending  []*Triple // triples of the current node element that have not been returned yet
+	current  []*Triple // triples of the node element that is being parsed
+}
+
+// rdfXMLContext holds the inherited attributes of an element.
+type rdfXMLContext struct {
+	base string
+	lang string
+}
+
+// NewRDFXMLParser opens a file and returns a parser that will produce its triples.
+func NewRDFXMLParser(filePath string) (*RDFXMLParser, error) {
+	reader, err := UniversalReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewRDFXMLParserFromReader(reader), nil
+}
+
+// NewRDFXMLParserFromReader returns an RDF/XML parser that reads from an already opened stream. The parser
+// takes ownership of the reader and closes it on Close().
+func NewRDFXMLParserFromReader(reader io.ReadCloser) *RDFXMLParser {
+	return &RDFXMLParser{Mode: DefaultParseMode, reader: reader, decoder: xml.NewDecoder(reader)}
+}
+
+// NextTriple returns the next triple of the file. At the end of the file a nil triple and nil error are returned.
+// The optional argument is accepted for compatibility with TripleParser and ignored.
+//
+// Errors in the XML structure cannot be recovered from and terminate the parsing in both modes.
+func (p *RDFXMLParser) NextTriple(argNumTokens ...int) (*Triple, error) {
+	if !p.started {
+		p.decoder.Strict = p.Mode == Strict
+		p.started = true
+	}
+
+	for len(p.pending) == 0 {
+		start, err := p.nextStart()
+		if err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		ctx := p.root
+		ctx.apply(start)
+		p.current = p.current[:0]
+		if start.Name.Space == rdfNS && start.Name.Local == "RDF" {
+			// the root element is only a container, its children are the top-level nodes
+			p.root = ctx
+			continue
+		}
+		subject, err := p.parseNodeElement(start, ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.pending = groupBySubject(append([]*Triple(nil), p.current...), subject)
+	}
+
+	trip := p.pending[0]
+	p.pending = p.pending[1:]
+	return trip, nil
+}
+
+// Close the handlers for the decoder and underlying file.
+func (p *RDFXMLParser) Close() error {
+	return p.reader.Close()
+}
+
+// nextStart skips to the next start element, across end elements of containers.
+func (p *RDFXMLParser) nextStart() (xml.StartElement, error) {
+	for {
+		tok, err := p.token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start, nil
+		}
+	}
+}
+
+// token reads the next XML token and converts syntax errors to parse errors.
+func (p *RDFXMLParser) token() (xml.Token, error) {
+	tok, err := p.decoder.Token()
+	if serr, ok := err.(*xml.SyntaxError); ok {
+		return nil, &ParseError{Line: uint64(serr.Line), Column: 1, Msg: serr.Msg}
+	}
+	return tok, err
+}
+
+func (p *RDFXMLParser) errorf(msg string) *ParseError {
+	line, column := p.decoder.InputPos()
+	return &ParseError{Line: uint64(line), Column: column, Msg: msg}
+}
+
+// apply updates the context with xml:base and xml:lang of an element.
+func (ctx *rdfXMLContext) apply(el xml.StartElement) {
+	for _, attr := range el.Attr {
+		if attr.Name.Space != xmlNS {
+			continue
+		}
+		switch attr.Name.Local {
+		case "base":
+			ctx.base = ctx.resolve(attr.Value)
+		case "lang":
+			ctx.lang = attr.Value
+		}
+	}
+}
+
+// resolve makes an IRI absolute with respect to the current base IRI.
+func (ctx *rdfXMLContext) resolve(iri string) string {
+	if ctx.base == "" || isAbsoluteIRI([]byte(iri)) {
+		return iri
+	}
+	base, err := url.Parse(ctx.base)
+	if err != nil {
+		return ctx.base + iri
+	}
+	ref, err := url.Parse(iri)
+	if err != nil {
+		return ctx.base + iri
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// rdfAttr returns the value of an attribute in the RDF namespace.
+func rdfAttr(el xml.StartElement, local string) (string, bool) {
+	for _, attr := range el.Attr {
+		if attr.Name.Space == rdfNS && attr.Name.Local == local {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// isSyntaxAttr returns true for attributes that do not describe properties.
+func isSyntaxAttr(name xml.Name) bool {
+	if name.Space == xmlNS || name.Space == "xmlns" || name.Space == "" { // unqualified attributes are not allowed in RDF/XML
+		return true
+	}
+	if name.Space != rdfNS {
+		return false
+	}
+	switch name.Local {
+	case "about", "ID", "nodeID", "resource", "parseType", "datatype", "aboutEach", "aboutEachPrefix", "bagID":
+		return true
+	}
+	return false
+}
+
+func (p *RDFXMLParser) newBlankNode() Term {
+	p.blankIDs++
+	return NewBlankNode("genid" + strconv.FormatUint(p.blankIDs, 10))
+}
+
+func (p *RDFXMLParser) emit(subject, predicate, object Term) {
+	p.current = append(p.current, NewTriple(subject, predicate, object))
+}
+
+// parseNodeElement consumes a node element including its end tag and returns its subject.
+func (p *RDFXMLParser) parseNodeElement(start xml.StartElement, ctx rdfXMLContext) (Term, error) {
+	var subject Term
+	if about, ok := rdfAttr(start, "about"); ok {
+		subject = NewIRI(ctx.resolve(about))
+	} else if id, ok := rdfAttr(start, "ID"); ok {
+		subject = NewIRI(ctx.resolve("#" + id))
+	} else if nodeID, ok := rdfAttr(start, "nodeID"); ok {
+		subject = NewBlankNode(nodeID)
+	} else {
+		subject = p.newBlankNode()
+	}
+
+	if !(start.Name.Space == rdfNS && start.Name.Local == "Description") {
+		p.emit(subject, NewIRI(rdfType), NewIRI(start.Name.Space+start.Name.Local))
+	}
+	p.emitPropertyAttrs(subject, start, ctx)
+
+	return subject, p.parsePropertyElements(subject, ctx)
+}
+
+// emitPropertyAttrs creates the triples of the property attributes of an element.
+func (p *RDFXMLParser) emitPropertyAttrs(subject Term, el xml.StartElement, ctx rdfXMLContext) {
+	for _, attr := range el.Attr {
+		if isSyntaxAttr(attr.Name) {
+			continue
+		}
+		predicate := NewIRI(attr.Name.Space + attr.Name.Local)
+		if attr.Name.Space == rdfNS && attr.Name.Local == "type" {
+			p.emit(subject, predicate, NewIRI(ctx.resolve(attr.Value)))
+		} else {
+			p.emit(subject, predicate, NewLiteral(attr.Value, ctx.lang, ""))
+		}
+	}
+}
+
+// parsePropertyElements consumes the property elements of a node up to the end tag of the node.
+func (p *RDFXMLParser) parsePropertyElements(subject Term, ctx rdfXMLContext) error {
+	li := 0
+	for {
+		tok, err := p.token()
+		if err != nil {
+			return p.unexpectedEOF(err)
+		}
+		switch el := tok.(type) {
+		case xml.EndElement:
+			return nil
+		case xml.CharData:
+			if len(bytes.TrimSpace(el)) > 0 {
+				return p.errorf("unexpected text between property elements")
+			}
+		case xml.StartElement:
+			propCtx := ctx
+			propCtx.apply(el)
+
+			iri := el.Name.Space + el.Name.Local
+			if el.Name.Space == rdfNS && el.Name.Local == "li" {
+				li++
+				iri = rdfNS + "_" + strconv.Itoa(li)
+			}
+			if err := p.parsePropertyElement(subject, NewIRI(iri), el, propCtx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// parsePropertyElement consumes a single property element including its end tag.
+func (p *RDFXMLParser) parsePropertyElement(subject, predicate Term, el xml.StartElement, ctx rdfXMLContext) error {
+	parseType, _ := rdfAttr(el, "parseType")
+	switch parseType {
+	case "Resource":
+		node := p.newBlankNode()
+		p.emit(subject, predicate, node)
+		return p.parsePropertyElements(node, ctx)
+	case "Literal":
+		content, err := p.innerXML()
+		if err != nil {
+			return err
+		}
+		p.emit(subject, predicate, NewLiteral(content, "", rdfNS+"XMLLiteral"))
+		return nil
+	case "Collection":
+		return p.parseCollection(subject, predicate, ctx)
+	}
+
+	// object given by attributes
+	if resource, ok := rdfAttr(el, "resource"); ok {
+		object := NewIRI(ctx.resolve(resource))
+		p.emit(subject, predicate, object)
+		p.emitPropertyAttrs(object, el, ctx)
+		return p.skipEmpty()
+	}
+	if nodeID, ok := rdfAttr(el, "nodeID"); ok {
+		object := NewBlankNode(nodeID)
+		p.emit(subject, predicate, object)
+		p.emitPropertyAttrs(object, el, ctx)
+		return p.skipEmpty()
+	}
+
+	// object given by content: either a literal or a single node element
+	datatype, _ := rdfAttr(el, "datatype")
+	var text []byte
+	for {
+		tok, err := p.token()
+		if err != nil {
+			return p.unexpectedEOF(err)
+		}
+		switch content := tok.(type) {
+		case xml.CharData:
+			text = append(text, content...)
+		case xml.StartElement:
+			if len(bytes.TrimSpace(text)) > 0 {
+				return p.errorf("mixed content in property element")
+			}
+			nodeCtx := ctx
+			nodeCtx.apply(content)
+			object, err := p.parseNodeElement(content, nodeCtx)
+			if err != nil {
+				return err
+			}
+			p.emit(subject, predicate, object)
+			return p.skipEmpty()
+		case xml.EndElement:
+			if len(text) == 0 && datatype == "" && hasPropertyAttrs(el) {
+				object := p.newBlankNode()
+				p.emit(subject, predicate, object)
+				p.emitPropertyAttrs(object, el, ctx)
+				return nil
+			}
+			if datatype != "" {
+				p.emit(subject, predicate, NewLiteral(string(text), "", ctx.resolve(datatype)))
+			} else {
+				p.emit(subject, predicate, NewLiteral(string(text), ctx.lang, ""))
+			}
+			return nil
+		}
+	}
+}
+
+func hasPropertyAttrs(el xml.StartElement) bool {
+	for _, attr := range el.Attr {
+		if !isSyntaxAttr(attr.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCollection consumes the node elements of a parseType="Collection" property as an RDF list.
+func (p *RDFXMLParser) parseCollection(subject, predicate Term, ctx rdfXMLContext) error {
+	last := subject
+	lastPredicate := predicate
+	for {
+		tok, err := p.token()
+		if err != nil {
+			return p.unexpectedEOF(err)
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			nodeCtx := ctx
+			nodeCtx.apply(el)
+			node := p.newBlankNode()
+			p.emit(last, lastPredicate, node)
+			item, err := p.parseNodeElement(el, nodeCtx)
+			if err != nil {
+				return err
+			}
+			p.emit(node, NewIRI(rdfFirst), item)
+			last, lastPredicate = node, NewIRI(rdfRest)
+		case xml.EndElement:
+			p.emit(last, lastPredicate, NewIRI(rdfNil))
+			return nil
+		}
+	}
+}
+
+// skipEmpty consumes the end tag of an element that must not have further content.
+func (p *RDFXMLParser) skipEmpty() error {
+	for {
+		tok, err := p.token()
+		if err != nil {
+			return p.unexpectedEOF(err)
+		}
+		switch el := tok.(type) {
+		case xml.EndElement:
+			return nil
+		case xml.StartElement:
+			return p.errorf("unexpected element <" + el.Name.Local + "> in empty property element")
+		case xml.CharData:
+			if len(bytes.TrimSpace(el)) > 0 {
+				return p.errorf("unexpected text in empty property element")
+			}
+		}
+	}
+}
+
+// innerXML serializes the content of the current element up to its end tag.
+func (p *RDFXMLParser) innerXML() (string, error) {
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+	depth := 0
+	for {
+		tok, err := p.token()
+		if err != nil {
+			return "", p.unexpectedEOF(err)
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				if err := enc.Flush(); err != nil {
+					return "", err
+				}
+				return buf.String(), nil
+			}
+			depth--
+		}
+		if err := enc.EncodeToken(xml.CopyToken(tok)); err != nil {
+			return "", err
+		}
+	}
+}
+
+func (p *RDFXMLParser) unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return p.errorf("unexpected end of file")
+	}
+	return err
+}
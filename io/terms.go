@@ -0,0 +1,525 @@
+package io
+
+// Tokenizer for the terms of the N-Triples grammar as defined in https://www.w3.org/TR/n-triples/.
+// The lexer is kept separate from the line reader so that other line-based formats can reuse it.
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// TermKind distinguishes the kinds of RDF terms that can appear in a triple.
+type TermKind uint8
+
+// Kinds of RDF terms. The zero value marks a term that has not been parsed.
+const (
+	IRI TermKind = iota + 1
+	BlankNode
+	Literal
+)
+
+// Term is a single RDF term of a triple.
+//
+// All byte slices point into the line the term was parsed from, unless the term contained
+// escape sequences, in which case Value and Datatype hold freshly decoded copies.
+type Term struct {
+	Kind     TermKind
+	Value    []byte // decoded IRI, blank node label (without `_:`) or literal lexical form
+	Lang     []byte // language tag of a literal (without `@`), lowercase is not enforced
+	Datatype []byte // decoded datatype IRI of a literal, nil if none was given
+	Raw      []byte // the term exactly as written in the input
+}
+
+// IsIRI returns true if the term is an IRI.
+func (t *Term) IsIRI() bool { return t.Kind == IRI }
+
+// IsBlankNode returns true if the term is a blank node.
+func (t *Term) IsBlankNode() bool { return t.Kind == BlankNode }
+
+// IsLiteral returns true if the term is a literal.
+func (t *Term) IsLiteral() bool { return t.Kind == Literal }
+
+// Identifier returns the representation used to identify the term in the models: the decoded
+// IRI for IRIs, `_:label` for blank nodes and the raw N-Triples form for literals.
+func (t *Term) Identifier() string {
+	switch t.Kind {
+	case IRI:
+		return string(t.Value)
+	case BlankNode:
+		return "_:" + string(t.Value)
+	}
+	return string(t.Raw)
+}
+
+// ParseError is returned for input that does not conform to the grammar. Line is only set when
+// the error was produced by a reader that keeps track of line numbers.
+type ParseError struct {
+	Line   uint64
+	Column int // 1-based byte offset in the line
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+	}
+	return fmt.Sprintf("column %d: %s", e.Column, e.Msg)
+}
+
+// ParseMode selects how strictly the grammar is enforced.
+type ParseMode uint8
+
+const (
+	// Lenient accepts common deviations from the specification, like missing terminating dots,
+	// relative IRIs or invalid characters inside IRIs. Malformed lines are skipped with a warning.
+	Lenient ParseMode = iota
+	// Strict rejects every deviation from the specification and aborts on the first error.
+	Strict
+)
+
+// DefaultParseMode is the mode given to all newly created parsers.
+var DefaultParseMode = Lenient
+
+// lexer tokenizes RDF terms from a single line of input.
+type lexer struct {
+	data   []byte
+	pos    int
+	strict bool
+}
+
+func (l *lexer) errorf(format string, args ...interface{}) *ParseError {
+	return &ParseError{Column: l.pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+// eof returns true if the whole line has been consumed.
+func (l *lexer) eof() bool {
+	return l.pos >= len(l.data)
+}
+
+// skipSpace advances over spaces and tabs, which are the only whitespace allowed in a line.
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.data) && (l.data[l.pos] == ' ' || l.data[l.pos] == '\t' || l.data[l.pos] == '\r') {
+		l.pos++
+	}
+}
+
+// skipComment consumes a trailing comment and returns true if one was found.
+func (l *lexer) skipComment() bool {
+	if l.pos < len(l.data) && l.data[l.pos] == '#' {
+		l.pos = len(l.data)
+		return true
+	}
+	return false
+}
+
+// parseSubject reads an IRIREF or BLANK_NODE_LABEL.
+func (l *lexer) parseSubject() (Term, error) {
+	if l.eof() {
+		return Term{}, l.errorf("expected subject, found end of line")
+	}
+	switch l.data[l.pos] {
+	case '<':
+		return l.parseIRIRef()
+	case '_':
+		return l.parseBlankNode()
+	}
+	return Term{}, l.errorf("expected IRI or blank node as subject, found %q", l.peekRune())
+}
+
+// parsePredicate reads an IRIREF.
+func (l *lexer) parsePredicate() (Term, error) {
+	if l.eof() {
+		return Term{}, l.errorf("expected predicate, found end of line")
+	}
+	if l.data[l.pos] != '<' {
+		return Term{}, l.errorf("expected IRI as predicate, found %q", l.peekRune())
+	}
+	return l.parseIRIRef()
+}
+
+// parseObject reads an IRIREF, BLANK_NODE_LABEL or literal.
+func (l *lexer) parseObject() (Term, error) {
+	if l.eof() {
+		return Term{}, l.errorf("expected object, found end of line")
+	}
+	switch l.data[l.pos] {
+	case '<':
+		return l.parseIRIRef()
+	case '_':
+		return l.parseBlankNode()
+	case '"':
+		return l.parseLiteral()
+	}
+	return Term{}, l.errorf("expected IRI, blank node or literal as object, found %q", l.peekRune())
+}
+
+// parseEnd consumes the terminating dot and an optional comment. Lenient mode does not require the dot.
+func (l *lexer) parseEnd() error {
+	l.skipSpace()
+	if !l.eof() && l.data[l.pos] == '.' {
+		l.pos++
+	} else if l.strict {
+		if l.eof() {
+			return l.errorf("expected '.', found end of line")
+		}
+		return l.errorf("expected '.', found %q", l.peekRune())
+	}
+	l.skipSpace()
+	if l.eof() || l.skipComment() {
+		return nil
+	}
+	return l.errorf("unexpected %q after end of triple", l.peekRune())
+}
+
+func (l *lexer) peekRune() rune {
+	r, _ := utf8.DecodeRune(l.data[l.pos:])
+	return r
+}
+
+// parseIRIRef reads `<...>`, validating the content in strict mode and decoding UCHAR escapes.
+func (l *lexer) parseIRIRef() (Term, error) {
+	start := l.pos
+	l.pos++ // opening bracket
+	valueStart := l.pos
+	hasEscapes := false
+	for {
+		if l.eof() {
+			l.pos = start
+			return Term{}, l.errorf("unterminated IRI")
+		}
+		c := l.data[l.pos]
+		if c == '>' {
+			break
+		}
+		if c == '\\' {
+			if l.pos+1 >= len(l.data) || (l.data[l.pos+1] != 'u' && l.data[l.pos+1] != 'U') {
+				if l.strict {
+					return Term{}, l.errorf("invalid escape sequence in IRI")
+				}
+			} else {
+				hasEscapes = true
+			}
+		} else if l.strict && (c <= 0x20 || bytes.IndexByte([]byte("<\"{}|^`"), c) >= 0) {
+			return Term{}, l.errorf("invalid character %q in IRI", rune(c))
+		} else if c == '<' || c == '\n' {
+			return Term{}, l.errorf("invalid character %q in IRI", rune(c))
+		}
+		l.pos++
+	}
+	value := l.data[valueStart:l.pos]
+	l.pos++ // closing bracket
+
+	if hasEscapes {
+		decoded, errPos := decodeEscapes(value, false)
+		if errPos >= 0 {
+			if l.strict {
+				l.pos = valueStart + errPos
+				return Term{}, l.errorf("invalid unicode escape in IRI")
+			}
+			decoded = value // keep the raw text instead of failing
+		}
+		value = decoded
+	}
+	if l.strict && !isAbsoluteIRI(value) {
+		l.pos = valueStart
+		return Term{}, l.errorf("IRI <%s> is not absolute", value)
+	}
+	return Term{Kind: IRI, Value: value, Raw: l.data[start:l.pos]}, nil
+}
+
+// parseBlankNode reads `_:label`.
+func (l *lexer) parseBlankNode() (Term, error) {
+	start := l.pos
+	if l.pos+1 >= len(l.data) || l.data[l.pos+1] != ':' {
+		return Term{}, l.errorf("expected '_:' to start a blank node")
+	}
+	l.pos += 2
+	labelStart := l.pos
+
+	// first character: PN_CHARS_U | [0-9]
+	if l.eof() {
+		return Term{}, l.errorf("empty blank node label")
+	}
+	r, w := utf8.DecodeRune(l.data[l.pos:])
+	if !isPNCharsU(r) && !(r >= '0' && r <= '9') {
+		return Term{}, l.errorf("invalid character %q at start of blank node label", r)
+	}
+	l.pos += w
+
+	// following characters: (PN_CHARS | '.')* PN_CHARS
+	for !l.eof() {
+		r, w = utf8.DecodeRune(l.data[l.pos:])
+		if !isPNChars(r) && r != '.' {
+			break
+		}
+		l.pos += w
+	}
+	for l.data[l.pos-1] == '.' { // a label must not end with a dot; it belongs to the triple
+		l.pos--
+	}
+	if l.strict && !l.eof() && !isDelimiter(l.data[l.pos]) {
+		return Term{}, l.errorf("invalid character %q in blank node label", l.peekRune())
+	}
+	return Term{Kind: BlankNode, Value: l.data[labelStart:l.pos], Raw: l.data[start:l.pos]}, nil
+}
+
+// parseLiteral reads a quoted string with an optional language tag or datatype IRI.
+func (l *lexer) parseLiteral() (Term, error) {
+	start := l.pos
+	l.pos++ // opening quote
+	valueStart := l.pos
+	hasEscapes := false
+	for {
+		if l.eof() {
+			l.pos = start
+			return Term{}, l.errorf("unterminated literal")
+		}
+		c := l.data[l.pos]
+		if c == '"' {
+			break
+		}
+		if c == '\\' {
+			hasEscapes = true
+			l.pos++ // the escaped character can never terminate the literal
+		} else if c == '\n' || c == '\r' {
+			return Term{}, l.errorf("unescaped line break in literal")
+		}
+		l.pos++
+	}
+	value := l.data[valueStart:l.pos]
+	l.pos++ // closing quote
+
+	if hasEscapes {
+		decoded, errPos := decodeEscapes(value, true)
+		if errPos >= 0 {
+			if l.strict {
+				l.pos = valueStart + errPos
+				return Term{}, l.errorf("invalid escape sequence in literal")
+			}
+			decoded = value // keep the raw text instead of failing
+		}
+		value = decoded
+	}
+	term := Term{Kind: Literal, Value: value}
+
+	// annotations
+	if !l.eof() && l.data[l.pos] == '@' {
+		l.pos++
+		langStart := l.pos
+		for !l.eof() && isLangTagChar(l.data[l.pos], l.pos == langStart) {
+			l.pos++
+		}
+		term.Lang = l.data[langStart:l.pos]
+		if len(term.Lang) == 0 || term.Lang[len(term.Lang)-1] == '-' {
+			return Term{}, l.errorf("invalid language tag")
+		}
+	} else if l.pos+1 < len(l.data) && l.data[l.pos] == '^' && l.data[l.pos+1] == '^' {
+		l.pos += 2
+		if l.eof() || l.data[l.pos] != '<' {
+			return Term{}, l.errorf("expected datatype IRI after '^^'")
+		}
+		dt, err := l.parseIRIRef()
+		if err != nil {
+			return Term{}, err
+		}
+		term.Datatype = dt.Value
+	}
+
+	if l.strict && !l.eof() && !isDelimiter(l.data[l.pos]) {
+		return Term{}, l.errorf("unexpected %q after literal", l.peekRune())
+	}
+	term.Raw = l.data[start:l.pos]
+	return term, nil
+}
+
+// decodeEscapes replaces UCHAR (and if allowed ECHAR) escape sequences. On failure it returns the
+// offset of the invalid sequence, otherwise -1.
+func decodeEscapes(data []byte, allowECHAR bool) ([]byte, int) {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if c != '\\' {
+			out = append(out, c)
+			continue
+		}
+		if i+1 >= len(data) {
+			return nil, i
+		}
+		switch data[i+1] {
+		case 'u', 'U':
+			n := 4
+			if data[i+1] == 'U' {
+				n = 8
+			}
+			if i+2+n > len(data) {
+				return nil, i
+			}
+			r, ok := parseHex(data[i+2 : i+2+n])
+			if !ok || !utf8.ValidRune(r) {
+				return nil, i
+			}
+			out = utf8.AppendRune(out, r)
+			i += 1 + n
+			continue
+		}
+		if !allowECHAR {
+			return nil, i
+		}
+		switch data[i+1] {
+		case 't':
+			out = append(out, '\t')
+		case 'b':
+			out = append(out, '\b')
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 'f':
+			out = append(out, '\f')
+		case '"', '\'', '\\':
+			out = append(out, data[i+1])
+		default:
+			return nil, i
+		}
+		i++
+	}
+	return out, -1
+}
+
+func parseHex(data []byte) (r rune, ok bool) {
+	for _, c := range data {
+		r <<= 4
+		switch {
+		case c >= '0' && c <= '9':
+			r |= rune(c - '0')
+		case c >= 'a' && c <= 'f':
+			r |= rune(c - 'a' + 10)
+		case c >= 'A' && c <= 'F':
+			r |= rune(c - 'A' + 10)
+		default:
+			return 0, false
+		}
+	}
+	return r, true
+}
+
+// isAbsoluteIRI checks for a scheme as in RFC 3987: ALPHA *( ALPHA / DIGIT / "+" / "-" / "." ) ":"
+func isAbsoluteIRI(iri []byte) bool {
+	for i, c := range iri {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case i > 0 && (c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.'):
+		case i > 0 && c == ':':
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// isDelimiter returns true for bytes that may directly follow a term.
+func isDelimiter(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '.' || c == '#' || c == ',' || c == ';'
+}
+
+// isLangTagChar implements LANGTAG ::= '@' [a-zA-Z]+ ('-' [a-zA-Z0-9]+)*
+func isLangTagChar(c byte, first bool) bool {
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+		return true
+	}
+	return !first && (c >= '0' && c <= '9' || c == '-')
+}
+
+// isPNCharsBase implements the PN_CHARS_BASE production.
+func isPNCharsBase(r rune) bool {
+	switch {
+	case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		return true
+	case r >= 0x00C0 && r <= 0x00D6, r >= 0x00D8 && r <= 0x00F6, r >= 0x00F8 && r <= 0x02FF,
+		r >= 0x0370 && r <= 0x037D, r >= 0x037F && r <= 0x1FFF, r >= 0x200C && r <= 0x200D,
+		r >= 0x2070 && r <= 0x218F, r >= 0x2C00 && r <= 0x2FEF, r >= 0x3001 && r <= 0xD7FF,
+		r >= 0xF900 && r <= 0xFDCF, r >= 0xFDF0 && r <= 0xFFFD, r >= 0x10000 && r <= 0xEFFFF:
+		return true
+	}
+	return false
+}
+
+// isPNCharsU implements PN_CHARS_U ::= PN_CHARS_BASE | '_' | ':'
+func isPNCharsU(r rune) bool {
+	return isPNCharsBase(r) || r == '_' || r == ':'
+}
+
+// isPNChars implements PN_CHARS ::= PN_CHARS_U | '-' | [0-9] | #x00B7 | [#x0300-#x036F] | [#x203F-#x2040]
+func isPNChars(r rune) bool {
+	return isPNCharsU(r) || r == '-' || (r >= '0' && r <= '9') || r == 0x00B7 ||
+		(r >= 0x0300 && r <= 0x036F) || (r >= 0x203F && r <= 0x2040)
+}
+
+// NewIRI creates an IRI term whose Raw field holds the N-Triples serialization.
+func NewIRI(iri string) Term {
+	return Term{Kind: IRI, Value: []byte(iri), Raw: appendIRI(nil, iri)}
+}
+
+// NewBlankNode creates a blank node term from its label (without `_:`).
+func NewBlankNode(label string) Term {
+	return Term{Kind: BlankNode, Value: []byte(label), Raw: []byte("_:" + label)}
+}
+
+// NewLiteral creates a literal term. At most one of lang and datatype should be given.
+func NewLiteral(value, lang, datatype string) Term {
+	term := Term{Kind: Literal, Value: []byte(value)}
+	raw := appendLiteral(nil, value)
+	if lang != "" {
+		term.Lang = []byte(lang)
+		raw = append(append(raw, '@'), lang...)
+	} else if datatype != "" {
+		term.Datatype = []byte(datatype)
+		raw = append(append(raw, "^^"...), appendIRI(nil, datatype)...)
+	}
+	term.Raw = raw
+	return term
+}
+
+// NewTriple assembles a triple from already created terms. Line is set to the N-Triples serialization.
+func NewTriple(subject, predicate, object Term) *Triple {
+	line := make([]byte, 0, len(subject.Raw)+len(predicate.Raw)+len(object.Raw)+4)
+	line = append(append(line, subject.Raw...), ' ')
+	line = append(append(line, predicate.Raw...), ' ')
+	line = append(append(line, object.Raw...), " ."...)
+	return &Triple{Subject: subject, Predicate: predicate, Object: object, Line: line}
+}
+
+// appendIRI writes `<iri>`, escaping the characters that are not allowed in an IRIREF.
+func appendIRI(out []byte, iri string) []byte {
+	out = append(out, '<')
+	for _, r := range iri {
+		if r <= 0x20 || r == '<' || r == '>' || r == '"' || r == '{' || r == '}' || r == '|' || r == '^' || r == '`' || r == '\\' {
+			out = append(out, fmt.Sprintf("\\u%04X", r)...)
+		} else {
+			out = utf8.AppendRune(out, r)
+		}
+	}
+	return append(out, '>')
+}
+
+// appendLiteral writes a quoted string, escaping quotes, backslashes and line breaks.
+func appendLiteral(out []byte, value string) []byte {
+	out = append(out, '"')
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '"':
+			out = append(out, `\"`...)
+		case '\\':
+			out = append(out, `\\`...)
+		case '\n':
+			out = append(out, `\n`...)
+		case '\r':
+			out = append(out, `\r`...)
+		default:
+			out = append(out, c)
+		}
+	}
+	return append(out, '"')
+}
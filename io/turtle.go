@@ -0,0 +1,846 @@
+package io
+
+// Streaming reader for the Turtle serialization as defined in https://www.w3.org/TR/turtle/.
+// Statements are parsed one at a time and their triples are handed out grouped by subject, so
+// that consumers relying on subject-sorted input see the triples of a statement contiguously.
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	rdfNS     = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xsdNS     = "http://www.w3.org/2001/XMLSchema#"
+	rdfType   = rdfNS + "type"
+	rdfFirst  = rdfNS + "first"
+	rdfRest   = rdfNS + "rest"
+	rdfNil    = rdfNS + "nil"
+	xsdString = xsdNS + "string"
+)
+
+// TurtleParser reads a Turtle file and produces triples from it.
+type TurtleParser struct {
+	Mode ParseMode // how malformed statements are treated, defaults to DefaultParseMode
+
+	reader   io.ReadCloser
+	scanner  *bufio.Reader
+	line     uint64
+	column   int
+	base     *url.URL
+	prefixes map[string]string
+	blankIDs uint64
+	pending  []*Triple // triples of the current statement that have not been returned yet
+	current  []*Triple // triples of the statement that is being parsed
+	subject  Term      // subject of the statement that is being parsed
+	skipped  uint64
+}
+
+// NewTurtleParser opens a file and returns a parser that will produce its triples.
+func NewTurtleParser(filePath string) (*TurtleParser, error) {
+	reader, err := UniversalReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewTurtleParserFromReader(reader), nil
+}
+
+// NewTurtleParserFromReader returns a Turtle parser that reads from an already opened stream. The parser
+// takes ownership of the reader and closes it on Close().
+func NewTurtleParserFromReader(reader io.ReadCloser) *TurtleParser {
+	return &TurtleParser{
+		Mode:     DefaultParseMode,
+		reader:   reader,
+		scanner:  bufio.NewReaderSize(reader, 4*1024*1024),
+		line:     1,
+		prefixes: map[string]string{},
+	}
+}
+
+// NextTriple returns the next triple of the file. At the end of the file a nil triple and nil error are returned.
+// The optional argument is accepted for compatibility with TripleParser and ignored.
+//
+// In strict mode the first malformed statement terminates the parsing with a *ParseError. In lenient
+// mode malformed statements are reported and skipped.
+func (tp *TurtleParser) NextTriple(argNumTokens ...int) (*Triple, error) {
+	for len(tp.pending) == 0 {
+		tp.skipWS()
+		if tp.atEOF() {
+			return nil, nil
+		}
+
+		tp.current = tp.current[:0]
+		tp.subject = Term{}
+		if err := tp.parseStatement(); err != nil {
+			perr, ok := err.(*ParseError)
+			if !ok || tp.Mode == Strict { // io errors are never skipped
+				return nil, err
+			}
+			tp.skipped++
+			warnSkipped(tp.skipped, "statement", perr)
+			tp.recover()
+			continue
+		}
+		tp.pending = groupBySubject(append([]*Triple(nil), tp.current...), tp.subject)
+	}
+
+	trip := tp.pending[0]
+	tp.pending = tp.pending[1:]
+	return trip, nil
+}
+
+// LineNumber returns the number of the line that is currently read.
+func (tp *TurtleParser) LineNumber() uint64 {
+	return tp.line
+}
+
+// SkippedStatements returns the number of malformed statements that have been skipped in lenient mode.
+func (tp *TurtleParser) SkippedStatements() uint64 {
+	return tp.skipped
+}
+
+// Close the handlers for the scanner and underlying file.
+func (tp *TurtleParser) Close() error {
+	return tp.reader.Close()
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+// character level access
+
+// peek returns the next byte without consuming it, 0 at the end of the input.
+func (tp *TurtleParser) peek() byte {
+	b, err := tp.scanner.Peek(1)
+	if err != nil {
+		return 0
+	}
+	return b[0]
+}
+
+// peekAt returns the byte at offset n without consuming anything, 0 if the input is shorter.
+func (tp *TurtleParser) peekAt(n int) byte {
+	b, err := tp.scanner.Peek(n + 1)
+	if err != nil || len(b) <= n {
+		return 0
+	}
+	return b[n]
+}
+
+// peekRuneAt decodes the rune starting at byte offset n.
+func (tp *TurtleParser) peekRuneAt(n int) (rune, int) {
+	b, _ := tp.scanner.Peek(n + utf8.UTFMax)
+	if len(b) <= n {
+		return 0, 0
+	}
+	return utf8.DecodeRune(b[n:])
+}
+
+func (tp *TurtleParser) atEOF() bool {
+	_, err := tp.scanner.Peek(1)
+	return err != nil
+}
+
+// next consumes a single byte.
+func (tp *TurtleParser) next() byte {
+	c, err := tp.scanner.ReadByte()
+	if err != nil {
+		return 0
+	}
+	if c == '\n' {
+		tp.line++
+		tp.column = 0
+	} else {
+		tp.column++
+	}
+	return c
+}
+
+// nextRune consumes a single rune.
+func (tp *TurtleParser) nextRune() rune {
+	r, w, err := tp.scanner.ReadRune()
+	if err != nil {
+		return 0
+	}
+	if r == '\n' {
+		tp.line++
+		tp.column = 0
+	} else {
+		tp.column += w
+	}
+	return r
+}
+
+func (tp *TurtleParser) errorf(format string, args ...interface{}) *ParseError {
+	return &ParseError{Line: tp.line, Column: tp.column + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+// skipWS advances over whitespace and comments.
+func (tp *TurtleParser) skipWS() {
+	for {
+		switch tp.peek() {
+		case ' ', '\t', '\r', '\n':
+			tp.next()
+		case '#':
+			for c := tp.peek(); c != '\n' && !(c == 0 && tp.atEOF()); c = tp.peek() {
+				tp.next()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// expect consumes the given byte after optional whitespace.
+func (tp *TurtleParser) expect(c byte) error {
+	tp.skipWS()
+	if tp.peek() != c {
+		return tp.unexpected(fmt.Sprintf("'%c'", c))
+	}
+	tp.next()
+	return nil
+}
+
+func (tp *TurtleParser) unexpected(expected string) *ParseError {
+	if tp.atEOF() {
+		return tp.errorf("expected %v, found end of file", expected)
+	}
+	r, _ := tp.peekRuneAt(0)
+	return tp.errorf("expected %v, found %q", expected, r)
+}
+
+// recover skips input up to the end of the current statement after an error.
+func (tp *TurtleParser) recover() {
+	for !tp.atEOF() {
+		c := tp.next()
+		if c == '.' {
+			if n := tp.peek(); n == ' ' || n == '\t' || n == '\r' || n == '\n' || n == '#' || tp.atEOF() {
+				return
+			}
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+// grammar
+
+// parseStatement reads a directive or a block of triples including the terminating dot.
+func (tp *TurtleParser) parseStatement() error {
+	switch c := tp.peek(); {
+	case c == '@':
+		return tp.parseAtDirective()
+	case c == 'P' || c == 'p' || c == 'B' || c == 'b':
+		if keyword := tp.peekKeyword(); strings.EqualFold(keyword, "PREFIX") || strings.EqualFold(keyword, "BASE") {
+			return tp.parseSparqlDirective(keyword)
+		}
+	}
+	return tp.parseTriples()
+}
+
+// peekKeyword returns the run of ASCII letters at the current position if it is followed by whitespace.
+func (tp *TurtleParser) peekKeyword() string {
+	n := 0
+	for c := tp.peekAt(n); c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'; c = tp.peekAt(n) {
+		n++
+	}
+	if c := tp.peekAt(n); c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+		return ""
+	}
+	b, _ := tp.scanner.Peek(n)
+	return string(b)
+}
+
+func (tp *TurtleParser) parseAtDirective() error {
+	tp.next() // @
+	keyword := tp.peekKeyword()
+	for range keyword {
+		tp.next()
+	}
+	switch keyword {
+	case "prefix":
+		if err := tp.parsePrefixDecl(); err != nil {
+			return err
+		}
+	case "base":
+		if err := tp.parseBaseDecl(); err != nil {
+			return err
+		}
+	default:
+		return tp.errorf("unknown directive '@%v'", keyword)
+	}
+	return tp.expect('.')
+}
+
+func (tp *TurtleParser) parseSparqlDirective(keyword string) error {
+	for range keyword {
+		tp.next()
+	}
+	if strings.EqualFold(keyword, "PREFIX") {
+		return tp.parsePrefixDecl()
+	}
+	return tp.parseBaseDecl()
+}
+
+func (tp *TurtleParser) parsePrefixDecl() error {
+	tp.skipWS()
+	prefix, local, err := tp.readPrefixedName()
+	if err != nil {
+		return err
+	}
+	if local != "" {
+		return tp.errorf("expected prefix declaration, found prefixed name '%v:%v'", prefix, local)
+	}
+	tp.skipWS()
+	if tp.peek() != '<' {
+		return tp.unexpected("IRI")
+	}
+	iri, err := tp.readIRIRef()
+	if err != nil {
+		return err
+	}
+	tp.prefixes[prefix] = iri
+	return nil
+}
+
+func (tp *TurtleParser) parseBaseDecl() error {
+	tp.skipWS()
+	if tp.peek() != '<' {
+		return tp.unexpected("IRI")
+	}
+	iri, err := tp.readIRIRef()
+	if err != nil {
+		return err
+	}
+	base, err := url.Parse(iri)
+	if err != nil {
+		return tp.errorf("invalid base IRI <%v>", iri)
+	}
+	tp.base = base
+	return nil
+}
+
+func (tp *TurtleParser) parseTriples() error {
+	if tp.peek() == '[' {
+		tp.subject = tp.peekBlankNode()
+		subject, err := tp.parseBlankNodePropertyList()
+		if err != nil {
+			return err
+		}
+		tp.skipWS()
+		if tp.peek() != '.' { // the predicate object list is optional for this form
+			if err := tp.parsePredicateObjectList(subject); err != nil {
+				return err
+			}
+		}
+	} else {
+		subject, err := tp.parseSubject()
+		if err != nil {
+			return err
+		}
+		tp.subject = subject
+		if err := tp.parsePredicateObjectList(subject); err != nil {
+			return err
+		}
+	}
+	return tp.expect('.')
+}
+
+func (tp *TurtleParser) parseSubject() (Term, error) {
+	tp.skipWS()
+	switch tp.peek() {
+	case '<':
+		iri, err := tp.readIRIRef()
+		return NewIRI(iri), err
+	case '_':
+		return tp.parseBlankNodeLabel()
+	case '(':
+		return tp.parseCollection()
+	case '"', '\'':
+		return Term{}, tp.errorf("literals are not allowed as subject")
+	}
+	return tp.parsePrefixedIRI()
+}
+
+func (tp *TurtleParser) parsePredicateObjectList(subject Term) error {
+	for {
+		predicate, err := tp.parseVerb()
+		if err != nil {
+			return err
+		}
+		if err = tp.parseObjectList(subject, predicate); err != nil {
+			return err
+		}
+
+		// `;` continues with another predicate, several semicolons in a row are allowed
+		tp.skipWS()
+		if tp.peek() != ';' {
+			return nil
+		}
+		for tp.peek() == ';' {
+			tp.next()
+			tp.skipWS()
+		}
+		if c := tp.peek(); c == '.' || c == ']' || tp.atEOF() {
+			return nil
+		}
+	}
+}
+
+func (tp *TurtleParser) parseVerb() (Term, error) {
+	tp.skipWS()
+	if tp.peek() == 'a' {
+		if r, _ := tp.peekRuneAt(1); !isPNChars(r) && r != '.' && r != ':' {
+			tp.next()
+			return NewIRI(rdfType), nil
+		}
+	}
+	if tp.peek() == '<' {
+		iri, err := tp.readIRIRef()
+		return NewIRI(iri), err
+	}
+	return tp.parsePrefixedIRI()
+}
+
+func (tp *TurtleParser) parseObjectList(subject, predicate Term) error {
+	for {
+		object, err := tp.parseObject()
+		if err != nil {
+			return err
+		}
+		tp.emit(subject, predicate, object)
+
+		tp.skipWS()
+		if tp.peek() != ',' {
+			return nil
+		}
+		tp.next()
+	}
+}
+
+func (tp *TurtleParser) parseObject() (Term, error) {
+	tp.skipWS()
+	switch c := tp.peek(); {
+	case c == '<':
+		iri, err := tp.readIRIRef()
+		return NewIRI(iri), err
+	case c == '_':
+		return tp.parseBlankNodeLabel()
+	case c == '[':
+		return tp.parseBlankNodePropertyList()
+	case c == '(':
+		return tp.parseCollection()
+	case c == '"' || c == '\'':
+		return tp.parseRDFLiteral()
+	case c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.' && tp.peekAt(1) >= '0' && tp.peekAt(1) <= '9':
+		return tp.parseNumericLiteral()
+	}
+
+	if keyword := tp.peekBoolean(); keyword != "" {
+		for range keyword {
+			tp.next()
+		}
+		return NewLiteral(keyword, "", xsdNS+"boolean"), nil
+	}
+	return tp.parsePrefixedIRI()
+}
+
+// peekBoolean returns `true` or `false` if one of them is the next complete token.
+func (tp *TurtleParser) peekBoolean() string {
+	for _, keyword := range []string{"true", "false"} {
+		b, _ := tp.scanner.Peek(len(keyword))
+		if string(b) != keyword {
+			continue
+		}
+		if r, _ := tp.peekRuneAt(len(keyword)); !isPNChars(r) && r != '.' && r != ':' || tp.peekAt(len(keyword)) == '.' {
+			return keyword
+		}
+	}
+	return ""
+}
+
+func (tp *TurtleParser) parseBlankNodePropertyList() (Term, error) {
+	tp.next() // [
+	node := tp.newBlankNode()
+	tp.skipWS()
+	if tp.peek() == ']' { // anonymous blank node
+		tp.next()
+		return node, nil
+	}
+	if err := tp.parsePredicateObjectList(node); err != nil {
+		return Term{}, err
+	}
+	return node, tp.expect(']')
+}
+
+func (tp *TurtleParser) parseCollection() (Term, error) {
+	tp.next() // (
+	head := NewIRI(rdfNil)
+	var last Term
+	for {
+		tp.skipWS()
+		if tp.peek() == ')' {
+			tp.next()
+			if last.Kind != 0 {
+				tp.emit(last, NewIRI(rdfRest), NewIRI(rdfNil))
+			}
+			return head, nil
+		}
+		if tp.atEOF() {
+			return Term{}, tp.unexpected("')'")
+		}
+
+		node := tp.newBlankNode()
+		if last.Kind == 0 {
+			head = node
+		} else {
+			tp.emit(last, NewIRI(rdfRest), node)
+		}
+		object, err := tp.parseObject()
+		if err != nil {
+			return Term{}, err
+		}
+		tp.emit(node, NewIRI(rdfFirst), object)
+		last = node
+	}
+}
+
+// peekBlankNode returns the blank node that newBlankNode will create next.
+func (tp *TurtleParser) peekBlankNode() Term {
+	return NewBlankNode("genid" + strconv.FormatUint(tp.blankIDs+1, 10))
+}
+
+func (tp *TurtleParser) newBlankNode() Term {
+	tp.blankIDs++
+	return NewBlankNode("genid" + strconv.FormatUint(tp.blankIDs, 10))
+}
+
+func (tp *TurtleParser) emit(subject, predicate, object Term) {
+	tp.current = append(tp.current, NewTriple(subject, predicate, object))
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+// terminals
+
+// readIRIRef reads `<...>` and resolves it against the base IRI.
+func (tp *TurtleParser) readIRIRef() (string, error) {
+	tp.next() // <
+	var value []byte
+	hasEscapes := false
+	for {
+		if tp.atEOF() {
+			return "", tp.errorf("unterminated IRI")
+		}
+		c := tp.peek()
+		if c == '>' {
+			tp.next()
+			break
+		}
+		if c == '\\' {
+			hasEscapes = true
+		} else if c == '\n' || c == '<' || tp.Mode == Strict && (c <= 0x20 || bytes.IndexByte([]byte("\"{}|^`"), c) >= 0) {
+			return "", tp.errorf("invalid character %q in IRI", rune(c))
+		}
+		value = append(value, tp.next())
+	}
+
+	if hasEscapes {
+		decoded, errPos := decodeEscapes(value, false)
+		if errPos >= 0 {
+			if tp.Mode == Strict {
+				return "", tp.errorf("invalid unicode escape in IRI")
+			}
+			decoded = value
+		}
+		value = decoded
+	}
+	return tp.resolve(string(value)), nil
+}
+
+// resolve makes an IRI absolute with respect to the base IRI of the document.
+func (tp *TurtleParser) resolve(iri string) string {
+	if tp.base == nil || isAbsoluteIRI([]byte(iri)) {
+		return iri
+	}
+	ref, err := url.Parse(iri)
+	if err != nil {
+		return tp.base.String() + iri
+	}
+	return tp.base.ResolveReference(ref).String()
+}
+
+func (tp *TurtleParser) parsePrefixedIRI() (Term, error) {
+	prefix, local, err := tp.readPrefixedName()
+	if err != nil {
+		return Term{}, err
+	}
+	ns, ok := tp.prefixes[prefix]
+	if !ok {
+		return Term{}, tp.errorf("undefined prefix '%v:'", prefix)
+	}
+	return NewIRI(ns + local), nil
+}
+
+// readPrefixedName reads PNAME_NS or PNAME_LN and returns the prefix and the unescaped local part.
+func (tp *TurtleParser) readPrefixedName() (prefix, local string, err error) {
+	var name []byte
+
+	// prefix part: PN_PREFIX? ':'
+	for {
+		r, w := tp.peekRuneAt(0)
+		if r == ':' {
+			tp.next()
+			break
+		}
+		if w == 0 || !(isPNChars(r) || r == '.' && len(name) > 0 && tp.continuesName(1)) {
+			return "", "", tp.unexpected("IRI, prefixed name or blank node")
+		}
+		name = utf8.AppendRune(name, tp.nextRune())
+	}
+	prefix = string(name)
+
+	// local part: PN_LOCAL, which may contain escapes and percent encodings
+	name = name[:0]
+	for {
+		r, w := tp.peekRuneAt(0)
+		switch {
+		case w == 0:
+		case r == '\\':
+			esc := tp.peekAt(1)
+			if esc == 0 || strings.IndexByte("_~.-!$&'()*+,;=/?#@%", esc) < 0 {
+				return "", "", tp.errorf("invalid escape in local name")
+			}
+			tp.next()
+			name = append(name, tp.next())
+			continue
+		case r == '%':
+			name = append(name, tp.next())
+			continue
+		case r == '.':
+			if tp.continuesName(1) {
+				name = append(name, tp.next())
+				continue
+			}
+		case isPNChars(r):
+			name = utf8.AppendRune(name, tp.nextRune())
+			continue
+		}
+		return prefix, string(name), nil
+	}
+}
+
+// continuesName checks if the rune at byte offset n can continue a name, which decides whether a dot
+// belongs to the name or terminates the statement.
+func (tp *TurtleParser) continuesName(n int) bool {
+	r, w := tp.peekRuneAt(n)
+	return w > 0 && (isPNChars(r) || r == '.' || r == '%' || r == '\\')
+}
+
+func (tp *TurtleParser) parseBlankNodeLabel() (Term, error) {
+	if tp.peekAt(1) != ':' {
+		return Term{}, tp.unexpected("'_:'")
+	}
+	tp.next()
+	tp.next()
+
+	var label []byte
+	for {
+		r, w := tp.peekRuneAt(0)
+		if w == 0 {
+			break
+		}
+		if r == '.' && tp.continuesName(1) || r != ':' && isPNChars(r) {
+			label = utf8.AppendRune(label, tp.nextRune())
+			continue
+		}
+		break
+	}
+	if len(label) == 0 {
+		return Term{}, tp.errorf("empty blank node label")
+	}
+	return NewBlankNode(string(label)), nil
+}
+
+func (tp *TurtleParser) parseRDFLiteral() (Term, error) {
+	value, err := tp.readString()
+	if err != nil {
+		return Term{}, err
+	}
+
+	// annotations
+	switch {
+	case tp.peek() == '@':
+		tp.next()
+		var lang []byte
+		for c := tp.peek(); isLangTagChar(c, len(lang) == 0); c = tp.peek() {
+			lang = append(lang, tp.next())
+		}
+		if len(lang) == 0 || lang[len(lang)-1] == '-' {
+			return Term{}, tp.errorf("invalid language tag")
+		}
+		return NewLiteral(value, string(lang), ""), nil
+	case tp.peek() == '^' && tp.peekAt(1) == '^':
+		tp.next()
+		tp.next()
+		var datatype string
+		if tp.peek() == '<' {
+			if datatype, err = tp.readIRIRef(); err != nil {
+				return Term{}, err
+			}
+		} else {
+			dt, err := tp.parsePrefixedIRI()
+			if err != nil {
+				return Term{}, err
+			}
+			datatype = string(dt.Value)
+		}
+		if datatype == xsdString { // plain literals and xsd:string are the same in RDF 1.1
+			datatype = ""
+		}
+		return NewLiteral(value, "", datatype), nil
+	}
+	return NewLiteral(value, "", ""), nil
+}
+
+// readString reads any of the four quoted string forms and decodes its escapes.
+func (tp *TurtleParser) readString() (string, error) {
+	quote := tp.next()
+	long := tp.peek() == quote && tp.peekAt(1) == quote
+	if long {
+		tp.next()
+		tp.next()
+	}
+
+	var value []byte
+	hasEscapes := false
+	for {
+		if tp.atEOF() {
+			return "", tp.errorf("unterminated string")
+		}
+		c := tp.peek()
+		if c == quote {
+			if !long {
+				tp.next()
+				break
+			}
+			run := 1
+			for tp.peekAt(run) == quote {
+				run++
+			}
+			if run >= 3 {
+				// a long string may end with quotes of its own right before the closing delimiter
+				for ; run > 3; run-- {
+					value = append(value, tp.next())
+				}
+				tp.next()
+				tp.next()
+				tp.next()
+				break
+			}
+		} else if c == '\\' {
+			hasEscapes = true
+			value = append(value, tp.next())
+			if tp.atEOF() {
+				return "", tp.errorf("unterminated string")
+			}
+		} else if !long && (c == '\n' || c == '\r') {
+			return "", tp.errorf("unescaped line break in string")
+		}
+		value = append(value, tp.next())
+	}
+
+	if hasEscapes {
+		decoded, errPos := decodeEscapes(value, true)
+		if errPos >= 0 {
+			if tp.Mode == Strict {
+				return "", tp.errorf("invalid escape sequence in string")
+			}
+			decoded = value
+		}
+		value = decoded
+	}
+	return string(value), nil
+}
+
+// parseNumericLiteral reads an integer, decimal or double in their abbreviated forms.
+func (tp *TurtleParser) parseNumericLiteral() (Term, error) {
+	var value []byte
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+
+	if c := tp.peek(); c == '+' || c == '-' {
+		value = append(value, tp.next())
+	}
+	digits := 0
+	for isDigit(tp.peek()) {
+		value = append(value, tp.next())
+		digits++
+	}
+	datatype := xsdNS + "integer"
+	if tp.peek() == '.' && isDigit(tp.peekAt(1)) {
+		datatype = xsdNS + "decimal"
+		value = append(value, tp.next())
+		for isDigit(tp.peek()) {
+			value = append(value, tp.next())
+			digits++
+		}
+	}
+	if c := tp.peek(); (c == 'e' || c == 'E') && digits > 0 {
+		datatype = xsdNS + "double"
+		value = append(value, tp.next())
+		if c := tp.peek(); c == '+' || c == '-' {
+			value = append(value, tp.next())
+		}
+		if !isDigit(tp.peek()) {
+			return Term{}, tp.errorf("missing exponent in number")
+		}
+		for isDigit(tp.peek()) {
+			value = append(value, tp.next())
+		}
+	}
+	if digits == 0 {
+		return Term{}, tp.errorf("invalid number '%s'", value)
+	}
+	return NewLiteral(string(value), "", datatype), nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+// helpers shared by the statement-based readers
+
+// groupBySubject reorders the triples so that all triples of a subject are adjacent. The triples of the
+// given main subject come first, then the other subjects in order of appearance. The order of triples
+// with the same subject is kept.
+func groupBySubject(trips []*Triple, main Term) []*Triple {
+	order := map[string]int{string(main.Raw): 0}
+	buckets := make([][]*Triple, 1, 2)
+	for _, trip := range trips {
+		key := string(trip.Subject.Raw)
+		idx, ok := order[key]
+		if !ok {
+			idx = len(buckets)
+			order[key] = idx
+			buckets = append(buckets, nil)
+		}
+		buckets[idx] = append(buckets[idx], trip)
+	}
+	if len(buckets) == 1 || len(buckets) == 2 && len(buckets[0]) == 0 {
+		return trips
+	}
+	grouped := trips[:0]
+	for _, bucket := range buckets {
+		grouped = append(grouped, bucket...)
+	}
+	return grouped
+}
+
+// warnSkipped logs a skipped piece of malformed input, suppressing the reports once the limit is reached.
+func warnSkipped(skipped uint64, what string, err error) {
+	if skipped <= maxLenientWarnings {
+		log.Printf("Skipping malformed %v: %v\n", what, err)
+	} else if skipped == maxLenientWarnings+1 {
+		log.Printf("Skipped more than %v malformed %vs, further warnings are suppressed\n", maxLenientWarnings, what)
+	}
+}
@@ -2,16 +2,26 @@ package main
 
 import (
 	"bufio"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"recommender/assessment"
 	"recommender/configuration"
 	"recommender/glossary"
+	recIO "recommender/io"
 	"recommender/preparation"
 	"recommender/schematree"
 	"recommender/server"
+	"recommender/shapes"
 	"recommender/strategy"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"runtime"
@@ -29,16 +39,127 @@ func main() {
 	// Setup the variables where all flags will reside.
 	var cpuprofile, memprofile, traceFile string // used globally
 	var measureTime bool                         // used globally
-	var firstNsubjects int64                     // used by build-tree
+	var strictParsing bool                       // used globally
+	var inputFormat string                       // used by build-tree, build-glossary
+	var inputGraphs []string                     // used by build-tree, build-glossary
+	var unsortedInput bool                       // used by build-tree
+	var groupPartitions int                      // used by build-tree
+	var groupMemory int                          // used by build-tree
+	var tempDir string                           // used by build-tree
+	var compactLayout bool                       // used by build-tree, serve
+	var updateOutput string                      // used by update-tree
+	var rebalanceThreshold float64               // used by update-tree
+	var forceRebalance bool                      // used by update-tree
+	var flatOutput string                        // used by flatten-tree
+	var minSupport uint64                        // used by build-tree, prune-tree, mine-rules
+	var pruneOutput string                       // used by prune-tree
+	var sampleDataset string                     // used by prune-tree
+	var sampleSize uint64                        // used by prune-tree
+	var constructionShards int                   // used by build-tree
+	var mergeOutput string                       // used by merge-trees
+	var maxSetSize int                           // used by mine-rules
+	var minConfidence float64                    // used by mine-rules
+	var minLift float64                          // used by mine-rules
+	var rulesFormat string                       // used by mine-rules
+	var rulesOutput string                       // used by mine-rules
+	var minTypeSupport uint64                    // used by generate-shapes
+	var minProbability float64                   // used by generate-shapes
+	var minShare float64                         // used by generate-shapes
+	var noCounts bool                            // used by generate-shapes
+	var noDatatypes bool                         // used by generate-shapes
+	var shapeNamespace string                    // used by generate-shapes
+	var shapesOutput string                      // used by generate-shapes
+	var expectedProbability float64              // used by validate
+	var surprisingProbability float64            // used by validate
+	var multiplicityShare float64                // used by validate
+	var reportAll bool                           // used by validate
+	var validateOutput string                    // used by validate
+	var anomalyTop int                           // used by score-anomalies
+	var anomalyContributors int                  // used by score-anomalies
+	var anomalyOutput string                     // used by score-anomalies
+	var buildConfigFile string                   // used by build-tree
+	var typePredicates []string                  // used by build-tree
+	var includePrefixes []string                 // used by build-tree
+	var includePatterns []string                 // used by build-tree
+	var excludePrefixes []string                 // used by build-tree
+	var excludePatterns []string                 // used by build-tree
+	var inverseProperties bool                   // used by build-tree
+	var valuePredicates []string                 // used by build-tree
+	var firstNsubjects int64                     // used by build-tree, validate, score-anomalies, recommend-file
 	var writeOutPropertyFreqs bool               // used by build-tree
 	var serveOnPort int                          // used by serve
-	var workflowFile string                      // used by serve
+	var workflowFile string                      // used by serve, recommend-file
+	var recommendTop int                         // used by recommend-file
+	var recommendFormat string                   // used by recommend-file
+	var recommendOutput string                   // used by recommend-file
+	var cacheSize int                            // used by serve
+	var verifyModel bool                         // used by serve
+	var warmUpLog string                         // used by serve
 	var contiguousInput bool                     // used by split-dataset:by-type
 	var everyNthSubject uint                     // used by split-dataset:1-in-n
 
 	// Setup helper variables
 	var timeCheckpoint time.Time // used globally
 
+	// selectInputFormat configures the readers of the io module from the input flags.
+	selectInputFormat := func() {
+		format, err := recIO.ParseFormat(inputFormat)
+		if err != nil {
+			log.Fatal(err)
+		}
+		recIO.DefaultFormat = format
+		recIO.DefaultGraphs = inputGraphs
+		recIO.GroupUnsorted = unsortedInput
+		recIO.GroupPartitions = groupPartitions
+		if groupMemory > 0 {
+			recIO.GroupMemory = int64(groupMemory) << 20
+		}
+		recIO.TempDir = tempDir
+	}
+	// selectBuildConfig sets the type predicates and predicate filters of new trees from the build flags.
+	// The filters given as flags take precedence over those of the configuration file.
+	selectBuildConfig := func() {
+		config := schematree.NewBuildConfig()
+		if buildConfigFile != "" {
+			var err error
+			if config, err = schematree.LoadBuildConfig(buildConfigFile); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if len(typePredicates) > 0 {
+			config.TypePredicates = typePredicates
+		}
+		var rules []schematree.PredicateRule
+		for _, prefix := range includePrefixes {
+			rules = append(rules, schematree.PredicateRule{Prefix: prefix})
+		}
+		for _, pattern := range includePatterns {
+			rules = append(rules, schematree.PredicateRule{Pattern: pattern})
+		}
+		for _, prefix := range excludePrefixes {
+			rules = append(rules, schematree.PredicateRule{Exclude: true, Prefix: prefix})
+		}
+		for _, pattern := range excludePatterns {
+			rules = append(rules, schematree.PredicateRule{Exclude: true, Pattern: pattern})
+		}
+		config.Predicates = append(rules, config.Predicates...)
+		if inverseProperties {
+			config.InverseProperties = true
+		}
+		if len(valuePredicates) > 0 {
+			config.ValuePredicates = valuePredicates
+		}
+		if err := config.Validate(); err != nil {
+			log.Fatal(err)
+		}
+		schematree.DefaultBuildConfig = config
+	}
+
+	inputFormatUsage := "`format` of the dataset, one of: " + strings.Join(recIO.FormatNames(), ", ") +
+		" (default: detected from the file extension, N-Triples if unknown)"
+	inputGraphsUsage := "only read quads of the given `graphs` (IRIs, blank nodes or '" + recIO.DefaultGraph +
+		"' for the default graph); applies to N-Quads"
+
 	// writeOutPropertyFreqs := flag.Bool("writeOutPropertyFreqs", false, "set this to write the frequency of all properties to a csv after first pass or schematree loading")
 
 	// root command
@@ -70,6 +191,11 @@ func main() {
 				}
 			}
 
+			// select how malformed N-Triples are treated by all parsers
+			if strictParsing {
+				recIO.DefaultParseMode = recIO.Strict
+			}
+
 			// measure time - start measuring the time
 			//   The measurements are done in such a way to not include the time for the profiles operations.
 			if measureTime == true {
@@ -117,22 +243,28 @@ func main() {
 	cmdRoot.PersistentFlags().StringVar(&memprofile, "memprofile", "", "write memory profile to `file`")
 	cmdRoot.PersistentFlags().StringVar(&traceFile, "trace", "", "write execution trace to `file`")
 	cmdRoot.PersistentFlags().BoolVarP(&measureTime, "time", "t", false, "measure time of command execution")
+	cmdRoot.PersistentFlags().BoolVar(&strictParsing, "strict", false, "abort on the first malformed triple or statement instead of skipping it")
 
 	// subcommand build-tree
 	cmdBuildTree := &cobra.Command{
 		Use:   "build-tree <dataset>",
 		Short: "Build the SchemaTree model",
 		Long: "A SchemaTree model will be built using the file provided in <dataset>." +
-			" The dataset should be a N-Triple of Items.\nTwo output files will be" +
+			" The dataset should be a N-Triple of Items, other RDF formats are selected with --format.\nTwo output files will be" +
 			" generated in the same directory as <dataset> and with suffixed names, namely:" +
 			" '<dataset>.firstPass.bin' and '<dataset>.schemaTree.bin'",
 		Args: cobra.ExactArgs(1),
 
 		Run: func(cmd *cobra.Command, args []string) {
 			inputDataset := &args[0]
+			selectInputFormat()
+			selectBuildConfig()
+			defer recIO.RemoveGroupedFiles()
+			schematree.CompactLayout = compactLayout
+			schematree.ConstructionShards = constructionShards
 
 			// Create the tree output file by using the input dataset.
-			schema, err := schematree.Create(*inputDataset, uint64(firstNsubjects), false, 0)
+			schema, err := schematree.Create(*inputDataset, uint64(firstNsubjects), false, minSupport)
 			if err != nil {
 				log.Panicln(err)
 			}
@@ -152,22 +284,44 @@ func main() {
 		&writeOutPropertyFreqs, "write-frequencies", "f", false,
 		"write all property frequencies to a csv file named '<dataset>.propertyFreqs.csv' after the SchemaTree is built",
 	)
+	cmdBuildTree.Flags().StringVar(&inputFormat, "format", "", inputFormatUsage)
+	cmdBuildTree.Flags().StringSliceVar(&inputGraphs, "graph", nil, inputGraphsUsage)
+	cmdBuildTree.Flags().BoolVar(&unsortedInput, "unsorted", false, "group the triples by subject on disk before building, for input that is not sorted by subject")
+	cmdBuildTree.Flags().IntVar(&groupPartitions, "partitions", 64, "number of on-disk `partitions` used with --unsorted, only one partition is held in memory at a time")
+	cmdBuildTree.Flags().IntVar(&groupMemory, "grouping-memory", 1024, "`MiB` of triples of a partition that are grouped in memory, larger partitions are split further (about twice as much memory is used)")
+	cmdBuildTree.Flags().StringVar(&tempDir, "temp-dir", "", "`directory` for the temporary files of --unsorted and --inverse (default: the directory of the operating system)")
+	cmdBuildTree.Flags().BoolVar(&compactLayout, "compact", false, "build the tree in the compact struct-of-arrays layout, which needs a fraction of the memory")
+	cmdBuildTree.Flags().Uint64Var(&minSupport, "min-support", 1, "prune all nodes with a support below `n` and leave out properties that occur less often")
+	cmdBuildTree.Flags().IntVar(&constructionShards, "shards", 0, "read the dataset once and build `n` independent sub-trees in parallel that are merged afterwards (0: two passes over the dataset)")
+	cmdBuildTree.Flags().StringVar(&buildConfigFile, "config", "", "JSON `file` with the type predicates, type rules and predicate filters, see the schematree README")
+	cmdBuildTree.Flags().StringSliceVar(&typePredicates, "type-predicate", nil, "`predicates` whose objects are the types of a subject (default: wikidata P31, rdf:type, dbpedia-owl:type)")
+	cmdBuildTree.Flags().StringSliceVar(&includePrefixes, "include-prefix", nil, "read predicates starting with one of these `prefixes`, even if they are excluded otherwise")
+	cmdBuildTree.Flags().StringSliceVar(&includePatterns, "include-pattern", nil, "read predicates matching one of these regular `expressions`, even if they are excluded otherwise")
+	cmdBuildTree.Flags().StringSliceVar(&excludePrefixes, "exclude-prefix", nil, "skip predicates starting with one of these `prefixes`")
+	cmdBuildTree.Flags().StringSliceVar(&excludePatterns, "exclude-pattern", nil, "skip predicates matching one of these regular `expressions`, e.g. '.*' together with --include-prefix")
+	cmdBuildTree.Flags().BoolVar(&inverseProperties, "inverse", false, "also add inverse items '^<predicate>' for the incoming relations of the subjects (the input is grouped on disk)")
+	cmdBuildTree.Flags().StringSliceVar(&valuePredicates, "value-predicate", nil, "`predicates` whose values become property=value items 'v#<predicate>=<value>', so that values can be recommended")
 
 	// subcommand build-tree
 	cmdBuildTreeTyped := &cobra.Command{
 		Use:   "build-tree-typed <dataset>",
 		Short: "Build the SchemaTree model with types",
 		Long: "A SchemaTree model will be built using the file provided in <dataset>." +
-			" The dataset should be a N-Triple of Items.\nTwo output files will be" +
+			" The dataset should be a N-Triple of Items, other RDF formats are selected with --format.\nTwo output files will be" +
 			" generated in the same directory as <dataset> and with suffixed names, namely:" +
 			" '<dataset>.firstPass.bin' and '<dataset>.schemaTree.typed.bin'",
 		Args: cobra.ExactArgs(1),
 
 		Run: func(cmd *cobra.Command, args []string) {
 			inputDataset := &args[0]
+			selectInputFormat()
+			selectBuildConfig()
+			defer recIO.RemoveGroupedFiles()
+			schematree.CompactLayout = compactLayout
+			schematree.ConstructionShards = constructionShards
 
 			// Create the tree output file by using the input dataset.
-			schema, err := schematree.Create(*inputDataset, uint64(firstNsubjects), true, 0)
+			schema, err := schematree.Create(*inputDataset, uint64(firstNsubjects), true, minSupport)
 			if err != nil {
 				log.Panicln(err)
 			}
@@ -191,19 +345,37 @@ func main() {
 		&writeOutPropertyFreqs, "write-frequencies", "f", false,
 		"write all property frequencies to a csv file named '<dataset>.propertyFreqs.csv' after the SchemaTree is built",
 	)
+	cmdBuildTreeTyped.Flags().StringVar(&inputFormat, "format", "", inputFormatUsage)
+	cmdBuildTreeTyped.Flags().StringSliceVar(&inputGraphs, "graph", nil, inputGraphsUsage)
+	cmdBuildTreeTyped.Flags().BoolVar(&unsortedInput, "unsorted", false, "group the triples by subject on disk before building, for input that is not sorted by subject")
+	cmdBuildTreeTyped.Flags().IntVar(&groupPartitions, "partitions", 64, "number of on-disk `partitions` used with --unsorted, only one partition is held in memory at a time")
+	cmdBuildTreeTyped.Flags().IntVar(&groupMemory, "grouping-memory", 1024, "`MiB` of triples of a partition that are grouped in memory, larger partitions are split further (about twice as much memory is used)")
+	cmdBuildTreeTyped.Flags().StringVar(&tempDir, "temp-dir", "", "`directory` for the temporary files of --unsorted and --inverse (default: the directory of the operating system)")
+	cmdBuildTreeTyped.Flags().BoolVar(&compactLayout, "compact", false, "build the tree in the compact struct-of-arrays layout, which needs a fraction of the memory")
+	cmdBuildTreeTyped.Flags().Uint64Var(&minSupport, "min-support", 1, "prune all nodes with a support below `n` and leave out properties that occur less often")
+	cmdBuildTreeTyped.Flags().IntVar(&constructionShards, "shards", 0, "read the dataset once and build `n` independent sub-trees in parallel that are merged afterwards (0: two passes over the dataset)")
+	cmdBuildTreeTyped.Flags().StringVar(&buildConfigFile, "config", "", "JSON `file` with the type predicates, type rules and predicate filters, see the schematree README")
+	cmdBuildTreeTyped.Flags().StringSliceVar(&typePredicates, "type-predicate", nil, "`predicates` whose objects are the types of a subject (default: wikidata P31, rdf:type, dbpedia-owl:type)")
+	cmdBuildTreeTyped.Flags().StringSliceVar(&includePrefixes, "include-prefix", nil, "read predicates starting with one of these `prefixes`, even if they are excluded otherwise")
+	cmdBuildTreeTyped.Flags().StringSliceVar(&includePatterns, "include-pattern", nil, "read predicates matching one of these regular `expressions`, even if they are excluded otherwise")
+	cmdBuildTreeTyped.Flags().StringSliceVar(&excludePrefixes, "exclude-prefix", nil, "skip predicates starting with one of these `prefixes`")
+	cmdBuildTreeTyped.Flags().StringSliceVar(&excludePatterns, "exclude-pattern", nil, "skip predicates matching one of these regular `expressions`, e.g. '.*' together with --include-prefix")
+	cmdBuildTreeTyped.Flags().BoolVar(&inverseProperties, "inverse", false, "also add inverse items '^<predicate>' for the incoming relations of the subjects (the input is grouped on disk)")
+	cmdBuildTreeTyped.Flags().StringSliceVar(&valuePredicates, "value-predicate", nil, "`predicates` whose values become property=value items 'v#<predicate>=<value>', so that values can be recommended")
 
 	// subcommand build-glossary
 	cmdBuildGlossary := &cobra.Command{
 		Use:   "build-glossary <dataset>",
 		Short: "Build the Glossary that maps properties to multi-lingual descriptions",
 		Long: "A Glossary will be built using the file provided in <dataset>. The input" +
-			" file should be a N-Triple of Property entries.\nThe output file will be" +
+			" file should be a N-Triple of Property entries, other RDF formats are selected with --format.\nThe output file will be" +
 			" generated in the same directory as <dataset> with the name:" +
 			" '<dataset>.glossary.bin'",
 		Args: cobra.ExactArgs(1),
 
 		Run: func(cmd *cobra.Command, args []string) {
 			inputDataset := &args[0]
+			selectInputFormat()
 
 			// Build the glossary
 			glos, stats, err := glossary.BuildGlossary(*inputDataset)
@@ -217,23 +389,505 @@ func main() {
 			//glos.OutputStats()
 		},
 	}
+	cmdBuildGlossary.Flags().StringVar(&inputFormat, "format", "", inputFormatUsage)
+	cmdBuildGlossary.Flags().StringSliceVar(&inputGraphs, "graph", nil, inputGraphsUsage)
+
+	// subcommand update-tree
+	cmdUpdateTree := &cobra.Command{
+		Use:   "update-tree <model> <delta>",
+		Short: "Apply a delta file to an existing SchemaTree model",
+		Long: "Load the <model> (schematree binary), add and remove the subjects listed in <delta> and store the" +
+			" updated model. The delta file contains N-Triples prefixed with '+' or '-', grouped by subject:" +
+			" the '-' triples are the old state of a subject and the '+' triples its new state.\nThe tree is" +
+			" rebalanced if the sort order drifted too far from the property frequencies. The model is" +
+			" overwritten unless --output is given.",
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			modelBinary := &args[0]
+			deltaFile := &args[1]
+
+			model, err := schematree.Load(*modelBinary)
+			if err != nil {
+				log.Panicln(err)
+			}
+
+			stats, err := model.ApplyDelta(*deltaFile, rebalanceThreshold)
+			if err != nil {
+				log.Panicln(err)
+			}
+			if forceRebalance && !stats.Rebalanced {
+				if err := model.Rebalance(); err != nil {
+					log.Panicln(err)
+				}
+				stats.Rebalanced = true
+			}
+			fmt.Printf("%+v\n", stats)
+
+			if updateOutput == "" {
+				updateOutput = *modelBinary
+			}
+			if err := model.Save(updateOutput); err != nil {
+				log.Panicln(err)
+			}
+		},
+	}
+	cmdUpdateTree.Flags().StringVarP(&updateOutput, "output", "o", "", "write the updated model to `file` instead of overwriting <model>")
+	cmdUpdateTree.Flags().Float64Var(&rebalanceThreshold, "rebalance-threshold", 0.1,
+		"rebalance if more than this `share` of neighbouring properties in the sort order are out of frequency order (0 disables)")
+	cmdUpdateTree.Flags().BoolVar(&forceRebalance, "rebalance", false, "always rebalance the tree after the update")
+
+	// subcommand flatten-tree
+	cmdFlattenTree := &cobra.Command{
+		Use:   "flatten-tree <model>",
+		Short: "Convert a SchemaTree model into the flat layout for memory-mapped serving",
+		Long: "Load the <model> (schematree binary) and store it in the flat layout that is memory-mapped" +
+			" instead of decoded when it is loaded, e.g. by serve. Several server processes then share one" +
+			" copy of the tree through the page cache and start instantly.\nThe output file is" +
+			" '<model>.flat' unless --output is given.",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			modelBinary := &args[0]
+
+			model, err := schematree.Load(*modelBinary)
+			if err != nil {
+				log.Panicln(err)
+			}
+
+			if flatOutput == "" {
+				flatOutput = *modelBinary + ".flat"
+			}
+			if err := model.SaveFlat(flatOutput); err != nil {
+				log.Panicln(err)
+			}
+		},
+	}
+	cmdFlattenTree.Flags().StringVarP(&flatOutput, "output", "o", "", "write the flat model to `file`")
+
+	// subcommand merge-trees
+	cmdMergeTrees := &cobra.Command{
+		Use:   "merge-trees <model> <model>...",
+		Short: "Merge SchemaTree models built on different shards of a dataset",
+		Long: "Load all <model>s (schematree binaries) and combine them into one model that contains the subjects" +
+			" of all of them, e.g. models that have been built on different machines or dataset shards." +
+			" The models have to be either all typed or all untyped. The merged model is written to --output.",
+		Args: cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var model *schematree.SchemaTree
+			for _, modelBinary := range args {
+				other, err := schematree.Load(modelBinary)
+				if err != nil {
+					log.Panicln(err)
+				}
+				if model == nil {
+					// merge into a new tree, as loaded models may be in the read-only flat layout
+					model = schematree.New(other.Typed, 1)
+					model.Meta.Config = other.Config()
+				}
+				if err := model.Merge(other); err != nil {
+					log.Panicln(err)
+				}
+				other.Close()
+			}
+
+			if err := model.Save(mergeOutput); err != nil {
+				log.Panicln(err)
+			}
+		},
+	}
+	cmdMergeTrees.Flags().StringVarP(&mergeOutput, "output", "o", "", "write the merged model to `file`")
+	cmdMergeTrees.MarkFlagRequired("output")
+
+	// subcommand prune-tree
+	cmdPruneTree := &cobra.Command{
+		Use:   "prune-tree <model>",
+		Short: "Prune infrequent branches of a SchemaTree model",
+		Long: "Load the <model> (schematree binary), remove all nodes with a support below --min-support and" +
+			" store the pruned model. The number of nodes and the node memory before and after the pruning" +
+			" are reported. With --sample, the recommendation quality is measured on a held-out dataset" +
+			" before and after the pruning. The model is overwritten unless --output is given.",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			modelBinary := &args[0]
+
+			model, err := schematree.Load(*modelBinary)
+			if err != nil {
+				log.Panicln(err)
+			}
+
+			var before schematree.SampleQuality
+			if sampleDataset != "" {
+				before = model.EvaluateSample(sampleDataset, sampleSize)
+			}
+			stats := model.Prune(minSupport)
+			fmt.Println(stats)
+			if sampleDataset != "" {
+				after := model.EvaluateSample(sampleDataset, sampleSize)
+				fmt.Printf("Quality before pruning: %v\n", before)
+				fmt.Printf("Quality after pruning:  %v\n", after)
+				fmt.Printf("Quality lost: hits@1 %.4f, hits@10 %.4f, MRR %.4f\n",
+					before.HitsAt1-after.HitsAt1, before.HitsAt10-after.HitsAt10, before.MRR-after.MRR)
+			}
+
+			if pruneOutput == "" {
+				pruneOutput = *modelBinary
+			}
+			if err := model.Save(pruneOutput); err != nil {
+				log.Panicln(err)
+			}
+		},
+	}
+	cmdPruneTree.Flags().Uint64Var(&minSupport, "min-support", 1, "remove all nodes with a support below `n`")
+	cmdPruneTree.MarkFlagRequired("min-support")
+	cmdPruneTree.Flags().StringVarP(&pruneOutput, "output", "o", "", "write the pruned model to `file` instead of overwriting <model>")
+	cmdPruneTree.Flags().StringVar(&sampleDataset, "sample", "", "held-out `dataset` to measure the recommendation quality lost by the pruning")
+	cmdPruneTree.Flags().Uint64Var(&sampleSize, "sample-size", 1000, "number of subjects of the sample that are evaluated (0 for all)")
+
+	// subcommand mine-rules
+	cmdMineRules := &cobra.Command{
+		Use:   "mine-rules <model>",
+		Short: "Mine frequent property sets and association rules from a SchemaTree model",
+		Long: "Load the <model> (schematree binary) and run FP-Growth over it to find all sets of properties, types" +
+			" and property=value items that at least --min-support subjects have in common, and the association" +
+			" rules between them with a confidence and lift above the thresholds. With --format csv, the rules and" +
+			" the frequent sets are written to '<output>.rules.csv' and '<output>.sets.csv', with --format json both" +
+			" are written to '<output>.rules.json'. <output> is <model> unless --output is given.",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			modelBinary := &args[0]
+			if rulesFormat != "csv" && rulesFormat != "json" {
+				log.Fatalf("Unknown format %q, use csv or json.", rulesFormat)
+			}
+
+			model, err := schematree.Load(*modelBinary)
+			if err != nil {
+				log.Panicln(err)
+			}
+
+			result := model.MineRules(schematree.MiningOptions{
+				MinSupport:    minSupport,
+				MaxSize:       maxSetSize,
+				MinConfidence: minConfidence,
+				MinLift:       minLift,
+			})
+
+			if rulesOutput == "" {
+				rulesOutput = *modelBinary
+			}
+			write := func(fileName string, writeTo func(io.Writer) error) {
+				f, err := os.Create(fileName)
+				if err != nil {
+					log.Fatalln(err)
+				}
+				defer f.Close()
+				if err := writeTo(f); err != nil {
+					log.Fatalln(err)
+				}
+				fmt.Printf("Wrote %v\n", fileName)
+			}
+			if rulesFormat == "json" {
+				write(rulesOutput+".rules.json", result.WriteJSON)
+			} else {
+				write(rulesOutput+".rules.csv", result.WriteRulesCSV)
+				write(rulesOutput+".sets.csv", result.WriteSetsCSV)
+			}
+		},
+	}
+	cmdMineRules.Flags().Uint64Var(&minSupport, "min-support", 1, "minimum number of subjects of a frequent set")
+	cmdMineRules.MarkFlagRequired("min-support")
+	cmdMineRules.Flags().IntVar(&maxSetSize, "max-size", 4, "maximum number of items of a frequent set (0 for no limit)")
+	cmdMineRules.Flags().Float64Var(&minConfidence, "min-confidence", 0.8, "minimum confidence of a rule")
+	cmdMineRules.Flags().Float64Var(&minLift, "min-lift", 1, "minimum lift of a rule")
+	cmdMineRules.Flags().StringVar(&rulesFormat, "format", "csv", "output `format`, csv or json")
+	cmdMineRules.Flags().StringVarP(&rulesOutput, "output", "o", "", "write the results to files starting with `prefix` instead of <model>")
+
+	// subcommand generate-shapes
+	cmdGenerateShapes := &cobra.Command{
+		Use:   "generate-shapes <model>",
+		Short: "Generate SHACL shapes for the types of a typed SchemaTree model",
+		Long: "Load the <model> (typed schematree binary) and write a SHACL NodeShape in Turtle for every type with at" +
+			" least --min-support subjects. Each shape has a property shape for every property that at least" +
+			" --min-probability of the subjects of the type have. Properties that at least --min-share of the subjects" +
+			" have get sh:minCount 1; sh:maxCount, sh:datatype, sh:nodeKind and sh:class are derived from the observed" +
+			" multiplicities and objects of the property if --min-share of them agree. The output file is" +
+			" '<model>.shapes.ttl' unless --output is given.",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			modelBinary := &args[0]
+
+			model, err := schematree.Load(*modelBinary)
+			if err != nil {
+				log.Panicln(err)
+			}
+			if !model.Typed {
+				log.Fatalln("Shapes can only be generated for typed models, see build-tree-typed.")
+			}
+
+			nodeShapes := shapes.Generate(model, shapes.Options{
+				MinSupport:     minTypeSupport,
+				MinProbability: minProbability,
+				Counts:         !noCounts,
+				Datatypes:      !noDatatypes,
+				MinShare:       minShare,
+				Namespace:      shapeNamespace,
+			})
+
+			if shapesOutput == "" {
+				shapesOutput = *modelBinary + ".shapes.ttl"
+			}
+			f, err := os.Create(shapesOutput)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			defer f.Close()
+			if err := shapes.WriteTurtle(f, nodeShapes); err != nil {
+				log.Fatalln(err)
+			}
+			fmt.Printf("Wrote %v shapes to %v\n", len(nodeShapes), shapesOutput)
+		},
+	}
+	cmdGenerateShapes.Flags().Uint64Var(&minTypeSupport, "min-support", shapes.DefaultOptions.MinSupport, "minimum number of subjects of a type with a shape")
+	cmdGenerateShapes.Flags().Float64Var(&minProbability, "min-probability", shapes.DefaultOptions.MinProbability, "minimum share of the subjects of a type that have a property of its shape")
+	cmdGenerateShapes.Flags().Float64Var(&minShare, "min-share", shapes.DefaultOptions.MinShare, "share of the subjects or objects that have to satisfy a derived count or datatype constraint")
+	cmdGenerateShapes.Flags().BoolVar(&noCounts, "no-counts", false, "leave out sh:minCount and sh:maxCount")
+	cmdGenerateShapes.Flags().BoolVar(&noDatatypes, "no-datatypes", false, "leave out sh:datatype, sh:nodeKind and sh:class")
+	cmdGenerateShapes.Flags().StringVar(&shapeNamespace, "namespace", shapes.DefaultOptions.Namespace, "`namespace` of the IRIs of the generated shapes")
+	cmdGenerateShapes.Flags().StringVarP(&shapesOutput, "output", "o", "", "write the shapes to `file`")
+
+	// subcommand validate
+	cmdValidate := &cobra.Command{
+		Use:   "validate <model> <dataset>",
+		Short: "Report missing and surprising properties of the subjects of a dataset",
+		Long: "Load the <model> (schematree binary) and check every subject of the <dataset> against it. Properties" +
+			" that are at least --min-probability likely given the description of a subject but missing are" +
+			" expected, present properties that are less than --max-probability likely given the rest of the" +
+			" description are surprising. Present properties with a number of values that less than --max-share of" +
+			" the subjects with the property have are suspicious. The report of every subject with findings (of all subjects with --all)" +
+			" is written as one JSON object per line to '<dataset>.validation.jsonl' unless --output is given.",
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			modelBinary := &args[0]
+			inputDataset := &args[1]
+
+			model, err := schematree.Load(*modelBinary)
+			if err != nil {
+				log.Panicln(err)
+			}
+
+			if validateOutput == "" {
+				validateOutput = *inputDataset + ".validation.jsonl"
+			}
+			f, err := os.Create(validateOutput)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			defer f.Close()
+			out := bufio.NewWriter(f)
+			defer out.Flush()
+			encoder := json.NewEncoder(out)
+
+			var lock sync.Mutex
+			var subjects, withMissing, withSurprising, withMultiplicities, withUnknown uint64
+			options := schematree.ValidationOptions{MinProbability: expectedProbability, MaxProbability: surprisingProbability,
+				MaxShare: multiplicityShare}
+			model.ValidateDataset(*inputDataset, uint64(firstNsubjects), options, func(report *schematree.ValidationReport) {
+				lock.Lock()
+				defer lock.Unlock()
+				subjects++
+				if len(report.Missing) > 0 {
+					withMissing++
+				}
+				if len(report.Surprising) > 0 {
+					withSurprising++
+				}
+				if len(report.SuspiciousMultiplicities) > 0 {
+					withMultiplicities++
+				}
+				if len(report.UnknownProperties) > 0 || len(report.UnknownTypes) > 0 {
+					withUnknown++
+				}
+				if reportAll || report.HasFindings() {
+					if err := encoder.Encode(report); err != nil {
+						log.Fatalln(err)
+					}
+				}
+			})
+			fmt.Printf("Validated %v subjects: %v with missing, %v with surprising, %v with suspiciously many or few values"+
+				" and %v with unknown properties or types\n", subjects, withMissing, withSurprising, withMultiplicities, withUnknown)
+			fmt.Printf("Wrote the reports to %v\n", validateOutput)
+		},
+	}
+	cmdValidate.Flags().Float64Var(&expectedProbability, "min-probability", schematree.DefaultValidationOptions.MinProbability, "properties at least this likely given a description are expected")
+	cmdValidate.Flags().Float64Var(&surprisingProbability, "max-probability", schematree.DefaultValidationOptions.MaxProbability, "present properties less likely than this given the rest of a description are surprising")
+	cmdValidate.Flags().Float64Var(&multiplicityShare, "max-share", schematree.DefaultValidationOptions.MaxShare, "numbers of values of a property that less than this share of its subjects have are suspicious")
+	cmdValidate.Flags().Int64VarP(&firstNsubjects, "first", "n", 0, "only validate the first `n` subjects")
+	cmdValidate.Flags().BoolVar(&reportAll, "all", false, "also report subjects without findings")
+	cmdValidate.Flags().StringVarP(&validateOutput, "output", "o", "", "write the reports to `file`")
+
+	// subcommand score-anomalies
+	cmdScoreAnomalies := &cobra.Command{
+		Use:   "score-anomalies <model> <dataset>",
+		Short: "Find the subjects of a dataset with the most unusual property combinations",
+		Long: "Load the <model> (schematree binary) and score every subject of the <dataset> by the average negative" +
+			" log probability of each of its properties and types given the others. The --top most unusual subjects" +
+			" are written as one JSON object per line, the most unusual first, each with the --contributors items" +
+			" that contribute most to its score. The output file is '<dataset>.anomalies.jsonl' unless --output is given.",
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			modelBinary := &args[0]
+			inputDataset := &args[1]
+			if anomalyTop < 0 || anomalyContributors < 0 {
+				log.Fatalln("--top and --contributors must not be negative.")
+			}
+
+			model, err := schematree.Load(*modelBinary)
+			if err != nil {
+				log.Panicln(err)
+			}
+
+			t1 := time.Now()
+			anomalies, scored := model.ScoreDataset(*inputDataset, uint64(firstNsubjects), anomalyTop, anomalyContributors)
+			fmt.Printf("Scored %v subjects in %v\n", scored, time.Since(t1))
+
+			if anomalyOutput == "" {
+				anomalyOutput = *inputDataset + ".anomalies.jsonl"
+			}
+			f, err := os.Create(anomalyOutput)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			defer f.Close()
+			out := bufio.NewWriter(f)
+			defer out.Flush()
+			encoder := json.NewEncoder(out)
+			for _, anomaly := range anomalies {
+				if err := encoder.Encode(anomaly); err != nil {
+					log.Fatalln(err)
+				}
+			}
+			fmt.Printf("Wrote the %v most unusual subjects to %v\n", len(anomalies), anomalyOutput)
+		},
+	}
+	cmdScoreAnomalies.Flags().IntVar(&anomalyTop, "top", 100, "number of the most unusual subjects to report")
+	cmdScoreAnomalies.Flags().IntVar(&anomalyContributors, "contributors", 3, "number of the most surprising items to report per subject")
+	cmdScoreAnomalies.Flags().Int64VarP(&firstNsubjects, "first", "n", 0, "only score the first `n` subjects")
+	cmdScoreAnomalies.Flags().StringVarP(&anomalyOutput, "output", "o", "", "write the unusual subjects to `file`")
+
+	// subcommand recommend-file
+	cmdRecommendFile := &cobra.Command{
+		Use:   "recommend-file <model> <dataset>",
+		Short: "Recommend missing properties for every subject of a dataset",
+		Long: "Load the <model> (schematree binary) and run the workflow (the standard recommender unless --workflow" +
+			" is given) on the properties and types of every subject of the <dataset>, which has to be grouped by" +
+			" subject. The --top recommended properties that a subject lacks are written as one JSON object per" +
+			" subject (--format jsonl) or as CSV rows (--format csv) to '<dataset>.recommendations.<format>' unless" +
+			" --output is given. The subjects are written in no particular order.",
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			modelBinary := &args[0]
+			inputDataset := &args[1]
+			if recommendFormat != "jsonl" && recommendFormat != "csv" {
+				log.Fatalf("Unknown format %v, expected jsonl or csv", recommendFormat)
+			}
+
+			model, err := schematree.Load(*modelBinary)
+			if err != nil {
+				log.Panicln(err)
+			}
+
+			var workflow *strategy.Workflow
+			if workflowFile != "" {
+				config, err := configuration.ReadConfigFile(&workflowFile)
+				if err != nil {
+					log.Panicln(err)
+				}
+				if err = config.Test(); err != nil {
+					log.Panicln(err)
+				}
+				if workflow, err = configuration.ConfigToWorkflow(config, model); err != nil {
+					log.Panicln(err)
+				}
+			} else {
+				workflow = strategy.MakePresetWorkflow("direct", model)
+			}
+
+			if recommendOutput == "" {
+				recommendOutput = *inputDataset + ".recommendations." + recommendFormat
+			}
+			f, err := os.Create(recommendOutput)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			defer f.Close()
+			out := bufio.NewWriter(f)
+			defer out.Flush()
+			encoder := json.NewEncoder(out)
+			rows := csv.NewWriter(out)
+			rows.Comma = ';'
+			if recommendFormat == "csv" {
+				rows.Write([]string{"Subject", "Rank", "Property", "Probability", "Support", "SetSupport"})
+			}
+
+			var lock sync.Mutex
+			var subjects, recommendations uint64
+			t1 := time.Now()
+			recommend := func(properties schematree.IList, k int) schematree.PropertyRecommendations {
+				asm := assessment.NewInstance(properties, model, true)
+				asm.Limit = k
+				return workflow.Recommend(asm)
+			}
+			model.RecommendDataset(*inputDataset, uint64(firstNsubjects), recommendTop, recommend, func(result *schematree.SubjectRecommendation) {
+				lock.Lock()
+				defer lock.Unlock()
+				subjects++
+				recommendations += uint64(len(result.Recommendations))
+				if recommendFormat == "jsonl" {
+					if err := encoder.Encode(result); err != nil {
+						log.Fatalln(err)
+					}
+					return
+				}
+				for i, rec := range result.Recommendations {
+					rows.Write([]string{result.Subject, fmt.Sprint(i + 1), rec.Property, fmt.Sprint(rec.Probability),
+						fmt.Sprint(rec.Support), fmt.Sprint(rec.SetSupport)})
+				}
+			})
+			rows.Flush()
+			if err := rows.Error(); err != nil {
+				log.Fatalln(err)
+			}
+			fmt.Printf("Recommended %v properties for %v subjects in %v\n", recommendations, subjects, time.Since(t1))
+			fmt.Printf("Wrote the recommendations to %v\n", recommendOutput)
+		},
+	}
+	cmdRecommendFile.Flags().StringVarP(&workflowFile, "workflow", "w", "", "`path` to config file that defines the workflow")
+	cmdRecommendFile.Flags().IntVar(&recommendTop, "top", 10, "number of properties to recommend per subject")
+	cmdRecommendFile.Flags().StringVar(&recommendFormat, "format", "jsonl", "output format, jsonl or csv")
+	cmdRecommendFile.Flags().Int64VarP(&firstNsubjects, "first", "n", 0, "only recommend for the first `n` subjects")
+	cmdRecommendFile.Flags().StringVarP(&recommendOutput, "output", "o", "", "write the recommendations to `file`")
 
 	// subcommand serve
 	cmdServe := &cobra.Command{
 		Use:   "serve <model> <glossary>",
 		Short: "Serve a SchemaTree model via an HTTP Server",
 		Long: "Load the <model> (schematree binary) and the <glossary> (glossary binary) and the recommendation" +
-			" endpoint using an HTTP Server.\nAvailable endpoints are stated in the server README.",
+			" endpoint using an HTTP Server. Models in the flat layout (see flatten-tree) are memory-mapped." +
+			"\nAvailable endpoints are stated in the server README.",
 		Args: cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
 			modelBinary := &args[0]
 			glossaryBinary := &args[1]
 
 			// Load the schematree from the binary file.
+			schematree.CompactLayout = compactLayout
 			model, err := schematree.Load(*modelBinary)
 			if err != nil {
 				log.Panicln(err)
 			}
+			if verifyModel {
+				if err := model.Verify(); err != nil {
+					log.Panicln(err)
+				}
+			}
 			schematree.PrintMemUsage()
 
 			// Load the glossary from the binary file.
@@ -244,28 +898,76 @@ func main() {
 
 			// read config file if given as parameter, test if everything needed is there, create a workflow
 			// if no config file is given, the standard recommender is set as workflow.
-			var workflow *strategy.Workflow
-			if workflowFile != "" {
+			makeWorkflow := func(model *schematree.SchemaTree) (*strategy.Workflow, error) {
+				if workflowFile == "" {
+					return strategy.MakePresetWorkflow("direct", model), nil
+				}
 				config, err := configuration.ReadConfigFile(&workflowFile)
 				if err != nil {
-					log.Panicln(err)
+					return nil, err
+				}
+				if err = config.Test(); err != nil {
+					return nil, err
 				}
-				err = config.Test()
+				return configuration.ConfigToWorkflow(config, model)
+			}
+			workflow, err := makeWorkflow(model)
+			if err != nil {
+				log.Panicln(err)
+			}
+			if workflowFile != "" {
+				log.Printf("Run Config Workflow %v", workflowFile)
+			} else {
+				fmt.Printf("Run Standard Recommender ")
+			}
+
+			// Share the recommendations of identical queries, optionally computed in advance from a query log.
+			var cache *strategy.Cache
+			if cacheSize > 0 {
+				cache = strategy.NewCache(model, cacheSize)
+			}
+			if warmUpLog != "" {
+				f, err := os.Open(warmUpLog)
 				if err != nil {
 					log.Panicln(err)
 				}
-				workflow, err = configuration.ConfigToWorkflow(config, model)
+				queries, err := server.WarmUp(cache, model, workflow, f, 500)
+				f.Close()
 				if err != nil {
 					log.Panicln(err)
 				}
-				log.Printf("Run Config Workflow %v", workflowFile)
-			} else {
-				workflow = strategy.MakePresetWorkflow("direct", model)
-				fmt.Printf("Run Standard Recommender ")
+				fmt.Printf("Warmed up the cache with %v queries\n", queries)
 			}
 
 			// Initiate the HTTP server. Make it stop on <Enter> press.
-			router := server.SetupEndpoints(model, glos, workflow, 500)
+			router := server.NewReloadable(server.SetupEndpoints(model, glos, workflow, cache, 500))
+
+			// Reload the model file on SIGHUP, e.g. after update-tree. The previous model keeps serving if the new
+			// one cannot be loaded.
+			reload := make(chan os.Signal, 1)
+			signal.Notify(reload, syscall.SIGHUP)
+			go func() {
+				for range reload {
+					fmt.Printf("Reloading the model... ")
+					newModel, err := schematree.Load(*modelBinary)
+					if err == nil && verifyModel {
+						err = newModel.Verify()
+					}
+					var newWorkflow *strategy.Workflow
+					if err == nil {
+						newWorkflow, err = makeWorkflow(newModel)
+					}
+					if err != nil {
+						log.Printf("Keeping the previous model, the reload failed: %v", err)
+						continue
+					}
+					cache.Reload(newModel)
+					router.Replace(server.SetupEndpoints(newModel, glos, newWorkflow, cache, 500))
+					model.Close()
+					model = newModel
+					fmt.Println("Reloaded the model")
+				}
+			}()
 			fmt.Printf("Now listening on 0.0.0.0:%v\n", serveOnPort)
 			http.ListenAndServe(fmt.Sprintf("0.0.0.0:%v", serveOnPort), router)
 
@@ -278,6 +980,10 @@ func main() {
 	// cmdBuildTree.MarkFlagRequired("load")
 	cmdServe.Flags().IntVarP(&serveOnPort, "port", "p", 8080, "`port` of http server")
 	cmdServe.Flags().StringVarP(&workflowFile, "workflow", "w", "", "`path` to config file that defines the workflow")
+	cmdServe.Flags().BoolVar(&compactLayout, "compact", false, "convert the model into the compact struct-of-arrays layout after loading")
+	cmdServe.Flags().BoolVar(&verifyModel, "verify", false, "check all indexes of a model in the flat layout before serving it, which reads the whole file")
+	cmdServe.Flags().IntVar(&cacheSize, "cache", 1000, "number of recommendation results shared between requests, 0 disables the cache")
+	cmdServe.Flags().StringVar(&warmUpLog, "warm-up", "", "`path` to a query log with one /recommender request per line to fill the cache with")
 
 	// subcommand visualize
 	cmdBuildDot := &cobra.Command{
@@ -505,6 +1211,15 @@ func main() {
 	cmdRoot.AddCommand(cmdBuildTree)
 	cmdRoot.AddCommand(cmdBuildTreeTyped)
 	cmdRoot.AddCommand(cmdBuildGlossary)
+	cmdRoot.AddCommand(cmdUpdateTree)
+	cmdRoot.AddCommand(cmdFlattenTree)
+	cmdRoot.AddCommand(cmdPruneTree)
+	cmdRoot.AddCommand(cmdMergeTrees)
+	cmdRoot.AddCommand(cmdMineRules)
+	cmdRoot.AddCommand(cmdGenerateShapes)
+	cmdRoot.AddCommand(cmdValidate)
+	cmdRoot.AddCommand(cmdScoreAnomalies)
+	cmdRoot.AddCommand(cmdRecommendFile)
 	cmdRoot.AddCommand(cmdServe)
 	cmdRoot.AddCommand(cmdBuildDot)
 
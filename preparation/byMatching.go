@@ -22,10 +22,10 @@ func SplitByPrefix(filePath string) (*SplitByPrefixStats, error) {
 
 	// Setup attributes of the wikidata ontology
 	var wdItemSubjects = [][]byte{
-		[]byte("<http://www.wikidata.org/entity/Q"),
+		[]byte("http://www.wikidata.org/entity/Q"),
 	}
 	var wdPropSubjects = [][]byte{
-		[]byte("<http://www.wikidata.org/entity/P"),
+		[]byte("http://www.wikidata.org/entity/P"),
 	}
 
 	// Get a N-Triple parser for the input file.
@@ -53,14 +53,15 @@ func SplitByPrefix(filePath string) (*SplitByPrefixStats, error) {
 	defer miscFile.Close()
 
 	// Go through all entries and decide on a line-by-line basis.
-	for trip, err := tParser.NextTriple(1); trip != nil && err == nil; trip, err = tParser.NextTriple(1) {
+	var trip *recIO.Triple
+	for trip, err = tParser.NextTriple(1); trip != nil && err == nil; trip, err = tParser.NextTriple(1) {
 
 		// We can check for equality in the first bytes instead of using actual regex or unicode.
-		if startsWithOneOf(trip.Subject, wdItemSubjects) {
+		if startsWithOneOf(trip.Subject.Value, wdItemSubjects) {
 			itemFile.Write(trip.Line)
 			itemFile.Write([]byte("\r\n")) // have to write the newline
 			stats.ItemCount++
-		} else if startsWithOneOf(trip.Subject, wdPropSubjects) {
+		} else if startsWithOneOf(trip.Subject.Value, wdPropSubjects) {
 			propFile.Write(trip.Line)
 			propFile.Write([]byte("\r\n")) // have to write the newline
 			stats.PropCount++
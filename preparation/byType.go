@@ -20,14 +20,14 @@ func SplitByType(filePath string) (*SplitByTypeStats, error) {
 	stats := SplitByTypeStats{}
 
 	// Setup attributes of the wikidata ontology
-	var wdTypePredicate = []byte("<http://www.w3.org/1999/02/22-rdf-syntax-ns#type>")
+	var wdTypePredicate = []byte("http://www.w3.org/1999/02/22-rdf-syntax-ns#type")
 	var wdItemObjects = [][]byte{
-		[]byte("<http://wikiba.se/ontology#Item>"),
-		[]byte("<http://wikiba.se/ontology-beta#Item>"), // included for retro-compatibility
+		[]byte("http://wikiba.se/ontology#Item"),
+		[]byte("http://wikiba.se/ontology-beta#Item"), // included for retro-compatibility
 	}
 	var wdPropObjects = [][]byte{
-		[]byte("<http://wikiba.se/ontology#Property>"),
-		[]byte("<http://www.wikidata.org/ontology#Property>"), // included for retro-compatibility
+		[]byte("http://wikiba.se/ontology#Property"),
+		[]byte("http://www.wikidata.org/ontology#Property"), // included for retro-compatibility
 	}
 
 	// Define a N-Triple parser for the input file.
@@ -51,16 +51,17 @@ func SplitByType(filePath string) (*SplitByTypeStats, error) {
 
 	// Will perform one pass on the entire file to identify subjects and categorize them.
 	subjectTypeMap := map[string]int{}
-	for trip, err := tParser.NextTriple(); trip != nil && err == nil; trip, err = tParser.NextTriple() {
+	var trip *recIO.Triple
+	for trip, err = tParser.NextTriple(); trip != nil && err == nil; trip, err = tParser.NextTriple() {
 
 		// Identify if this entry is trying to describe a type, and get that type.
 		// Only register the mapping if the type is 'item' or 'prop. We do not need to store mappings for 'misc'.
 		// Also, the same predicate can occur multiple times and with values we ignore. Only take valid objects.
-		if bytes.Equal(trip.Predicate, wdTypePredicate) {
-			if equalToOneOf(trip.Object, wdItemObjects) {
-				subjectTypeMap[string(trip.Subject)] = itemBlock
-			} else if equalToOneOf(trip.Object, wdPropObjects) {
-				subjectTypeMap[string(trip.Subject)] = propBlock
+		if bytes.Equal(trip.Predicate.Value, wdTypePredicate) {
+			if equalToOneOf(trip.Object.Value, wdItemObjects) {
+				subjectTypeMap[trip.Subject.Identifier()] = itemBlock
+			} else if equalToOneOf(trip.Object.Value, wdPropObjects) {
+				subjectTypeMap[trip.Subject.Identifier()] = propBlock
 			}
 		}
 	}
@@ -87,8 +88,8 @@ func SplitByType(filePath string) (*SplitByTypeStats, error) {
 	}
 
 	// On the second pass, go through all entries and send them to their file according to the mapping.
-	for trip, err := tParser.NextTriple(); trip != nil && err == nil; trip, err = tParser.NextTriple() {
-		mapType, mapOk := subjectTypeMap[string(trip.Subject)]
+	for trip, err = tParser.NextTriple(); trip != nil && err == nil; trip, err = tParser.NextTriple() {
+		mapType, mapOk := subjectTypeMap[trip.Subject.Identifier()]
 
 		// Mapped items are guaranteed to have correct block. Misc if no mapping found.
 		var curBlockType int
@@ -132,14 +133,14 @@ func SplitByTypeInBlocks(filePath string) (*SplitByTypeStats, error) {
 	stats := SplitByTypeStats{}
 
 	// Setup attributes of the wikidata ontology
-	var wdTypePredicate = []byte("<http://www.w3.org/1999/02/22-rdf-syntax-ns#type>")
+	var wdTypePredicate = []byte("http://www.w3.org/1999/02/22-rdf-syntax-ns#type")
 	var wdItemObjects = [][]byte{
-		[]byte("<http://wikiba.se/ontology#Item>"),
-		[]byte("<http://wikiba.se/ontology-beta#Item>"), // included for retro-compatibility
+		[]byte("http://wikiba.se/ontology#Item"),
+		[]byte("http://wikiba.se/ontology-beta#Item"), // included for retro-compatibility
 	}
 	var wdPropObjects = [][]byte{
-		[]byte("<http://wikiba.se/ontology#Property>"),
-		[]byte("<http://www.wikidata.org/ontology#Property>"), // included for retro-compatibility
+		[]byte("http://wikiba.se/ontology#Property"),
+		[]byte("http://www.wikidata.org/ontology#Property"), // included for retro-compatibility
 	}
 
 	// Get a N-Triple parser for the input file.
@@ -172,10 +173,11 @@ func SplitByTypeInBlocks(filePath string) (*SplitByTypeStats, error) {
 	var curBlockSubject []byte // subject of the current block, used to check if we proceeded to another block
 	tempCount := 0
 	curBlockType := miscBlock // type of the current block
-	for trip, err := tParser.NextTriple(); err == nil; trip, err = tParser.NextTriple() {
+	var trip *recIO.Triple
+	for trip, err = tParser.NextTriple(); err == nil; trip, err = tParser.NextTriple() {
 
 		// Check if the subject of the block has changed, or it terminated.
-		if trip == nil || !bytes.Equal(curBlockSubject, trip.Subject) {
+		if trip == nil || !bytes.Equal(curBlockSubject, trip.Subject.Raw) {
 
 			// Flush the buffer into one of the 3 files
 			switch curBlockType {
@@ -192,7 +194,7 @@ func SplitByTypeInBlocks(filePath string) (*SplitByTypeStats, error) {
 
 			// Set the new subject to identify this new block
 			if trip != nil {
-				curBlockSubject = trip.Subject
+				curBlockSubject = trip.Subject.Raw
 				curBlockType = miscBlock
 				tempCount = 0
 			}
@@ -204,10 +206,10 @@ func SplitByTypeInBlocks(filePath string) (*SplitByTypeStats, error) {
 		}
 
 		// While its a 'misc' block, we hope to find a predicate that identifies the type.
-		if curBlockType == miscBlock && bytes.Equal(trip.Predicate, wdTypePredicate) {
-			if equalToOneOf(trip.Object, wdItemObjects) {
+		if curBlockType == miscBlock && bytes.Equal(trip.Predicate.Value, wdTypePredicate) {
+			if equalToOneOf(trip.Object.Value, wdItemObjects) {
 				curBlockType = itemBlock
-			} else if equalToOneOf(trip.Object, wdPropObjects) {
+			} else if equalToOneOf(trip.Object.Value, wdPropObjects) {
 				curBlockType = propBlock
 			}
 		}
@@ -12,12 +12,12 @@ import (
 // todo: In future, such hard-coded predicates should probably not exist.
 func FilterForSchematree(filePath string) (*FilterStats, error) {
 	var removalPredicates = [][]byte{
-		[]byte("<http://www.w3.org/1999/02/22-rdf-syntax-ns#type>"),
-		[]byte("<http://www.w3.org/2000/01/rdf-schema#label>"),
-		[]byte("<http://www.w3.org/2004/02/skos/core#prefLabel>"),
-		[]byte("<http://www.w3.org/2004/02/skos/core#altLabel>"),
-		[]byte("<http://schema.org/name>"),
-		[]byte("<http://schema.org/description>"),
+		[]byte("http://www.w3.org/1999/02/22-rdf-syntax-ns#type"),
+		[]byte("http://www.w3.org/2000/01/rdf-schema#label"),
+		[]byte("http://www.w3.org/2004/02/skos/core#prefLabel"),
+		[]byte("http://www.w3.org/2004/02/skos/core#altLabel"),
+		[]byte("http://schema.org/name"),
+		[]byte("http://schema.org/description"),
 	}
 	return filterByPredicate(filePath, removalPredicates)
 }
@@ -35,9 +35,9 @@ func FilterForSchematree(filePath string) (*FilterStats, error) {
 //       the building step.
 func FilterForGlossary(filePath string) (*FilterStats, error) {
 	var removalPredicates = [][]byte{
-		[]byte("<http://www.w3.org/1999/02/22-rdf-syntax-ns#type>"),
-		[]byte("<http://www.w3.org/2000/01/rdf-schema#label>"),
-		[]byte("<http://www.w3.org/2004/02/skos/core#prefLabel>"),
+		[]byte("http://www.w3.org/1999/02/22-rdf-syntax-ns#type"),
+		[]byte("http://www.w3.org/2000/01/rdf-schema#label"),
+		[]byte("http://www.w3.org/2004/02/skos/core#prefLabel"),
 	}
 	return filterByPredicate(filePath, removalPredicates)
 }
@@ -46,12 +46,12 @@ func FilterForGlossary(filePath string) (*FilterStats, error) {
 // executing the evaluation.
 func FilterForEvaluation(filePath string) (*FilterStats, error) {
 	var removalPredicates = [][]byte{
-		[]byte("<http://www.w3.org/1999/02/22-rdf-syntax-ns#type>"),
-		[]byte("<http://www.w3.org/2000/01/rdf-schema#label>"),
-		[]byte("<http://www.w3.org/2004/02/skos/core#prefLabel>"),
-		[]byte("<http://www.w3.org/2004/02/skos/core#altLabel>"),
-		[]byte("<http://schema.org/name>"),
-		[]byte("<http://schema.org/description>"),
+		[]byte("http://www.w3.org/1999/02/22-rdf-syntax-ns#type"),
+		[]byte("http://www.w3.org/2000/01/rdf-schema#label"),
+		[]byte("http://www.w3.org/2004/02/skos/core#prefLabel"),
+		[]byte("http://www.w3.org/2004/02/skos/core#altLabel"),
+		[]byte("http://schema.org/name"),
+		[]byte("http://schema.org/description"),
 	}
 	return filterByPredicate(filePath, removalPredicates)
 }
@@ -81,12 +81,13 @@ func filterByPredicate(filePath string, removalPredicates [][]byte) (*FilterStat
 	defer filteredFile.Close()
 
 	// Go through all entries in blocks of subjects.
-	for trip, err := tParser.NextTriple(); trip != nil && err == nil; trip, err = tParser.NextTriple() {
+	var trip *recIO.Triple
+	for trip, err = tParser.NextTriple(); trip != nil && err == nil; trip, err = tParser.NextTriple() {
 
 		// Check if the subject of the block has changed, or it terminated.
 		toRemove := false
 		for _, pred := range removalPredicates {
-			if bytes.Equal(pred, trip.Predicate) {
+			if bytes.Equal(pred, trip.Predicate.Value) {
 				toRemove = true
 				stats.LostCount++
 				break
@@ -0,0 +1,124 @@
+package schematree
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+	"sync"
+)
+
+// AnomalyScore tells how unusual the description of a subject is under the tree.
+type AnomalyScore struct {
+	Subject       string                `json:"subject"`
+	Score         float64               `json:"score"` // average surprise of the items, higher is more unusual
+	Items         int                   `json:"items"` // number of properties and types of the subject
+	Contributions []AnomalyContribution `json:"contributions"`
+}
+
+// AnomalyContribution is an item of a subject together with its probability given the other items.
+type AnomalyContribution struct {
+	Item        string  `json:"item"`
+	Probability float64 `json:"probability"`
+	Surprise    float64 `json:"surprise"` // -log(Probability)
+}
+
+// ScoreAnomaly scores a set of items by the average negative log conditional probability of each item given
+// the others, Support(all) / Support(all without the item). Both supports get one added, so that combinations
+// that the tree has never seen get a finite score. The known items are scored among themselves, the items unknown
+// to the tree, given as unknown, each have a probability of one over the support of the known items plus one.
+// The contributions are sorted by their surprise, the largest first.
+func (tree *SchemaTree) ScoreAnomaly(known IList, unknown []string) AnomalyScore {
+	result := AnomalyScore{Items: len(known) + len(unknown)}
+	if result.Items == 0 {
+		return result
+	}
+	if len(known) > 0 {
+		known.sortAndDeduplicate()
+	}
+	support := tree.Support(known)
+	add := func(item string, all, rest uint64) {
+		surprise := math.Log(float64(rest+1) / float64(all+1))
+		result.Contributions = append(result.Contributions, AnomalyContribution{item, float64(all+1) / float64(rest+1), surprise})
+		result.Score += surprise
+	}
+
+	rest := make(IList, 0, len(known))
+	for i, item := range known {
+		rest = append(append(rest[:0], known[:i]...), known[i+1:]...)
+		add(*item.Str, support, tree.Support(rest))
+	}
+	for _, item := range unknown {
+		add(item, 0, support)
+	}
+
+	result.Items = len(result.Contributions)
+	result.Score /= float64(result.Items)
+	sort.SliceStable(result.Contributions, func(i, j int) bool {
+		if result.Contributions[i].Surprise != result.Contributions[j].Surprise {
+			return result.Contributions[i].Surprise > result.Contributions[j].Surprise
+		}
+		return result.Contributions[i].Item < result.Contributions[j].Item
+	})
+	return result
+}
+
+// ScoreDataset scores the first firstN subjects of a dataset (all subjects if firstN is zero) with ScoreAnomaly
+// and returns the top most unusual ones, the most unusual first, each with its contributors largest contributions.
+// The tree is not modified. scored is the number of all subjects that have been scored. Negative numbers count
+// as zero.
+func (tree *SchemaTree) ScoreDataset(fileName string, firstN uint64, top int, contributors int) (anomalies []AnomalyScore, scored uint64) {
+	if contributors < 0 {
+		contributors = 0
+	}
+	var lock sync.Mutex
+	h := &anomalyHeap{}
+
+	score := func(s *SubjectSummary, known IList, unknown []string) {
+		result := tree.ScoreAnomaly(known, unknown)
+		if result.Items == 0 {
+			return
+		}
+		result.Subject = s.Str
+		if len(result.Contributions) > contributors {
+			result.Contributions = result.Contributions[:contributors]
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		scored++
+		if h.Len() < top {
+			heap.Push(h, result)
+		} else if top > 0 && h.less(h.scores[0], result) {
+			h.scores[0] = result
+			heap.Fix(h, 0)
+		}
+	}
+	tree.readKnownSubjects(fileName, firstN, score)
+
+	anomalies = h.scores
+	sort.Slice(anomalies, func(i, j int) bool { return h.less(anomalies[j], anomalies[i]) })
+	return
+}
+
+// anomalyHeap is a min-heap of scores, so that the least unusual of the top subjects is replaced first.
+type anomalyHeap struct {
+	scores []AnomalyScore
+}
+
+// less orders by score and then by subject, so that the top subjects do not depend on the reading order.
+func (h *anomalyHeap) less(a, b AnomalyScore) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+	return a.Subject > b.Subject
+}
+
+func (h *anomalyHeap) Len() int           { return len(h.scores) }
+func (h *anomalyHeap) Less(i, j int) bool { return h.less(h.scores[i], h.scores[j]) }
+func (h *anomalyHeap) Swap(i, j int)      { h.scores[i], h.scores[j] = h.scores[j], h.scores[i] }
+func (h *anomalyHeap) Push(x interface{}) { h.scores = append(h.scores, x.(AnomalyScore)) }
+func (h *anomalyHeap) Pop() interface{} {
+	last := h.scores[len(h.scores)-1]
+	h.scores = h.scores[:len(h.scores)-1]
+	return last
+}
@@ -0,0 +1,83 @@
+package schematree
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreAnomaly(t *testing.T) {
+	lines := []string{
+		`<http://ex.org/odd> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/City> .`,
+		`<http://ex.org/odd> <http://ex.org/name> "odd" .`,
+		`<http://ex.org/odd> <http://ex.org/birthDate> "2000" .`,
+	}
+	for i := 0; i < 10; i++ {
+		lines = append(lines,
+			fmt.Sprintf(`<http://ex.org/c%v> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/City> .`, i),
+			fmt.Sprintf(`<http://ex.org/c%v> <http://ex.org/name> "%v" .`, i, i),
+			fmt.Sprintf(`<http://ex.org/c%v> <http://ex.org/country> <http://ex.org/Germany> .`, i),
+		)
+	}
+	dataset := writeLines(t, "dataset.nt", lines...)
+	tree := New(true, 1)
+	tree.TwoPass(dataset, 0)
+	items := func(iris ...string) IList {
+		list := IList{}
+		for _, iri := range iris {
+			list = append(list, tree.PropMap[iri])
+		}
+		return list
+	}
+	rdfType := "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+
+	t.Run("scores", func(t *testing.T) {
+		usual := tree.ScoreAnomaly(items(rdfType, "t#http://ex.org/City", "http://ex.org/name", "http://ex.org/country"), nil)
+		assert.Equal(t, 4, usual.Items)
+		// only the country is a little surprising, as one city has none
+		assert.InDelta(t, -math.Log(11.0/12)/4, usual.Score, 1e-9)
+		assert.Equal(t, "http://ex.org/country", usual.Contributions[0].Item)
+
+		odd := tree.ScoreAnomaly(items(rdfType, "t#http://ex.org/City", "http://ex.org/name", "http://ex.org/birthDate"), nil)
+		assert.Greater(t, odd.Score, usual.Score)
+		assert.Equal(t, "http://ex.org/birthDate", odd.Contributions[0].Item)
+
+		unseen := tree.ScoreAnomaly(items("http://ex.org/country", "http://ex.org/birthDate"), nil)
+		assert.Greater(t, unseen.Score, odd.Score)
+		assert.False(t, math.IsInf(unseen.Score, 0))
+
+		unknown := tree.ScoreAnomaly(items("http://ex.org/name"), []string{"http://ex.org/shoeSize"})
+		assert.Equal(t, AnomalyContribution{"http://ex.org/shoeSize", 1.0 / 12, -math.Log(1.0 / 12)}, unknown.Contributions[0])
+		assert.Equal(t, 0, tree.ScoreAnomaly(nil, nil).Items)
+	})
+
+	t.Run("datasets", func(t *testing.T) {
+		check := writeLines(t, "check.nt",
+			`<http://ex.org/a> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/City> .`,
+			`<http://ex.org/a> <http://ex.org/name> "a" .`,
+			`<http://ex.org/a> <http://ex.org/country> <http://ex.org/Germany> .`,
+			`<http://ex.org/b> <http://ex.org/country> <http://ex.org/Germany> .`,
+			`<http://ex.org/b> <http://ex.org/birthDate> "2000" .`,
+			`<http://ex.org/c> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/City> .`,
+			`<http://ex.org/c> <http://ex.org/name> "c" .`,
+			`<http://ex.org/c> <http://ex.org/birthDate> "2000" .`,
+		)
+		anomalies, scored := tree.ScoreDataset(check, 0, 2, 1)
+		assert.EqualValues(t, 3, scored)
+		if assert.Len(t, anomalies, 2) {
+			assert.Equal(t, "http://ex.org/b", anomalies[0].Subject)
+			assert.Equal(t, "http://ex.org/c", anomalies[1].Subject)
+			assert.Len(t, anomalies[0].Contributions, 1)
+		}
+
+		// negative numbers count as zero
+		anomalies, _ = tree.ScoreDataset(check, 0, 2, -1)
+		if assert.Len(t, anomalies, 2) {
+			assert.Empty(t, anomalies[0].Contributions)
+		}
+		anomalies, _ = tree.ScoreDataset(check, 0, -1, 1)
+		assert.Empty(t, anomalies)
+	})
+}
@@ -0,0 +1,15 @@
+package schematree
+
+import "recommender/internal/parallel"
+
+// RecommendPropertyBatch recommends the top k property candidates (all if k is not positive, see RecommendTopK)
+// for each of the lists concurrently and calls emit with them in the order of the lists.
+func (tree *SchemaTree) RecommendPropertyBatch(lists []IList, k, workers int, emit func(i int, recs PropertyRecommendations)) {
+	results := make([]PropertyRecommendations, len(lists))
+	parallel.InOrder(len(lists), workers, func(i int) {
+		results[i] = tree.RecommendTopK(lists[i], k)
+	}, func(i int) {
+		emit(i, results[i])
+		results[i] = nil
+	})
+}
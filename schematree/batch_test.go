@@ -0,0 +1,24 @@
+package schematree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecommendPropertyBatch(t *testing.T) {
+	tree, err := Load(typedTreepath)
+	assert.NoError(t, err)
+	lists := make([]IList, len(topKInputs))
+	for i, input := range topKInputs {
+		for _, iri := range input {
+			lists[i] = append(lists[i], tree.PropMap[iri])
+		}
+	}
+	var emitted []int
+	tree.RecommendPropertyBatch(lists, 10, 2, func(i int, recs PropertyRecommendations) {
+		emitted = append(emitted, i)
+		assert.Equal(t, tree.RecommendTopK(append(IList(nil), lists[i]...), 10), recs)
+	})
+	assert.Equal(t, []int{0, 1, 2, 3}, emitted)
+}
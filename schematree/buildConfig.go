@@ -0,0 +1,321 @@
+package schematree
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	rio "recommender/io"
+)
+
+// BuildConfig describes how the triples of a dataset are turned into the properties and types of the subjects.
+// It is stored in the metadata of a tree, so that data that is read later, e.g. by the server, the evaluation or
+// delta updates, is interpreted the same way as during the construction. A configuration must not be changed
+// once it has been used.
+type BuildConfig struct {
+	TypePredicates []string        `json:"typePredicates"`      // predicates whose objects are the types of a subject
+	TypeRules      []TypeRule      `json:"typeRules,omitempty"` // further triples whose objects are types of their subject
+	Predicates     []PredicateRule `json:"predicates"`          // filters of the predicates, the first matching rule decides
+
+	// InverseProperties adds an inverse item `^predicate` to the transaction of the object of every triple with
+	// an IRI object, so that the transactions describe the incoming relations of the subjects, too.
+	InverseProperties bool `json:"inverseProperties,omitempty"`
+
+	// ValuePredicates are predicates whose values matter for the rest of the schema, e.g. the country of a
+	// city. Every triple with one of them adds the property=value item `v#predicate=value` to the transaction
+	// of its subject, in addition to the property itself, so that likely values can be recommended.
+	ValuePredicates []string `json:"valuePredicates,omitempty"`
+
+	compileOnce sync.Once
+	compiled    *compiledConfig
+}
+
+// PredicateRule includes or excludes the predicates that match it. A rule matches if the predicate starts with
+// Prefix and matches the regular expression Pattern. Empty fields match every predicate.
+type PredicateRule struct {
+	Exclude bool   `json:"exclude,omitempty"` // true to skip the matching predicates, false to read them
+	Prefix  string `json:"prefix,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// TypeRule makes the objects of matching triples types of their subject, in addition to the objects of the
+// type predicates. A rule matches if the predicate is Predicate and the object starts with ObjectPrefix and
+// matches the regular expression ObjectPattern. Empty fields match every triple.
+type TypeRule struct {
+	Predicate     string `json:"predicate,omitempty"`
+	ObjectPrefix  string `json:"objectPrefix,omitempty"`
+	ObjectPattern string `json:"objectPattern,omitempty"`
+}
+
+// typePredicates are the default predicates whose objects are the types of a subject.
+var typePredicates = []string{
+	"http://www.wikidata.org/prop/direct/P31",
+	"http://www.w3.org/1999/02/22-rdf-syntax-ns#type",
+	"http://dbpedia.org/ontology/type",
+}
+
+// NewBuildConfig returns the default configuration: the wikidata, RDF and DBpedia type predicates and
+// all predicates except the statement, qualifier and reference predicates of wikidata.
+func NewBuildConfig() *BuildConfig {
+	return &BuildConfig{
+		TypePredicates: append([]string(nil), typePredicates...),
+		Predicates: []PredicateRule{
+			{Prefix: "http://www.wikidata.org/prop/direct/"},
+			{Exclude: true, Prefix: "http://www.wikidata.org/prop/"},
+		},
+	}
+}
+
+// DefaultBuildConfig is the configuration of new trees, see New.
+var DefaultBuildConfig = NewBuildConfig()
+
+// LoadBuildConfig reads a configuration from a JSON file. Fields that are not given keep their defaults.
+func LoadBuildConfig(filePath string) (*BuildConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	// decoded into an empty configuration, as decoding into the default slices would merge their elements
+	config := &BuildConfig{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid build configuration %v: %w", filePath, err)
+	}
+	defaults := NewBuildConfig()
+	if config.TypePredicates == nil {
+		config.TypePredicates = defaults.TypePredicates
+	}
+	if config.Predicates == nil {
+		config.Predicates = defaults.Predicates
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid build configuration %v: %w", filePath, err)
+	}
+	return config, nil
+}
+
+// Validate checks that all regular expressions of the configuration compile and that the value predicates
+// can be told apart from their values.
+func (c *BuildConfig) Validate() error {
+	for _, iri := range c.ValuePredicates {
+		if strings.ContainsRune(iri, '=') {
+			return fmt.Errorf("value predicate %v must not contain '='", iri)
+		}
+	}
+	_, err := c.compile()
+	return err
+}
+
+// Config returns the build configuration of the tree. Trees that have been stored before the configuration
+// was part of the metadata were built with the default filters and the type predicates in their metadata.
+func (tree *SchemaTree) Config() *BuildConfig {
+	if tree.Meta.Config != nil {
+		return tree.Meta.Config
+	}
+	config := NewBuildConfig()
+	if len(tree.Meta.TypePredicates) > 0 {
+		config.TypePredicates = tree.Meta.TypePredicates
+	}
+	tree.Meta.Config = config
+	return config
+}
+
+// ReadsPredicate checks whether the configuration keeps a predicate as property. Inverse predicates are
+// read if the predicate itself is read and the configuration includes inverse properties. Property=value
+// items are read if their predicate is one of the value predicates.
+func (c *BuildConfig) ReadsPredicate(iri string) bool {
+	if strings.HasPrefix(iri, inversePrefix) {
+		return c.InverseProperties && c.rules().reads(iri[len(inversePrefix):])
+	}
+	if strings.HasPrefix(iri, valuePrefix) {
+		predicate, _, ok := (&IItem{Str: &iri}).ValueOf()
+		return ok && c.IsValuePredicate(predicate)
+	}
+	return c.rules().reads(iri)
+}
+
+// IsTypePredicate checks whether the objects of a predicate are read as types.
+func (c *BuildConfig) IsTypePredicate(iri string) bool {
+	for _, predicate := range c.TypePredicates {
+		if predicate == iri {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValuePredicate checks whether property=value items are built for a predicate.
+func (c *BuildConfig) IsValuePredicate(iri string) bool {
+	for _, predicate := range c.ValuePredicates {
+		if predicate == iri {
+			return true
+		}
+	}
+	return false
+}
+
+// equal checks whether two configurations read datasets the same way.
+func (c *BuildConfig) equal(other *BuildConfig) bool {
+	a, errA := json.Marshal(c)
+	b, errB := json.Marshal(other)
+	return errA == nil && errB == nil && string(a) == string(b)
+}
+
+// compiledConfig holds the regular expressions of a configuration.
+type compiledConfig struct {
+	predicates []compiledPredicateRule
+	typeRules  []compiledTypeRule
+}
+
+type compiledPredicateRule struct {
+	PredicateRule
+	pattern *regexp.Regexp
+}
+
+type compiledTypeRule struct {
+	TypeRule
+	pattern *regexp.Regexp
+}
+
+func (c *BuildConfig) compile() (*compiledConfig, error) {
+	compiled := &compiledConfig{}
+	for _, rule := range c.Predicates {
+		r := compiledPredicateRule{PredicateRule: rule}
+		if rule.Pattern != "" {
+			var err error
+			if r.pattern, err = regexp.Compile(rule.Pattern); err != nil {
+				return nil, err
+			}
+		}
+		compiled.predicates = append(compiled.predicates, r)
+	}
+	for _, rule := range c.TypeRules {
+		r := compiledTypeRule{TypeRule: rule}
+		if rule.ObjectPattern != "" {
+			var err error
+			if r.pattern, err = regexp.Compile(rule.ObjectPattern); err != nil {
+				return nil, err
+			}
+		}
+		compiled.typeRules = append(compiled.typeRules, r)
+	}
+	return compiled, nil
+}
+
+// rules compiles the configuration on first use. Invalid configurations are rejected by LoadBuildConfig and
+// Validate before they are used, so an error here is a programming error.
+func (c *BuildConfig) rules() *compiledConfig {
+	c.compileOnce.Do(func() {
+		compiled, err := c.compile()
+		if err != nil {
+			panic(fmt.Sprintf("invalid build configuration: %v", err))
+		}
+		c.compiled = compiled
+	})
+	return c.compiled
+}
+
+// reads applies the predicate rules. Predicates that match no rule are read.
+func (c *compiledConfig) reads(iri string) bool {
+	for _, rule := range c.predicates {
+		if strings.HasPrefix(iri, rule.Prefix) && (rule.pattern == nil || rule.pattern.MatchString(iri)) {
+			return !rule.Exclude
+		}
+	}
+	return true
+}
+
+// classifier applies a configuration to the triples of a dataset. The decisions are cached per predicate.
+// NOT thread-safe
+type classifier struct {
+	config     *compiledConfig
+	pMap       propMap
+	typeProps  map[string]bool
+	valueProps map[string]bool
+	predicates map[string]*predicateClass
+	inverses   map[string]*predicateClass
+	objects    *objectCollector // collects the ObjectStats of the properties, if set
+}
+
+// predicateClass is the cached decision for a predicate.
+type predicateClass struct {
+	item      *IItem // nil if the predicate is excluded
+	isType    bool
+	isValue   bool                // the values of the predicate become property=value items
+	typeRules []*compiledTypeRule // type rules that apply to the predicate
+	objects   *objectCounts       // statistics of the objects, see objectCollector
+}
+
+func newClassifier(config *BuildConfig, pMap propMap) *classifier {
+	if config == nil {
+		config = DefaultBuildConfig
+	}
+	c := &classifier{
+		config:     config.rules(),
+		pMap:       pMap,
+		typeProps:  make(map[string]bool),
+		valueProps: make(map[string]bool),
+		predicates: make(map[string]*predicateClass),
+		inverses:   make(map[string]*predicateClass),
+	}
+	for _, iri := range config.TypePredicates {
+		c.typeProps[iri] = true
+		c.classify([]byte(iri)) // type predicates are always known to the tree
+	}
+	for _, iri := range config.ValuePredicates {
+		c.valueProps[iri] = true
+	}
+	return c
+}
+
+func (c *classifier) classify(predicate []byte) *predicateClass {
+	if class, ok := c.predicates[string(predicate)]; ok {
+		return class
+	}
+	iri := string(predicate)
+	class := &predicateClass{isType: c.typeProps[iri], isValue: c.valueProps[iri]}
+	if c.config.reads(iri) {
+		class.item = c.pMap.get(iri)
+	}
+	for i, rule := range c.config.typeRules {
+		if rule.Predicate == "" || rule.Predicate == iri {
+			class.typeRules = append(class.typeRules, &c.config.typeRules[i])
+		}
+	}
+	c.predicates[iri] = class
+	return class
+}
+
+// classifyInverse returns the decision for the inverse of a predicate, which is read if the predicate is read.
+// Inverse predicates never have type objects.
+func (c *classifier) classifyInverse(predicate []byte) *predicateClass {
+	if class, ok := c.inverses[string(predicate)]; ok {
+		return class
+	}
+	iri := string(predicate)
+	class := &predicateClass{}
+	if c.config.reads(iri) {
+		class.item = c.pMap.get(inversePrefix + iri)
+	}
+	c.inverses[iri] = class
+	return class
+}
+
+// isTypeObject checks whether the object of a triple with the predicate is a type of its subject.
+func (class *predicateClass) isTypeObject(object *rio.Term) bool {
+	if class.isType {
+		return true
+	}
+	if len(class.typeRules) == 0 {
+		return false
+	}
+	identifier := object.Identifier()
+	for _, rule := range class.typeRules {
+		if strings.HasPrefix(identifier, rule.ObjectPrefix) && (rule.pattern == nil || rule.pattern.MatchString(identifier)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+package schematree
+
+import (
+	"path/filepath"
+	rio "recommender/io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		config := NewBuildConfig()
+		assert.True(t, config.ReadsPredicate("http://www.wikidata.org/prop/direct/P31"))
+		assert.False(t, config.ReadsPredicate("http://www.wikidata.org/prop/P31"))
+		assert.False(t, config.ReadsPredicate("http://www.wikidata.org/prop/statement/P31"))
+		assert.True(t, config.ReadsPredicate("http://schema.org/name"))
+	})
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		path := writeLines(t, "config.json", `{"predicates": [{"pattern": "("}]}`)
+		_, err := LoadBuildConfig(path)
+		assert.Error(t, err)
+	})
+
+	t.Run("older trees", func(t *testing.T) {
+		tree := &SchemaTree{Meta: Metadata{TypePredicates: []string{"http://schema.org/additionalType"}}}
+		assert.Equal(t, []string{"http://schema.org/additionalType"}, tree.Config().TypePredicates)
+		assert.False(t, tree.Config().ReadsPredicate("http://www.wikidata.org/prop/P31"))
+	})
+
+	dataset := writeLines(t, "dataset.nt",
+		`<http://ex.org/a> <http://schema.org/additionalType> <http://ex.org/City> .`,
+		`<http://ex.org/a> <http://ex.org/kind> <http://ex.org/class/Place> .`,
+		`<http://ex.org/a> <http://ex.org/kind> <http://ex.org/other/Thing> .`,
+		`<http://ex.org/a> <http://ex.org/name> "a" .`,
+		`<http://ex.org/a> <http://ex.org/internal/id> "1" .`,
+		`<http://ex.org/a> <http://other.org/p> "1" .`,
+		`<http://ex.org/b> <http://ex.org/name> "b" .`,
+		`<http://ex.org/b> <http://ex.org/internalNote> "1" .`,
+	)
+	configFile := writeLines(t, "config.json", `{
+		"typePredicates": ["http://schema.org/additionalType"],
+		"typeRules": [{"predicate": "http://ex.org/kind", "objectPrefix": "http://ex.org/class/"}],
+		"predicates": [
+			{"exclude": true, "prefix": "http://ex.org/internal"},
+			{"prefix": "http://ex.org/"},
+			{"prefix": "http://schema.org/"},
+			{"exclude": true}
+		]
+	}`)
+	config, err := LoadBuildConfig(configFile)
+	assert.NoError(t, err)
+
+	DefaultBuildConfig = config
+	defer func() { DefaultBuildConfig = NewBuildConfig() }()
+	tree := New(true, 1)
+	tree.TwoPass(dataset, 0)
+
+	t.Run("construction", func(t *testing.T) {
+		assert.Contains(t, tree.PropMap, "http://ex.org/name")
+		assert.Contains(t, tree.PropMap, "http://ex.org/kind")
+		assert.Contains(t, tree.PropMap, "t#http://ex.org/City")
+		assert.Contains(t, tree.PropMap, "t#http://ex.org/class/Place")
+		assert.NotContains(t, tree.PropMap, "t#http://ex.org/other/Thing")
+		assert.NotContains(t, tree.PropMap, "http://ex.org/internal/id")
+		assert.NotContains(t, tree.PropMap, "http://ex.org/internalNote")
+		assert.NotContains(t, tree.PropMap, "http://other.org/p")
+		assert.NotContains(t, tree.PropMap, "http://www.wikidata.org/prop/direct/P31")
+		assert.EqualValues(t, 2, tree.PropMap["http://ex.org/name"].TotalCount)
+	})
+
+	t.Run("stored in the metadata", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tree.bin")
+		assert.NoError(t, tree.Save(path))
+		DefaultBuildConfig = NewBuildConfig()
+
+		loaded, err := Load(path)
+		assert.NoError(t, err)
+		assert.True(t, config.equal(loaded.Config()))
+		assert.Equal(t, config.TypePredicates, loaded.Meta.TypePredicates)
+
+		// the server interprets inputs with the configuration of the tree
+		list := loaded.BuildPropertyList([]string{"http://ex.org/name", "http://other.org/p"}, []string{"http://ex.org/City"})
+		assert.Len(t, list, 2)
+
+		// and so do delta updates
+		delta := writeLines(t, "delta.txt",
+			`+ <http://ex.org/c> <http://ex.org/kind> <http://ex.org/class/Place> .`,
+			`+ <http://ex.org/c> <http://ex.org/internal/id> "2" .`,
+		)
+		_, err = loaded.ApplyDelta(delta, 0)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 2, loaded.PropMap["t#http://ex.org/class/Place"].TotalCount)
+		assert.NotContains(t, loaded.PropMap, "http://ex.org/internal/id")
+	})
+}
+
+func TestInverseProperties(t *testing.T) {
+	dataset := writeLines(t, "dataset.nt",
+		`<http://ex.org/book1> <http://ex.org/author> <http://ex.org/alice> .`,
+		`<http://ex.org/alice> <http://ex.org/name> "Alice" .`,
+		`<http://ex.org/book2> <http://ex.org/author> <http://ex.org/alice> .`,
+		`<http://ex.org/book2> <http://ex.org/author> <http://ex.org/bob> .`,
+		`<http://ex.org/book2> <http://ex.org/cites> <http://ex.org/unknown> .`,
+		`<http://ex.org/bob> <http://ex.org/name> "Bob" .`,
+		`<http://ex.org/carol> <http://ex.org/name> "Carol" .`,
+	)
+	DefaultBuildConfig = NewBuildConfig()
+	DefaultBuildConfig.InverseProperties = true
+	defer func() { DefaultBuildConfig = NewBuildConfig() }()
+	defer rio.RemoveGroupedFiles()
+
+	tree := New(false, 1)
+	tree.TwoPass(dataset, 0)
+	assert.True(t, tree.Config().InverseProperties)
+
+	t.Run("construction", func(t *testing.T) {
+		// the objects that are not described in the dataset are no subjects
+		assert.EqualValues(t, 5, tree.Root.Support)
+		assert.EqualValues(t, 2, tree.PropMap["^http://ex.org/author"].TotalCount)
+		assert.NotContains(t, tree.PropMap, "^http://ex.org/cites")
+		assert.True(t, tree.PropMap["^http://ex.org/author"].IsInverse())
+		assert.False(t, tree.PropMap["http://ex.org/author"].IsInverse())
+		assert.Equal(t, map[string]uint64{
+			"http://ex.org/author":                     1,
+			"http://ex.org/author http://ex.org/cites": 1,
+			"^http://ex.org/author http://ex.org/name": 2,
+			"http://ex.org/name":                       1,
+		}, transactionsOf(tree))
+	})
+
+	t.Run("recommendations by direction", func(t *testing.T) {
+		recs := tree.RecommendProperty(tree.BuildPropertyList([]string{"http://ex.org/name"}, nil))
+		assert.Contains(t, asMap(recs), "^http://ex.org/author")
+
+		incoming := asMap(recs.FilterDirection(Incoming))
+		assert.Equal(t, map[string]float64{"^http://ex.org/author": 2.0 / 3}, incoming)
+		assert.NotContains(t, asMap(recs.FilterDirection(Outgoing)), "^http://ex.org/author")
+
+		list := tree.BuildPropertyList([]string{"^http://ex.org/author"}, nil)
+		assert.Len(t, list, 1)
+		assert.Contains(t, asMap(tree.RecommendProperty(list)), "http://ex.org/name")
+
+		_, err := ParseDirection("sideways")
+		assert.Error(t, err)
+		direction, err := ParseDirection("incoming")
+		assert.NoError(t, err)
+		assert.Equal(t, Incoming, direction)
+	})
+
+	t.Run("delta updates", func(t *testing.T) {
+		_, err := tree.ApplyDelta(dataset, 0)
+		assert.Equal(t, ErrInverseDelta, err)
+	})
+}
+
+func TestValuePredicates(t *testing.T) {
+	dataset := writeLines(t, "dataset.nt",
+		`<http://ex.org/a> <http://ex.org/country> <http://ex.org/Germany> .`,
+		`<http://ex.org/a> <http://ex.org/mayor> <http://ex.org/x> .`,
+		`<http://ex.org/a> <http://ex.org/name> "a" .`,
+		`<http://ex.org/b> <http://ex.org/country> <http://ex.org/Germany> .`,
+		`<http://ex.org/b> <http://ex.org/mayor> <http://ex.org/y> .`,
+		`<http://ex.org/b> <http://ex.org/name> "b" .`,
+		`<http://ex.org/c> <http://ex.org/country> <http://ex.org/France> .`,
+		`<http://ex.org/c> <http://ex.org/name> "c" .`,
+		`<http://ex.org/d> <http://ex.org/name> "d" .`,
+	)
+	DefaultBuildConfig = NewBuildConfig()
+	DefaultBuildConfig.ValuePredicates = []string{"http://ex.org/country"}
+	defer func() { DefaultBuildConfig = NewBuildConfig() }()
+
+	tree := New(false, 1)
+	tree.TwoPass(dataset, 0)
+	germany := "v#http://ex.org/country=http://ex.org/Germany"
+	france := "v#http://ex.org/country=http://ex.org/France"
+
+	t.Run("construction", func(t *testing.T) {
+		assert.EqualValues(t, 3, tree.PropMap["http://ex.org/country"].TotalCount)
+		assert.EqualValues(t, 2, tree.PropMap[germany].TotalCount)
+		assert.NotContains(t, tree.PropMap, "v#http://ex.org/mayor=http://ex.org/x")
+
+		predicate, value, ok := tree.PropMap[germany].ValueOf()
+		assert.True(t, ok)
+		assert.Equal(t, "http://ex.org/country", predicate)
+		assert.Equal(t, "http://ex.org/Germany", value)
+		assert.True(t, tree.PropMap[germany].IsValue())
+		assert.False(t, tree.PropMap[germany].IsProp())
+		_, _, ok = tree.PropMap["http://ex.org/country"].ValueOf()
+		assert.False(t, ok)
+	})
+
+	t.Run("value recommendations", func(t *testing.T) {
+		props := asMap(tree.RecommendProperty(tree.BuildPropertyList([]string{"http://ex.org/name"}, nil)))
+		assert.Contains(t, props, "http://ex.org/country")
+		assert.NotContains(t, props, germany)
+
+		// the same holds for the empty input
+		props = asMap(tree.RecommendProperty(IList{}))
+		assert.InDelta(t, 0.75, props["http://ex.org/country"], 1e-9)
+		assert.NotContains(t, props, germany)
+		assert.NotContains(t, asMap(tree.RecommendPropertiesAndTypes(IList{})), germany)
+
+		values := func(properties ...string) map[string]float64 {
+			return asMap(tree.RecommendValues(tree.BuildPropertyList(properties, nil), "http://ex.org/country"))
+		}
+		assert.Equal(t, map[string]float64{germany: 0.5, france: 0.25}, values())
+		assert.Equal(t, map[string]float64{germany: 0.5, france: 0.25}, values("http://ex.org/name"))
+		assert.Equal(t, map[string]float64{germany: 1}, values("http://ex.org/mayor"))
+		assert.Empty(t, asMap(tree.RecommendValues(IList{tree.PropMap["http://ex.org/name"]}, "http://ex.org/mayor")))
+
+		// known values are part of the input
+		list := tree.BuildPropertyList([]string{germany}, nil)
+		assert.Len(t, list, 1)
+		assert.Equal(t, 1.0, asMap(tree.RecommendProperty(list))["http://ex.org/mayor"])
+
+		compact := New(false, 1)
+		compact.TwoPass(dataset, 0)
+		compact.Compact()
+		assert.Equal(t, values("http://ex.org/name"),
+			asMap(compact.RecommendValues(compact.BuildPropertyList([]string{"http://ex.org/name"}, nil), "http://ex.org/country")))
+	})
+
+	t.Run("invalid value predicates", func(t *testing.T) {
+		config := NewBuildConfig()
+		config.ValuePredicates = []string{"http://ex.org/p?a=b"}
+		assert.Error(t, config.Validate())
+	})
+}
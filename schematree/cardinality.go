@@ -0,0 +1,215 @@
+package schematree
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// CardinalityBounds are the lower bounds of the buckets of a Cardinality histogram. Bucket i counts the
+// subjects with CardinalityBounds[i] up to CardinalityBounds[i+1]-1 values, the last bucket has no upper bound.
+var CardinalityBounds = []uint32{1, 2, 3, 4, 6, 11, 21, 51, 101}
+
+// Cardinality is a histogram of the number of values that the subjects with a property have for it.
+type Cardinality struct {
+	Subjects []uint64 `json:"subjects"` // number of subjects per bucket, see CardinalityBounds
+	Values   uint64   `json:"values"`   // number of values of all subjects
+}
+
+// cardinalityShare is the share of the subjects that the typical range of a Cardinality covers.
+const cardinalityShare = 0.8
+
+// CardinalityOf returns the cardinality histogram of a property, nil if the tree has none.
+func (tree *SchemaTree) CardinalityOf(property *IItem) *Cardinality {
+	return tree.Meta.Cardinalities[*property.Str]
+}
+
+// cardinalityBucket returns the index of the bucket of a number of values.
+func cardinalityBucket(count uint32) int {
+	i := len(CardinalityBounds) - 1
+	for i > 0 && count < CardinalityBounds[i] {
+		i--
+	}
+	return i
+}
+
+// Total returns the number of subjects with the property.
+func (c *Cardinality) Total() (total uint64) {
+	for _, n := range c.Subjects {
+		total += n
+	}
+	return
+}
+
+// Share returns the fraction of subjects that have about as many values as given, i.e. the fraction of
+// the bucket of count. Rare multiplicities have a small share.
+func (c *Cardinality) Share(count uint32) float64 {
+	total := c.Total()
+	if total == 0 || count == 0 {
+		return 0
+	}
+	return float64(c.Subjects[cardinalityBucket(count)]) / float64(total)
+}
+
+// Bound returns the largest number of values of all but 1-share of the subjects, rounded up to the end of its
+// bucket. It is zero if the bound lies in the last bucket, which has no upper bound.
+func (c *Cardinality) Bound(share float64) uint32 {
+	total := c.Total()
+	if total == 0 {
+		return 0
+	}
+	var covered uint64
+	for i, n := range c.Subjects {
+		covered += n
+		if float64(covered) >= share*float64(total) {
+			if i+1 < len(CardinalityBounds) {
+				return CardinalityBounds[i+1] - 1
+			}
+			break
+		}
+	}
+	return 0
+}
+
+// Typical returns the smallest range of values that covers most subjects, see cardinalityShare.
+// The upper bound is zero if the range is unbounded.
+func (c *Cardinality) Typical() (low uint32, high uint32) {
+	total := c.Total()
+	if total == 0 {
+		return 0, 0
+	}
+	from, to := 0, len(c.Subjects)-1
+	for i := range c.Subjects {
+		var covered uint64
+		for j := i; j < len(c.Subjects); j++ {
+			covered += c.Subjects[j]
+			if float64(covered) >= cardinalityShare*float64(total) {
+				if j-i < to-from {
+					from, to = i, j
+				}
+				break
+			}
+		}
+	}
+	low = CardinalityBounds[from]
+	if to+1 < len(CardinalityBounds) {
+		high = CardinalityBounds[to+1] - 1
+	}
+	return
+}
+
+// String describes the typical number of values, e.g. "usually 1 value" or "typically 3-10 values".
+func (c *Cardinality) String() string {
+	low, high := c.Typical()
+	switch {
+	case low == 0:
+		return "no values"
+	case high == 0:
+		return fmt.Sprintf("typically %v or more values", low)
+	case low == 1 && high == 1:
+		return "usually 1 value"
+	case low == high:
+		return fmt.Sprintf("usually %v values", low)
+	}
+	return fmt.Sprintf("typically %v-%v values", low, high)
+}
+
+// merge adds the histogram of another tree.
+func (c *Cardinality) merge(other *Cardinality) {
+	for i, n := range other.Subjects {
+		if i < len(c.Subjects) {
+			c.Subjects[i] += n
+		}
+	}
+	c.Values += other.Values
+}
+
+// updateCardinalities counts the values of a subject that is added after the construction, or takes them out
+// of the histograms again for a removed subject. Trees without histograms are left without them.
+func (tree *SchemaTree) updateCardinalities(s *SubjectSummary, removed bool) {
+	if tree.Meta.Cardinalities == nil {
+		return
+	}
+	for item, count := range s.Properties {
+		if !item.IsProp() || count == 0 {
+			continue
+		}
+		c, ok := tree.Meta.Cardinalities[*item.Str]
+		if !ok {
+			if removed {
+				continue
+			}
+			c = &Cardinality{Subjects: make([]uint64, len(CardinalityBounds))}
+			tree.Meta.Cardinalities[*item.Str] = c
+		}
+		bucket := cardinalityBucket(count)
+		if !removed {
+			c.Subjects[bucket]++
+			c.Values += uint64(count)
+			continue
+		}
+		if c.Subjects[bucket] > 0 {
+			c.Subjects[bucket]--
+		}
+		if c.Values >= uint64(count) {
+			c.Values -= uint64(count)
+		}
+	}
+}
+
+// cardinalityCollector counts the number of values per subject and property while a dataset is read.
+// thread-safe
+type cardinalityCollector struct {
+	properties sync.Map // *IItem -> *cardinalityCounts
+}
+
+type cardinalityCounts struct {
+	subjects []uint64
+	values   uint64
+}
+
+// add counts the properties of a subject. Types and property=value items are left out.
+func (c *cardinalityCollector) add(s *SubjectSummary) {
+	for item, count := range s.Properties {
+		if !item.IsProp() {
+			continue
+		}
+		counts, ok := c.properties.Load(item)
+		if !ok {
+			counts, _ = c.properties.LoadOrStore(item, &cardinalityCounts{subjects: make([]uint64, len(CardinalityBounds))})
+		}
+		cc := counts.(*cardinalityCounts)
+		atomic.AddUint64(&cc.subjects[cardinalityBucket(count)], 1)
+		atomic.AddUint64(&cc.values, uint64(count))
+	}
+}
+
+// cardinalities returns the histograms of all properties.
+func (c *cardinalityCollector) cardinalities() map[string]*Cardinality {
+	result := make(map[string]*Cardinality)
+	c.properties.Range(func(item, counts interface{}) bool {
+		cc := counts.(*cardinalityCounts)
+		result[*item.(*IItem).Str] = &Cardinality{Subjects: cc.subjects, Values: cc.values}
+		return true
+	})
+	return result
+}
+
+// mergeCardinalities adds the histograms of another tree, e.g. when trees are merged.
+func mergeCardinalities(into map[string]*Cardinality, other map[string]*Cardinality) map[string]*Cardinality {
+	if len(other) == 0 {
+		return into
+	}
+	if into == nil {
+		into = make(map[string]*Cardinality, len(other))
+	}
+	for iri, o := range other {
+		c, ok := into[iri]
+		if !ok {
+			c = &Cardinality{Subjects: make([]uint64, len(CardinalityBounds))}
+			into[iri] = c
+		}
+		c.merge(o)
+	}
+	return into
+}
@@ -0,0 +1,82 @@
+package schematree
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCardinality(t *testing.T) {
+	histogram := func(counts ...uint64) *Cardinality {
+		c := &Cardinality{Subjects: make([]uint64, len(CardinalityBounds))}
+		copy(c.Subjects, counts)
+		return c
+	}
+
+	t.Run("typical number of values", func(t *testing.T) {
+		assert.Equal(t, "usually 1 value", histogram(95, 5).String())
+		assert.Equal(t, "usually 2 values", histogram(10, 90).String())
+		assert.Equal(t, "typically 3-10 values", histogram(5, 5, 30, 30, 30).String())
+		assert.Equal(t, "typically 101 or more values", histogram(0, 0, 0, 0, 0, 0, 0, 0, 7).String())
+		assert.Equal(t, "no values", histogram().String())
+		assert.Equal(t, []int{0, 1, 2, 3, 3, 4, 4, 8}, []int{cardinalityBucket(1), cardinalityBucket(2),
+			cardinalityBucket(3), cardinalityBucket(4), cardinalityBucket(5), cardinalityBucket(6),
+			cardinalityBucket(10), cardinalityBucket(1000)})
+	})
+
+	t.Run("share of a multiplicity", func(t *testing.T) {
+		c := histogram(95, 5)
+		assert.Equal(t, 0.95, c.Share(1))
+		assert.Equal(t, 0.05, c.Share(2))
+		assert.Equal(t, 0.0, c.Share(7))
+		assert.Equal(t, 0.0, c.Share(0))
+	})
+
+	t.Run("upper bound", func(t *testing.T) {
+		assert.EqualValues(t, 1, histogram(100).Bound(1))
+		assert.EqualValues(t, 2, histogram(95, 5).Bound(1))
+		assert.EqualValues(t, 1, histogram(95, 5).Bound(0.95))
+		assert.EqualValues(t, 10, histogram(5, 5, 30, 30, 30).Bound(0.9))
+		assert.EqualValues(t, 0, histogram(90, 0, 0, 0, 0, 0, 0, 0, 10).Bound(0.95))
+	})
+
+	lines := []string{}
+	for i := 0; i < 12; i++ {
+		lines = append(lines, fmt.Sprintf(`<http://ex.org/a> <http://ex.org/alias> "%v" .`, i))
+	}
+	lines = append(lines,
+		`<http://ex.org/a> <http://ex.org/name> "a" .`,
+		`<http://ex.org/b> <http://ex.org/name> "b" .`,
+		`<http://ex.org/b> <http://ex.org/alias> "1" .`,
+		`<http://ex.org/b> <http://ex.org/alias> "2" .`,
+		`<http://ex.org/b> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/Thing> .`,
+		`<http://ex.org/c> <http://ex.org/name> "c" .`,
+	)
+	dataset := writeLines(t, "dataset.nt", lines...)
+	withValues := func(values uint64, c *Cardinality) *Cardinality {
+		c.Values = values
+		return c
+	}
+	expected := map[string]*Cardinality{
+		"http://ex.org/name":                              withValues(3, histogram(3)),
+		"http://ex.org/alias":                             withValues(14, histogram(0, 1, 0, 0, 0, 1)),
+		"http://www.w3.org/1999/02/22-rdf-syntax-ns#type": withValues(1, histogram(1)),
+	}
+
+	t.Run("construction", func(t *testing.T) {
+		tree := New(true, 1)
+		tree.TwoPass(dataset, 0)
+		assert.Equal(t, expected, tree.Meta.Cardinalities)
+		assert.Equal(t, expected["http://ex.org/name"], tree.CardinalityOf(tree.PropMap["http://ex.org/name"]))
+		assert.Nil(t, tree.CardinalityOf(tree.PropMap["t#http://ex.org/Thing"]))
+
+		single := New(true, 1)
+		single.SinglePass(dataset, 0, 2)
+		assert.Equal(t, expected, single.Meta.Cardinalities)
+
+		assert.NoError(t, single.Merge(tree))
+		assert.Equal(t, histogram(6).Subjects, single.Meta.Cardinalities["http://ex.org/name"].Subjects)
+		assert.EqualValues(t, 28, single.Meta.Cardinalities["http://ex.org/alias"].Values)
+	})
+}
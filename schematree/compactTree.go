@@ -0,0 +1,432 @@
+package schematree
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"unsafe"
+)
+
+// CompactLayout makes TwoPass build new trees in the compact layout and Load convert loaded trees into it.
+// Compact trees keep their nodes in the flat arrays of the memory-mapped layout (see flatTree.go) and need
+// a fraction of the memory of SchemaNode pointers, but they are read-only after the construction.
+var CompactLayout bool
+
+// compactBuilder is the mutable struct-of-arrays representation of a tree under construction. Nodes are
+// referenced by 32-bit indexes, the root is node 0. The children of a node form a linked list of
+// siblings sorted by the sort order of their items, except for the children of the root, which are
+// looked up by item, and the children of wide nodes, which are looked up in a map and kept unsorted.
+// Index 0 doubles as the end of a list, as the root is never a child.
+// NOT thread-safe, concurrent constructions use one builder per goroutine, see builderPool
+type compactBuilder struct {
+	item         []uint32 // sort order of the item of every node
+	support      supportArray
+	firstChild   []uint32
+	nextSibling  []uint32
+	rootChildren []uint32                     // child of the root for every item, by sort order
+	wide         map[uint32]map[uint32]uint32 // child of a wide node for every item, by node
+}
+
+// wideChildren is the number of children above which the children of a node are looked up in a map instead
+// of walking the list of siblings, e.g. for the type of a typed tree with millions of t# children.
+const wideChildren = 32
+
+func newCompactBuilder(numItems int, root *IItem) *compactBuilder {
+	b := &compactBuilder{rootChildren: make([]uint32, numItems), wide: make(map[uint32]map[uint32]uint32)}
+	b.newNode(root.SortOrder)
+	return b
+}
+
+// newNode appends a node without linking it to its parent.
+func (b *compactBuilder) newNode(item uint32) uint32 {
+	if len(b.item) >= 1<<32-1 {
+		panic("the compact layout is limited to 2^32-1 nodes")
+	}
+	b.item = append(b.item, item)
+	b.support.append(0)
+	b.firstChild = append(b.firstChild, 0)
+	b.nextSibling = append(b.nextSibling, 0)
+	return uint32(len(b.item) - 1)
+}
+
+// getOrCreateChild returns the child of a node for an item, creating it if necessary.
+// NOT thread-safe
+func (b *compactBuilder) getOrCreateChild(node, item uint32) uint32 {
+	if node == 0 {
+		for int(item) >= len(b.rootChildren) {
+			b.rootChildren = append(b.rootChildren, 0)
+		}
+		if b.rootChildren[item] == 0 {
+			b.rootChildren[item] = b.newNode(item)
+		}
+		return b.rootChildren[item]
+	}
+	if index, ok := b.wide[node]; ok {
+		if child, ok := index[item]; ok {
+			return child
+		}
+		child := b.newNode(item)
+		b.nextSibling[child] = b.firstChild[node]
+		b.firstChild[node] = child
+		index[item] = child
+		return child
+	}
+
+	// walk the sorted list of siblings up to the position of the item
+	prev, cur := uint32(0), b.firstChild[node]
+	siblings := 0
+	for cur != 0 && b.item[cur] < item {
+		prev, cur = cur, b.nextSibling[cur]
+		siblings++
+	}
+	if cur != 0 && b.item[cur] == item {
+		return cur
+	}
+	child := b.newNode(item)
+	b.nextSibling[child] = cur
+	if prev == 0 {
+		b.firstChild[node] = child
+	} else {
+		b.nextSibling[prev] = child
+	}
+	for c := cur; c != 0 && siblings < wideChildren; c = b.nextSibling[c] {
+		siblings++
+	}
+	if siblings >= wideChildren {
+		index := make(map[uint32]uint32)
+		for c := b.firstChild[node]; c != 0; c = b.nextSibling[c] {
+			index[b.item[c]] = c
+		}
+		b.wide[node] = index
+	}
+	return child
+}
+
+// insert adds a property set, which has to be sorted, count times.
+// NOT thread-safe
+func (b *compactBuilder) insert(properties IList, count uint64) {
+	node := uint32(0)
+	b.support.add(node, count)
+	for _, prop := range properties {
+		node = b.getOrCreateChild(node, prop.SortOrder)
+		b.support.add(node, count)
+	}
+}
+
+// appendChildren appends the children of a node, sorted by the sort order of their items.
+func (b *compactBuilder) appendChildren(node uint32, children []uint32) []uint32 {
+	if node == 0 {
+		for _, child := range b.rootChildren {
+			if child != 0 {
+				children = append(children, child)
+			}
+		}
+		return children
+	}
+	start := len(children)
+	for child := b.firstChild[node]; child != 0; child = b.nextSibling[child] {
+		children = append(children, child)
+	}
+	if _, ok := b.wide[node]; ok {
+		added := children[start:]
+		sort.Slice(added, func(i, j int) bool { return b.item[added[i]] < b.item[added[j]] })
+	}
+	return children
+}
+
+// freeze numbers the nodes breadth-first, which packs the children of every node next to each other, and
+// returns the resulting flat arrays. The builder must not be used afterwards.
+func (b *compactBuilder) freeze(items []*IItem) *flatTree {
+	return freezeBuilders([]*compactBuilder{b}, items)
+}
+
+// builderRef references a node of one of the builders that are frozen together.
+type builderRef struct {
+	builder, node uint32
+}
+
+// freezeBuilders merges the trees of several builders into one tree in flat arrays. The nodes are numbered
+// breadth-first, which packs the children of every node next to each other. Every flat node stands for the
+// nodes of the builders with the same path, its support is the sum of theirs. The builders must not be used
+// afterwards.
+func freezeBuilders(builders []*compactBuilder, items []*IItem) *flatTree {
+	n := 0
+	for _, b := range builders {
+		n += len(b.item)
+	}
+	flat := &flatTree{
+		items:       items,
+		nodeItem:    make([]uint32, 0, len(builders[0].item)),
+		nodeParent:  make([]uint32, 0, len(builders[0].item)),
+		nodeSupport: newSupportArray(0, false),
+	}
+
+	// the builder nodes of every flat node, grouped by flat node
+	refs := make([]builderRef, 0, n)
+	starts := []int{0}
+	for i := range builders {
+		refs = append(refs, builderRef{uint32(i), 0})
+	}
+	starts = append(starts, len(refs))
+	flat.nodeParent = append(flat.nodeParent, 0)
+
+	var children []uint32
+	var merged []builderRef
+	for i := 0; i < len(starts)-1; i++ {
+		members := refs[starts[i]:starts[i+1]]
+		var support uint64
+		merged = merged[:0]
+		for _, ref := range members {
+			b := builders[ref.builder]
+			support += b.support.get(ref.node)
+			children = b.appendChildren(ref.node, children[:0])
+			for _, child := range children {
+				merged = append(merged, builderRef{ref.builder, child})
+			}
+		}
+		flat.nodeItem = append(flat.nodeItem, builders[members[0].builder].item[members[0].node])
+		flat.nodeSupport.append(support)
+		flat.childStarts = append(flat.childStarts, uint32(len(starts)-1))
+
+		// children of the builders with the same item become one flat node
+		itemOf := func(ref builderRef) uint32 { return builders[ref.builder].item[ref.node] }
+		if len(members) > 1 {
+			sort.SliceStable(merged, func(a, b int) bool { return itemOf(merged[a]) < itemOf(merged[b]) })
+		}
+		for j, ref := range merged {
+			refs = append(refs, ref)
+			if j == 0 || itemOf(ref) != itemOf(merged[j-1]) {
+				starts = append(starts, len(refs))
+				flat.nodeParent = append(flat.nodeParent, uint32(i))
+			} else {
+				starts[len(starts)-1] = len(refs)
+			}
+		}
+	}
+	flat.childStarts = append(flat.childStarts, uint32(len(flat.nodeItem)))
+	// release the builder arrays before the traversal lists are allocated
+	for _, b := range builders {
+		b.item, b.support, b.firstChild, b.nextSibling, b.rootChildren, b.wide = nil, supportArray{}, nil, nil, nil, nil
+	}
+	refs, starts = nil, nil
+
+	flat.buildTraversalLists()
+	return flat
+}
+
+// builderPool hands the builders of a concurrent construction out to the inserting goroutines, so that every
+// builder is only used by one goroutine at a time and insertions do not wait for each other. The builders are
+// merged when the pool is frozen.
+type builderPool struct {
+	pool     chan *compactBuilder
+	builders []*compactBuilder
+}
+
+// newBuilderPool creates a pool of shards builders, one per CPU if shards is below one.
+func newBuilderPool(shards int, root *IItem) *builderPool {
+	if shards < 1 {
+		shards = runtime.NumCPU()
+	}
+	p := &builderPool{pool: make(chan *compactBuilder, shards)}
+	for i := 0; i < shards; i++ {
+		b := newCompactBuilder(0, root) // the children of the root are added as they come
+		p.builders = append(p.builders, b)
+		p.pool <- b
+	}
+	return p
+}
+
+// insert adds a property set, which has to be sorted, count times to one of the builders.
+// thread-safe
+func (p *builderPool) insert(properties IList, count uint64) {
+	b := <-p.pool
+	b.insert(properties, count)
+	p.pool <- b
+}
+
+// freeze merges the builders into flat arrays. The pool must not be used afterwards.
+func (p *builderPool) freeze(items []*IItem) *flatTree {
+	flat := freezeBuilders(p.builders, items)
+	p.builders = nil
+	return flat
+}
+
+// buildTraversalLists groups the nodes by item.
+func (flat *flatTree) buildTraversalLists() {
+	flat.traversalStarts = make([]uint32, len(flat.items)+1)
+	for _, item := range flat.nodeItem {
+		flat.traversalStarts[item+1]++
+	}
+	for i := 1; i < len(flat.traversalStarts); i++ {
+		flat.traversalStarts[i] += flat.traversalStarts[i-1]
+	}
+	flat.traversal = make([]uint32, len(flat.nodeItem))
+	next := append([]uint32(nil), flat.traversalStarts[:len(flat.items)]...)
+	for node, item := range flat.nodeItem {
+		flat.traversal[next[item]] = uint32(node)
+		next[item]++
+	}
+}
+
+// itemsBySortOrder lists all items of the tree, indexed by their sort order.
+func (tree *SchemaTree) itemsBySortOrder() []*IItem {
+	items := make([]*IItem, len(tree.PropMap))
+	for _, item := range tree.PropMap {
+		items[item.SortOrder] = item
+	}
+	return items
+}
+
+// toFlat copies the SchemaNodes of the tree into flat arrays.
+func (tree *SchemaTree) toFlat() *flatTree {
+	b := newCompactBuilder(len(tree.PropMap), tree.Root.ID)
+	var copyChildren func(node *SchemaNode, index uint32)
+	copyChildren = func(node *SchemaNode, index uint32) {
+		for _, child := range node.Children {
+			childIndex := b.getOrCreateChild(index, child.ID.SortOrder)
+			b.support.set(childIndex, child.Support)
+			copyChildren(child, childIndex)
+		}
+	}
+	b.support.set(0, tree.Root.Support)
+	copyChildren(&tree.Root, 0)
+	return b.freeze(tree.itemsBySortOrder())
+}
+
+// Compact converts the nodes of a loaded or built tree into the compact layout and releases the
+// SchemaNodes. The memory usage is reported before and after the conversion.
+func (tree *SchemaTree) Compact() {
+	if tree.flat != nil {
+		return
+	}
+	fmt.Println("Converting the schematree to the compact layout...")
+	tree.PrintNodeMemUsage()
+	PrintMemUsage()
+
+	tree.flat = tree.toFlat()
+	tree.Root.Children = nil
+	for _, item := range tree.PropMap {
+		item.traversalPointer = nil
+	}
+	runtime.GC()
+
+	tree.PrintNodeMemUsage()
+	PrintMemUsage()
+}
+
+// finishCompact replaces the builder of a tree built in the compact layout by the frozen arrays.
+func (tree *SchemaTree) finishCompact() {
+	if tree.builder == nil {
+		return
+	}
+	tree.flat = tree.builder.freeze(tree.itemsBySortOrder())
+	tree.builder = nil
+	runtime.GC()
+	tree.PrintNodeMemUsage()
+}
+
+// NodeMemUsage returns the number of nodes of the tree and the number of bytes used by them, not counting
+// the items. For the pointer layout, the size of the SchemaNodes and their child slices is counted.
+func (tree *SchemaTree) NodeMemUsage() (nodes uint64, bytes uint64) {
+	if tree.flat != nil {
+		f := tree.flat
+		nodes = uint64(len(f.nodeItem))
+		bytes = 4*uint64(len(f.nodeItem)+len(f.nodeParent)+len(f.childStarts)+len(f.traversalStarts)+
+			len(f.traversal)) + f.nodeSupport.bytes()
+		return
+	}
+	var count func(node *SchemaNode)
+	count = func(node *SchemaNode) {
+		nodes++
+		bytes += uint64(unsafe.Sizeof(*node)) + uint64(cap(node.Children))*uint64(unsafe.Sizeof(node))
+		for _, child := range node.Children {
+			count(child)
+		}
+	}
+	count(&tree.Root)
+	return
+}
+
+// PrintNodeMemUsage outputs the number of nodes and the memory used by them.
+func (tree *SchemaTree) PrintNodeMemUsage() {
+	layout := "pointer"
+	if tree.flat != nil {
+		layout = "compact"
+	}
+	nodes, bytes := tree.NodeMemUsage()
+	fmt.Printf("Nodes = %v\tNodeMemory = %.2f MiB\t(%v layout)\n", nodes, bToMb(bytes), layout)
+}
+
+// supportArray stores the supports of the nodes of compact and flat trees. Supports take 32 bits as long as
+// they fit, the array switches to 64 bits as soon as one of them exceeds that.
+type supportArray struct {
+	narrow []uint32
+	wide   []uint64 // used instead of narrow once the array has been widened
+}
+
+func newSupportArray(n int, wide bool) supportArray {
+	if wide {
+		return supportArray{wide: make([]uint64, n)}
+	}
+	return supportArray{narrow: make([]uint32, n)}
+}
+
+func (s *supportArray) isWide() bool {
+	return s.wide != nil
+}
+
+func (s *supportArray) len() int {
+	if s.isWide() {
+		return len(s.wide)
+	}
+	return len(s.narrow)
+}
+
+func (s *supportArray) get(node uint32) uint64 {
+	if s.isWide() {
+		return s.wide[node]
+	}
+	return uint64(s.narrow[node])
+}
+
+func (s *supportArray) set(node uint32, support uint64) {
+	if !s.isWide() && support > math.MaxUint32 {
+		s.widen()
+	}
+	if s.isWide() {
+		s.wide[node] = support
+	} else {
+		s.narrow[node] = uint32(support)
+	}
+}
+
+func (s *supportArray) add(node uint32, n uint64) {
+	s.set(node, s.get(node)+n)
+}
+
+func (s *supportArray) append(support uint64) {
+	if s.isWide() {
+		s.wide = append(s.wide, support)
+	} else {
+		s.narrow = append(s.narrow, 0)
+		s.set(uint32(len(s.narrow)-1), support)
+	}
+}
+
+// widen converts the array to 64-bit supports.
+func (s *supportArray) widen() {
+	s.wide = make([]uint64, len(s.narrow), cap(s.narrow))
+	for i, support := range s.narrow {
+		s.wide[i] = uint64(support)
+	}
+	s.narrow = nil
+}
+
+// bytes returns the memory used by the supports.
+func (s *supportArray) bytes() uint64 {
+	if s.isWide() {
+		return 8 * uint64(len(s.wide))
+	}
+	return 4 * uint64(len(s.narrow))
+}
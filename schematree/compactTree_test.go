@@ -0,0 +1,149 @@
+package schematree
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactLayout(t *testing.T) {
+	pointer := New(true, 1)
+	pointer.TwoPass(filePath, 0)
+
+	CompactLayout = true
+	defer func() { CompactLayout = false }()
+	compact := New(true, 1)
+	compact.TwoPass(filePath, 0)
+
+	t.Run("construction", func(t *testing.T) {
+		assert.Nil(t, compact.builder)
+		assert.NotNil(t, compact.flat)
+		assert.Empty(t, compact.Root.Children)
+		assert.Equal(t, pointer.Root.Support, compact.Root.Support)
+		assert.Equal(t, transactionsOf(pointer), transactionsOf(compact))
+
+		pointerNodes, pointerBytes := pointer.NodeMemUsage()
+		compactNodes, compactBytes := compact.NodeMemUsage()
+		assert.Equal(t, pointerNodes, compactNodes)
+		assert.Less(t, 2*compactBytes, pointerBytes)
+	})
+
+	t.Run("queries", func(t *testing.T) {
+		for str, item := range pointer.PropMap {
+			if item == pointer.Root.ID {
+				continue
+			}
+			a, b := IList{item}, IList{compact.PropMap[str]}
+			assert.Equal(t, pointer.Support(a), compact.Support(b), str)
+			assert.Equal(t, asMap(pointer.RecommendProperty(a)), asMap(compact.RecommendProperty(b)), str)
+		}
+	})
+
+	t.Run("save and load", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "compact.bin")
+		assert.NoError(t, compact.Save(path))
+		loaded, err := Load(path) // converted, as CompactLayout is set
+		assert.NoError(t, err)
+		assert.NotNil(t, loaded.flat)
+		assert.Equal(t, transactionsOf(pointer), transactionsOf(loaded))
+	})
+
+	t.Run("read-only", func(t *testing.T) {
+		_, err := compact.ApplyDelta(filePath, 0)
+		assert.Equal(t, ErrReadOnly, err)
+		assert.Equal(t, ErrReadOnly, compact.Insert(&SubjectSummary{}))
+		assert.Equal(t, ErrReadOnly, compact.Add(&SubjectSummary{}))
+	})
+}
+
+func TestCompactBuilder(t *testing.T) {
+	// a node with many children and transactions that are spread over several builders
+	pointer := New(false, 1)
+	p := pointer.PropMap.get("http://ex.org/p")
+	var transactions []IList
+	for i := 0; i < 4*wideChildren; i++ {
+		item := pointer.PropMap.get(fmt.Sprintf("http://ex.org/q%v", i))
+		transactions = append(transactions, IList{p, item}, IList{item})
+	}
+	for _, transaction := range transactions {
+		for _, item := range transaction {
+			item.TotalCount++
+		}
+	}
+	pointer.updateSortOrder()
+
+	pool := newBuilderPool(3, pointer.Root.ID)
+	for i := len(transactions) - 1; i >= 0; i-- { // the wide node gets its children in descending order
+		pointer.insertTransaction(transactions[i], uint64(i%4+1))
+		pool.builders[i%3].insert(transactions[i], uint64(i%4+1))
+	}
+	assert.Contains(t, pool.builders[0].wide, pool.builders[0].rootChildren[p.SortOrder])
+
+	compact := &SchemaTree{PropMap: pointer.PropMap, Root: SchemaNode{ID: pointer.Root.ID, Support: pointer.Root.Support}}
+	compact.flat = pool.freeze(pointer.itemsBySortOrder())
+	assert.NoError(t, compact.flat.check(uint64(len(compact.flat.nodeItem)), uint64(len(compact.flat.items))))
+	assert.Equal(t, transactionsOf(pointer), transactionsOf(compact))
+	nodes, _ := pointer.NodeMemUsage()
+	assert.EqualValues(t, nodes, len(compact.flat.nodeItem))
+	for _, item := range pointer.PropMap {
+		assert.Equal(t, pointer.Support(IList{p, item}), compact.Support(IList{p, item}), *item.Str)
+	}
+}
+
+func TestWideSupports(t *testing.T) {
+	t.Run("support array", func(t *testing.T) {
+		s := newSupportArray(2, false)
+		s.add(1, 7)
+		assert.False(t, s.isWide())
+		s.add(1, 1<<32)
+		s.append(3)
+		assert.True(t, s.isWide())
+		assert.Equal(t, []uint64{0, 1<<32 + 7, 3}, s.wide)
+	})
+
+	// a tree whose supports exceed 32 bits
+	tree := New(false, 1)
+	p, q := tree.PropMap.get("http://ex.org/p"), tree.PropMap.get("http://ex.org/q")
+	p.TotalCount, q.TotalCount = 3<<32, 1<<32
+	tree.updateSortOrder()
+	tree.insertTransaction(IList{p}, 2<<32)
+	tree.insertTransaction(IList{p, q}, 1<<32)
+	tree.Meta.Subjects = 3 << 32
+
+	check := func(t *testing.T, tree *SchemaTree) {
+		p, q := tree.PropMap["http://ex.org/p"], tree.PropMap["http://ex.org/q"]
+		assert.EqualValues(t, uint64(3<<32), tree.Root.Support)
+		assert.EqualValues(t, uint64(3<<32), tree.Support(IList{p}))
+		assert.EqualValues(t, uint64(1<<32), tree.Support(IList{p, q}))
+		recs := tree.RecommendProperty(IList{p})
+		if assert.Len(t, recs, 1) {
+			assert.InDelta(t, 1.0/3, recs[0].Probability, 1e-9)
+		}
+	}
+	check(t, tree)
+
+	t.Run("container", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "wide.bin")
+		assert.NoError(t, tree.Save(path))
+		loaded, err := Load(path)
+		assert.NoError(t, err)
+		check(t, loaded)
+	})
+
+	t.Run("compact and mapped", func(t *testing.T) {
+		tree.Compact()
+		assert.True(t, tree.flat.nodeSupport.isWide())
+		check(t, tree)
+
+		path := filepath.Join(t.TempDir(), "wide.flat")
+		assert.NoError(t, tree.SaveFlat(path))
+		mapped, err := LoadMapped(path)
+		if assert.NoError(t, err) {
+			defer mapped.Close()
+			assert.True(t, mapped.flat.nodeSupport.isWide())
+			check(t, mapped)
+		}
+	})
+}
@@ -25,12 +25,54 @@ func (p *IItem) increment() {
 
 const typePrefix = "t#"
 
+// inversePrefix marks the items of incoming relations, see BuildConfig.InverseProperties.
+const inversePrefix = "^"
+
+// valuePrefix marks the property=value items `v#predicate=value`, see BuildConfig.ValuePredicates.
+const valuePrefix = "v#"
+
 func (p *IItem) IsType() bool {
 	return strings.HasPrefix(*p.Str, typePrefix)
 }
 
+// TypeOf returns the IRI of the type of a type item. ok is false for other items.
+func (p *IItem) TypeOf() (iri string, ok bool) {
+	if !p.IsType() {
+		return "", false
+	}
+	return (*p.Str)[len(typePrefix):], true
+}
+
+// IsProp checks whether the item is a property, i.e. neither a type nor a property=value item.
 func (p *IItem) IsProp() bool {
-	return !strings.HasPrefix(*p.Str, typePrefix)
+	return !strings.HasPrefix(*p.Str, typePrefix) && !strings.HasPrefix(*p.Str, valuePrefix)
+}
+
+// IsValue checks whether the item is a property=value item.
+func (p *IItem) IsValue() bool {
+	return strings.HasPrefix(*p.Str, valuePrefix)
+}
+
+// ValueOf splits a property=value item into its predicate and value. ok is false for other items.
+func (p *IItem) ValueOf() (predicate, value string, ok bool) {
+	if !p.IsValue() {
+		return "", "", false
+	}
+	i := strings.IndexByte(*p.Str, '=')
+	if i < 0 {
+		return "", "", false
+	}
+	return (*p.Str)[len(valuePrefix):i], (*p.Str)[i+1:], true
+}
+
+// valueItem returns the string of the property=value item of a predicate and a value.
+func valueItem(predicate, value string) string {
+	return valuePrefix + predicate + "=" + value
+}
+
+// IsInverse checks whether the item is the inverse of a property, i.e. an incoming relation.
+func (p *IItem) IsInverse() bool {
+	return strings.HasPrefix(*p.Str, inversePrefix)
 }
 
 func (p IItem) String() string {
@@ -27,7 +27,7 @@ func recommendations(length int) (ls PropertyRecommendations) {
 	ps := orderedList(10)
 	ls = make([]RankedPropertyCandidate, length, length)
 	for i := 0; i < length; i++ {
-		ls[i] = RankedPropertyCandidate{ps[i], (1 / float64(i+1))}
+		ls[i] = RankedPropertyCandidate{Property: ps[i], Probability: (1 / float64(i+1))}
 	}
 	return
 }
@@ -0,0 +1,167 @@
+package schematree
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Estimator turns the supports that the tree counts for a candidate into its probability. support is the
+// number of subjects with the input set and the candidate, setSupport the number of subjects with the input
+// set and prior an estimate of the candidate without the input, see RecommendPropertyWith.
+type Estimator interface {
+	Estimate(support, setSupport uint64, prior float64) float64
+}
+
+// RawEstimator is the relative frequency support / setSupport, the estimate of RecommendProperty.
+type RawEstimator struct{}
+
+// Estimate implements Estimator.
+func (RawEstimator) Estimate(support, setSupport uint64, prior float64) float64 {
+	if setSupport == 0 {
+		return 0
+	}
+	return float64(support) / float64(setSupport)
+}
+
+// LaplaceEstimator adds Alpha pseudo subjects with and Alpha pseudo subjects without the candidate, so that
+// probabilities of few subjects are pulled towards 0.5.
+type LaplaceEstimator struct {
+	Alpha float64
+}
+
+// Estimate implements Estimator.
+func (e LaplaceEstimator) Estimate(support, setSupport uint64, prior float64) float64 {
+	return (float64(support) + e.Alpha) / (float64(setSupport) + 2*e.Alpha)
+}
+
+// ShrinkageEstimator shrinks the relative frequency towards a prior, as if Strength pseudo subjects had the
+// candidate as often as the prior says (a Dirichlet prior). The relative frequency gets the weight
+// setSupport / (setSupport + Strength), so input sets of few subjects mostly rely on the prior.
+//
+// Without LowerOrders, the prior is the marginal frequency of the candidate. Otherwise the estimator interpolates:
+// the prior is its own estimate for the input without its least frequent item, which again uses the input
+// without two items as prior and so on, for up to LowerOrders left out items. Every order costs one more walk
+// of the tree, the last one uses the marginal frequency.
+type ShrinkageEstimator struct {
+	Strength    float64
+	LowerOrders int
+}
+
+// Estimate implements Estimator.
+func (e ShrinkageEstimator) Estimate(support, setSupport uint64, prior float64) float64 {
+	if setSupport == 0 && e.Strength == 0 {
+		return prior
+	}
+	return (float64(support) + e.Strength*prior) / (float64(setSupport) + e.Strength)
+}
+
+// interpolationOrders is the number of lower orders of the interpolation estimator of ParseEstimator.
+const interpolationOrders = 3
+
+// ParseEstimator returns the estimator of a name, which is raw (or empty), laplace, shrinkage or interpolation
+// (a ShrinkageEstimator with lower orders). strength is the Alpha or Strength of the estimator, a default is
+// used if it is not positive.
+func ParseEstimator(name string, strength float64) (Estimator, error) {
+	orDefault := func(d float64) float64 {
+		if strength > 0 {
+			return strength
+		}
+		return d
+	}
+	switch name {
+	case "", "raw":
+		return RawEstimator{}, nil
+	case "laplace":
+		return LaplaceEstimator{Alpha: orDefault(1)}, nil
+	case "shrinkage":
+		return ShrinkageEstimator{Strength: orDefault(10)}, nil
+	case "interpolation":
+		return ShrinkageEstimator{Strength: orDefault(10), LowerOrders: interpolationOrders}, nil
+	}
+	return nil, fmt.Errorf("unknown estimator %v, expected raw, laplace, shrinkage or interpolation", name)
+}
+
+// RecommendPropertyWith recommends a ranked list of property candidates by given IItems like RecommendProperty,
+// but the probabilities come from the estimator. Unlike the raw estimate, smoothed estimates also give the
+// properties that never cooccur with the input a probability, so that unseen input sets get recommendations.
+// The raw support and set support of each candidate are kept in the recommendations.
+func (tree *SchemaTree) RecommendPropertyWith(properties IList, estimator Estimator) PropertyRecommendations {
+	if _, ok := estimator.(RawEstimator); ok || estimator == nil {
+		return tree.RecommendProperty(properties)
+	}
+	if tree.Root.Support == 0 {
+		return PropertyRecommendations{}
+	}
+	properties.Sort() // descending by support
+	orders := 0
+	if shrinkage, ok := estimator.(ShrinkageEstimator); ok {
+		orders = shrinkage.LowerOrders
+	}
+	ranked := tree.estimate(properties, tree.propertyItems(), estimator, orders)
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Probability > ranked[j].Probability })
+	return ranked
+}
+
+// propertyList holds the property items of a PropMap of the given size.
+type propertyList struct {
+	mapSize int
+	items   IList
+}
+
+// propertyItems lists the property items of the tree, i.e. no types, values or the root. The list is cached,
+// since the types alone can be millions of items, and built again when items have been added to the PropMap.
+func (tree *SchemaTree) propertyItems() IList {
+	if cached, ok := tree.properties.Load().(propertyList); ok && cached.mapSize == len(tree.PropMap) {
+		return cached.items
+	}
+	items := make(IList, 0)
+	for _, item := range tree.PropMap {
+		if item.IsProp() && item != tree.Root.ID {
+			items = append(items, item)
+		}
+	}
+	tree.properties.Store(propertyList{len(tree.PropMap), items})
+	return items
+}
+
+// estimate computes the probabilities of all candidates that are not part of the properties, which have to be
+// sorted. Candidates with a probability of zero are left out. With lower orders, the priors are the estimates
+// for the properties without the last one, see ShrinkageEstimator.
+func (tree *SchemaTree) estimate(properties IList, candidates IList, estimator Estimator, orders int) PropertyRecommendations {
+	supports, setSupport := map[*IItem]uint64(nil), tree.Root.Support
+	if len(properties) > 0 {
+		supports, setSupport = tree.conditionalCandidates(properties, (*IItem).IsProp)
+	}
+
+	// the prior of lower orders leaves out the least frequent property, which is the last one
+	var lower map[*IItem]float64
+	if orders > 0 && len(properties) > 0 {
+		lowerRanked := tree.estimate(properties[:len(properties)-1], candidates, estimator, orders-1)
+		lower = make(map[*IItem]float64, len(lowerRanked))
+		for _, rec := range lowerRanked {
+			lower[rec.Property] = rec.Probability
+		}
+	}
+
+	input := properties.toSet()
+	ranked := make(PropertyRecommendations, 0, len(candidates))
+	for _, item := range candidates {
+		if input[item] || item.TotalCount == 0 {
+			continue // items that no subject has, e.g. the type predicates of untyped trees, are no candidates
+		}
+		support := item.TotalCount
+		if len(properties) > 0 {
+			support = supports[item]
+		}
+		prior := float64(item.TotalCount) / float64(tree.Root.Support)
+		if lower != nil {
+			prior = lower[item]
+		}
+		if probability := estimator.Estimate(support, setSupport, prior); probability > 0 {
+			ranked = append(ranked, RankedPropertyCandidate{item, probability, support, setSupport})
+		}
+	}
+	// the candidates are not ordered, so ties are broken by the sort order of the items
+	sort.Slice(ranked, func(i, j int) bool { return ranked[i].Property.SortOrder < ranked[j].Property.SortOrder })
+	return ranked
+}
@@ -0,0 +1,115 @@
+package schematree
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecommendPropertyWith(t *testing.T) {
+	lines := []string{
+		`<http://ex.org/p0> <http://ex.org/name> "p0" .`,
+		`<http://ex.org/p0> <http://ex.org/shoeSize> "42" .`,
+		`<http://ex.org/p0> <http://ex.org/birthDate> "2000" .`,
+		`<http://ex.org/p1> <http://ex.org/name> "p1" .`,
+		`<http://ex.org/p1> <http://ex.org/shoeSize> "43" .`,
+	}
+	for i := 0; i < 8; i++ {
+		lines = append(lines,
+			fmt.Sprintf(`<http://ex.org/c%v> <http://ex.org/name> "%v" .`, i, i),
+			fmt.Sprintf(`<http://ex.org/c%v> <http://ex.org/country> <http://ex.org/Germany> .`, i),
+		)
+	}
+	dataset := writeLines(t, "dataset.nt", lines...)
+	tree := New(false, 1)
+	tree.TwoPass(dataset, 0)
+	items := func(iris ...string) IList {
+		list := IList{}
+		for _, iri := range iris {
+			list = append(list, tree.PropMap["http://ex.org/"+iri])
+		}
+		return list
+	}
+	probabilities := func(recs PropertyRecommendations) map[string]float64 {
+		result := make(map[string]float64)
+		for _, rec := range recs {
+			result[*rec.Property.Str] = rec.Probability
+		}
+		return result
+	}
+	name, country, birthDate := "http://ex.org/name", "http://ex.org/country", "http://ex.org/birthDate"
+
+	t.Run("supports", func(t *testing.T) {
+		recs := tree.RecommendProperty(items("shoeSize"))
+		assert.Equal(t, RankedPropertyCandidate{tree.PropMap[name], 1, 2, 2}, recs[0])
+		assert.Equal(t, RankedPropertyCandidate{tree.PropMap[birthDate], 0.5, 1, 2}, recs[1])
+		assert.Len(t, recs, 2)
+		assert.Equal(t, recs, tree.RecommendPropertyWith(items("shoeSize"), RawEstimator{}))
+	})
+
+	t.Run("laplace", func(t *testing.T) {
+		recs := tree.RecommendPropertyWith(items("shoeSize"), LaplaceEstimator{Alpha: 1})
+		assert.Equal(t, map[string]float64{name: 0.75, birthDate: 0.5, country: 0.25}, probabilities(recs))
+		assert.Equal(t, RankedPropertyCandidate{tree.PropMap[country], 0.25, 0, 2}, recs[2])
+	})
+
+	t.Run("shrinkage", func(t *testing.T) {
+		recs := tree.RecommendPropertyWith(items("shoeSize"), ShrinkageEstimator{Strength: 10})
+		p := probabilities(recs)
+		assert.InDelta(t, 1, p[name], 1e-9)
+		assert.InDelta(t, 2.0/3, p[country], 1e-9)
+		assert.InDelta(t, 2.0/12, p[birthDate], 1e-9)
+		assert.Equal(t, name, *recs[0].Property.Str)
+	})
+
+	t.Run("interpolation", func(t *testing.T) {
+		interpolation := ShrinkageEstimator{Strength: 10, LowerOrders: 3}
+		p := probabilities(tree.RecommendPropertyWith(items("shoeSize", "birthDate"), interpolation))
+		assert.InDelta(t, 1, p[name], 1e-9)
+		assert.InDelta(t, 20.0/33, p[country], 1e-9)
+
+		// the set was never seen, so the lower order estimates for the country alone are taken
+		assert.Empty(t, tree.RecommendProperty(items("country", "shoeSize")))
+		recs := tree.RecommendPropertyWith(items("country", "shoeSize"), interpolation)
+		p = probabilities(recs)
+		assert.Len(t, p, 2)
+		assert.InDelta(t, 1, p[name], 1e-9)
+		assert.InDelta(t, 1.0/18, p[birthDate], 1e-9)
+		assert.EqualValues(t, 0, recs[0].SetSupport)
+
+		// without lower orders, the marginal frequency is the prior, the recursion stops at the empty input
+		input := items("shoeSize", "birthDate")
+		assert.InDelta(t, 8.0/11, probabilities(tree.RecommendPropertyWith(input, ShrinkageEstimator{Strength: 10}))[country], 1e-9)
+		assert.Equal(t, tree.RecommendPropertyWith(input, interpolation),
+			tree.RecommendPropertyWith(input, ShrinkageEstimator{Strength: 10, LowerOrders: 10}))
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		p := probabilities(tree.RecommendPropertyWith(IList{}, ShrinkageEstimator{Strength: 10}))
+		assert.InDelta(t, 0.8, p[country], 1e-9)
+		assert.InDelta(t, 0.1, p[birthDate], 1e-9)
+	})
+
+	t.Run("added properties", func(t *testing.T) {
+		tree := New(false, 1)
+		tree.TwoPass(dataset, 0)
+		assert.NotContains(t, probabilities(tree.RecommendPropertyWith(IList{}, LaplaceEstimator{Alpha: 1})), "http://ex.org/height")
+		assert.NoError(t, tree.Add(&SubjectSummary{Properties: map[*IItem]uint32{tree.PropMap.get("http://ex.org/height"): 1}}))
+		p := probabilities(tree.RecommendPropertyWith(IList{}, LaplaceEstimator{Alpha: 1}))
+		assert.Contains(t, p, "http://ex.org/height")
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		estimator, err := ParseEstimator("laplace", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, LaplaceEstimator{Alpha: 1}, estimator)
+		estimator, err = ParseEstimator("interpolation", 5)
+		assert.NoError(t, err)
+		assert.Equal(t, ShrinkageEstimator{Strength: 5, LowerOrders: 3}, estimator)
+		estimator, err = ParseEstimator("", 0)
+		assert.Equal(t, RawEstimator{}, estimator)
+		_, err = ParseEstimator("magic", 0)
+		assert.Error(t, err)
+	})
+}
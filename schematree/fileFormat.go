@@ -0,0 +1,357 @@
+package schematree
+
+// Binary container format of stored schematrees:
+//
+//	magic         8 bytes   "SCHMTREE"
+//	version       uint16    format version of the payload, see formatVersion
+//	metaLength    uint32    length of the metadata
+//	metadata      JSON      see Metadata
+//	payloadLength uint64    length of the payload
+//	checksum      uint32    CRC-32 (Castagnoli) of metadata and payload
+//	payload       gzip      gob stream of the property list, MinSup and the nodes
+//
+// All integers are big-endian. Files written before the container was introduced start directly with
+// the gzip stream and are still accepted by Load.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+
+	gzip "github.com/klauspost/pgzip"
+)
+
+var formatMagic = []byte("SCHMTREE")
+
+// formatVersion is the version written by Save. Load accepts all versions up to it.
+const formatVersion uint16 = 1
+
+// maxMetadataLength protects against allocating huge buffers for corrupted headers.
+const maxMetadataLength = 64 * 1024 * 1024
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Errors returned by Load for files that cannot be read.
+var (
+	ErrUnknownFormat      = errors.New("not a schematree file")
+	ErrUnsupportedVersion = errors.New("unsupported schematree format version")
+	ErrCorrupted          = errors.New("schematree file is corrupted")
+)
+
+// ErrMetadataTooLarge is returned by Save if the metadata exceeds maxMetadataLength, since Load would reject it.
+var ErrMetadataTooLarge = errors.New("schematree metadata is too large")
+
+// Metadata describes how a schematree has been built. It is stored in the header of the binary format
+// and can be read without loading the tree via ReadMetadata.
+type Metadata struct {
+	FormatVersion  uint16       `json:"formatVersion"`
+	Dataset        string       `json:"dataset,omitempty"`        // path of the dataset the tree was built from
+	Subjects       uint64       `json:"subjects"`                 // number of subjects (transactions) in the tree
+	Typed          bool         `json:"typed"`                    // true if types are included as properties
+	BuildTime      time.Time    `json:"buildTime"`                // time when the construction or last update finished
+	TypePredicates []string     `json:"typePredicates,omitempty"` // predicates whose objects are treated as types
+	Config         *BuildConfig `json:"config,omitempty"`         // type predicates and filters used to read the dataset
+
+	// ObjectStats describes the objects of the properties by their IRI, e.g. to tell users what kind of value
+	// to enter for a recommended property
+	ObjectStats map[string]*ObjectStats `json:"objectStats,omitempty"`
+
+	// Cardinalities are histograms of the number of values per subject of the properties by their IRI
+	Cardinalities map[string]*Cardinality `json:"cardinalities,omitempty"`
+}
+
+// header is the fixed part of the container that precedes the payload.
+type header struct {
+	version       uint16
+	metadata      []byte
+	payloadLength uint64
+	checksum      uint32
+}
+
+// writeContainer writes the container to f. The payload is written by the callback, the lengths and the
+// checksum are filled in afterwards.
+func writeContainer(f *os.File, meta Metadata, writePayload func(w io.Writer) error) error {
+	meta.FormatVersion = formatVersion
+	metadata, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if len(metadata) > maxMetadataLength {
+		return fmt.Errorf("%w: %v bytes, at most %v are allowed", ErrMetadataTooLarge, len(metadata), maxMetadataLength)
+	}
+
+	buffered := bufio.NewWriterSize(f, 1024*1024)
+	var head bytes.Buffer // writes to a bytes.Buffer cannot fail
+	head.Write(formatMagic)
+	binary.Write(&head, binary.BigEndian, formatVersion)
+	binary.Write(&head, binary.BigEndian, uint32(len(metadata)))
+	head.Write(metadata)
+	lengthOffset := int64(head.Len())
+	binary.Write(&head, binary.BigEndian, uint64(0)) // payload length, patched below
+	binary.Write(&head, binary.BigEndian, uint32(0)) // checksum, patched below
+	if _, err := buffered.Write(head.Bytes()); err != nil {
+		return err
+	}
+
+	// payload, counted and hashed while it is written
+	counter := &countingWriter{w: buffered, hash: crc32.New(crcTable)}
+	counter.hash.Write(metadata)
+	if err := writePayload(counter); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+
+	// patch the header
+	var patch [12]byte
+	binary.BigEndian.PutUint64(patch[0:8], counter.n)
+	binary.BigEndian.PutUint32(patch[8:12], counter.hash.Sum32())
+	_, err = f.WriteAt(patch[:], lengthOffset)
+	return err
+}
+
+type countingWriter struct {
+	w    io.Writer
+	n    uint64
+	hash hash.Hash32
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += uint64(n)
+	c.hash.Write(p[:n])
+	return n, err
+}
+
+// isContainer checks whether the reader starts with the magic bytes, without consuming anything.
+func isContainer(r *bufio.Reader) bool {
+	magic, err := r.Peek(len(formatMagic))
+	return err == nil && bytes.Equal(magic, formatMagic)
+}
+
+// readHeader reads the fixed part of the container up to the start of the payload.
+func readHeader(r io.Reader) (h header, err error) {
+	magic := make([]byte, len(formatMagic))
+	if _, err = io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, formatMagic) {
+		return h, ErrUnknownFormat
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.version); err != nil {
+		return h, fmt.Errorf("%w: truncated header", ErrCorrupted)
+	}
+	if h.version == 0 || h.version > formatVersion {
+		return h, fmt.Errorf("%w: version %v, this build reads up to version %v", ErrUnsupportedVersion, h.version, formatVersion)
+	}
+	var metaLength uint32
+	if err = binary.Read(r, binary.BigEndian, &metaLength); err != nil {
+		return h, fmt.Errorf("%w: truncated header", ErrCorrupted)
+	}
+	if metaLength > maxMetadataLength {
+		return h, fmt.Errorf("%w: invalid metadata length %v", ErrCorrupted, metaLength)
+	}
+	h.metadata = make([]byte, metaLength)
+	if _, err = io.ReadFull(r, h.metadata); err != nil {
+		return h, fmt.Errorf("%w: truncated metadata", ErrCorrupted)
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.payloadLength); err != nil {
+		return h, fmt.Errorf("%w: truncated header", ErrCorrupted)
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.checksum); err != nil {
+		return h, fmt.Errorf("%w: truncated header", ErrCorrupted)
+	}
+	return h, nil
+}
+
+// ReadMetadata reads the metadata of a stored schematree without loading the tree. Legacy files have
+// no metadata and result in ErrUnknownFormat.
+func ReadMetadata(filePath string) (meta Metadata, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return meta, err
+	}
+	defer f.Close()
+
+	h, err := readHeader(bufio.NewReader(f))
+	if err != nil {
+		return meta, err
+	}
+	if err = json.Unmarshal(h.metadata, &meta); err != nil {
+		return meta, fmt.Errorf("%w: invalid metadata: %v", ErrCorrupted, err)
+	}
+	return meta, nil
+}
+
+// writePayload encodes the tree into the gzipped gob stream of the payload.
+func (tree *SchemaTree) writePayload(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	e := gob.NewEncoder(zw)
+
+	// encode propMap
+	props := make([]*IItem, len(tree.PropMap), len(tree.PropMap))
+	for _, p := range tree.PropMap {
+		props[int(p.SortOrder)] = p
+	}
+	if err := e.Encode(props); err != nil {
+		return err
+	}
+
+	// encode MinSup
+	if err := e.Encode(tree.MinSup); err != nil {
+		return err
+	}
+
+	// encode root
+	if tree.flat != nil {
+		if err := tree.flat.writeGob(e, 0); err != nil {
+			return err
+		}
+	} else if err := tree.Root.writeGob(e); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// loadContainer reads a tree from the container format and verifies its checksum.
+func loadContainer(r io.Reader) (*SchemaTree, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	tree := New(false, 1)
+	tree.Meta = Metadata{} // the configuration is decoded from the file, not into the default one
+	if err = json.Unmarshal(h.metadata, &tree.Meta); err != nil {
+		return nil, fmt.Errorf("%w: invalid metadata: %v", ErrCorrupted, err)
+	}
+	tree.Typed = tree.Meta.Typed
+
+	// hash everything that is read from the payload
+	crc := crc32.New(crcTable)
+	crc.Write(h.metadata)
+	limited := &io.LimitedReader{R: r, N: int64(h.payloadLength)}
+	payload := io.TeeReader(limited, crc)
+
+	zr, err := gzip.NewReader(payload)
+	if err != nil {
+		return nil, corruption(err)
+	}
+	defer zr.Close()
+	d := gob.NewDecoder(zr)
+
+	// decode propMap
+	var props []*IItem
+	if err = d.Decode(&props); err != nil {
+		return nil, corruption(err)
+	}
+	for sortOrder, item := range props {
+		item.SortOrder = uint32(sortOrder)
+		tree.PropMap[*item.Str] = item
+	}
+	fmt.Printf("%v properties... ", len(props))
+
+	// decode MinSup
+	if err = d.Decode(&tree.MinSup); err != nil {
+		return nil, corruption(err)
+	}
+
+	// decode Root
+	fmt.Printf("decoding tree...")
+	if err = tree.Root.decodeGob(d, props); err != nil {
+		return nil, corruption(err)
+	}
+	if tree.Root.ID == nil || *tree.Root.ID.Str != "root" {
+		return nil, fmt.Errorf("%w: root node is not the root item", ErrCorrupted)
+	}
+
+	// consume the rest of the payload, so that the checksum covers all of it. The decompressor reads
+	// ahead concurrently, so it has to be finished and closed before the payload is read directly.
+	// The reader is wrapped to hide WriteTo of pgzip, which fails on streams that are already at their end.
+	if _, err = io.Copy(io.Discard, struct{ io.Reader }{zr}); err != nil {
+		return nil, corruption(err)
+	}
+	zr.Close()
+	if _, err = io.Copy(io.Discard, payload); err != nil {
+		return nil, err
+	}
+	if limited.N > 0 {
+		return nil, fmt.Errorf("%w: file is truncated", ErrCorrupted)
+	}
+	if crc.Sum32() != h.checksum {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorrupted)
+	}
+	tree.Config() // fill in the configuration of older files while the tree is not shared
+	return tree, nil
+}
+
+// corruption wraps decoding errors of the payload, distinguishing truncated files.
+func corruption(err error) error {
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return fmt.Errorf("%w: file is truncated", ErrCorrupted)
+	}
+	return fmt.Errorf("%w: %v", ErrCorrupted, err)
+}
+
+// loadLegacy reads the gob stream that was written before the container format was introduced.
+func loadLegacy(r io.Reader) (*SchemaTree, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, ErrUnknownFormat
+	}
+	defer zr.Close()
+
+	tree := New(false, 1)
+	d := gob.NewDecoder(zr)
+
+	// decode propMap
+	var props []*IItem
+	err = d.Decode(&props)
+	if err != nil {
+		return nil, err
+	}
+	for sortOrder, item := range props {
+		item.SortOrder = uint32(sortOrder)
+		tree.PropMap[*item.Str] = item
+	}
+	fmt.Printf("%v properties... ", len(props))
+
+	// decode MinSup
+	err = d.Decode(&tree.MinSup)
+	if err != nil {
+		return nil, err
+	}
+
+	// decode Root
+	fmt.Printf("decoding tree...")
+	err = tree.Root.decodeGob(d, props)
+	if err != nil {
+		return nil, err
+	}
+
+	// legacy import bug workaround
+	if *tree.Root.ID.Str != "root" {
+		fmt.Println("WARNING!!! Encountered legacy root node import bug - root node counts will be incorrect!")
+		tree.Root.ID = tree.PropMap.get("root")
+	}
+
+	// decode Typed, which was written as int (1 = typed, 2 = untyped) followed by a bool
+	var i int
+	err = d.Decode(&i)
+	if i == 1 {
+		tree.Typed = true
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tree.Meta = Metadata{Typed: tree.Typed, Subjects: uint64(tree.Root.Support)}
+	tree.Config()
+	return tree, nil
+}
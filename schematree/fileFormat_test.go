@@ -0,0 +1,83 @@
+package schematree
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileFormat(t *testing.T) {
+	tree, err := Load(typedTreepath) // legacy file without header
+	assert.NoError(t, err)
+	assert.True(t, tree.Typed)
+	assert.EqualValues(t, tree.Root.Support, tree.Meta.Subjects)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tree.bin")
+	tree.Meta.Dataset = "dataset.nt.gz"
+	tree.Meta.TypePredicates = typePredicates
+	assert.NoError(t, tree.Save(path))
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	t.Run("round trip", func(t *testing.T) {
+		loaded, err := Load(path)
+		assert.NoError(t, err)
+		assert.True(t, loaded.Typed)
+		assert.Equal(t, len(tree.PropMap), len(loaded.PropMap))
+		assert.Equal(t, tree.Root.Support, loaded.Root.Support)
+		assert.Equal(t, len(tree.Root.Children), len(loaded.Root.Children))
+		assert.Equal(t, "dataset.nt.gz", loaded.Meta.Dataset)
+		assert.Equal(t, typePredicates, loaded.Meta.TypePredicates)
+		assert.Equal(t, formatVersion, loaded.Meta.FormatVersion)
+	})
+
+	t.Run("metadata only", func(t *testing.T) {
+		meta, err := ReadMetadata(path)
+		assert.NoError(t, err)
+		assert.True(t, meta.Typed)
+		_, err = ReadMetadata(typedTreepath)
+		assert.True(t, errors.Is(err, ErrUnknownFormat))
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		truncated := filepath.Join(dir, "truncated.bin")
+		assert.NoError(t, os.WriteFile(truncated, content[:len(content)/2], 0644))
+		_, err := Load(truncated)
+		assert.True(t, errors.Is(err, ErrCorrupted), err)
+	})
+
+	t.Run("flipped byte", func(t *testing.T) {
+		corrupted := append([]byte(nil), content...)
+		corrupted[len(corrupted)-10] ^= 0xff
+		path := filepath.Join(dir, "corrupted.bin")
+		assert.NoError(t, os.WriteFile(path, corrupted, 0644))
+		_, err := Load(path)
+		assert.True(t, errors.Is(err, ErrCorrupted), err)
+	})
+
+	t.Run("newer version", func(t *testing.T) {
+		newer := append([]byte(nil), content...)
+		newer[len(formatMagic)+1] = byte(formatVersion + 1)
+		path := filepath.Join(dir, "newer.bin")
+		assert.NoError(t, os.WriteFile(path, newer, 0644))
+		_, err := Load(path)
+		assert.True(t, errors.Is(err, ErrUnsupportedVersion), err)
+	})
+
+	t.Run("metadata too large", func(t *testing.T) {
+		large := New(false, 1)
+		large.Meta.Dataset = strings.Repeat("x", maxMetadataLength)
+		err := large.Save(filepath.Join(dir, "large.bin"))
+		assert.True(t, errors.Is(err, ErrMetadataTooLarge), err)
+	})
+
+	t.Run("foreign file", func(t *testing.T) {
+		_, err := Load("../testdata/workflow.json")
+		assert.True(t, errors.Is(err, ErrUnknownFormat), err)
+	})
+}
@@ -0,0 +1,545 @@
+package schematree
+
+// Flat layout of schematrees that is memory-mapped for serving.
+//
+// The nodes are numbered in breadth-first order, so the children of every node are stored next to each
+// other and the whole tree fits into a few flat arrays. These arrays are used directly from the mapped
+// file, nothing is deserialized except the item table. Several processes that map the same file share
+// its pages through the page cache.
+//
+//	header          64 bytes  magic "SCHMFLAT", version, number of items and nodes, lengths of metadata and strings,
+//	                          width of the supports
+//	metadata        JSON      see Metadata
+//	itemCounts      uint64    TotalCount of every item, indexed by sort order
+//	stringStarts    uint64    offset of the IRI of every item in strings, followed by the total length
+//	strings         bytes     IRIs of all items
+//	nodeItem        uint32    sort order of the item of every node
+//	nodeParent      uint32    index of the parent of every node (the root is node 0 and its own parent)
+//	nodeSupport     uint32    support of every node, uint64 if the width of the supports is 8 bytes
+//	childStarts     uint32    index of the first child of every node, followed by the number of nodes
+//	traversalStarts uint32    start of the traversal list of every item, followed by the number of nodes
+//	traversal       uint32    indexes of the nodes of every item, grouped by item
+//
+// All integers are little-endian and every section starts at a multiple of 8 bytes.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+	"unsafe"
+)
+
+var flatMagic = []byte("SCHMFLAT")
+
+// flatFormatVersion is the version of the flat layout written by SaveFlat. Version 1 had no support width
+// and always used 32-bit supports.
+const flatFormatVersion uint32 = 2
+
+const flatHeaderSize = 64
+
+// ErrReadOnly is returned by operations that would modify a compact or memory-mapped schematree.
+var ErrReadOnly = errors.New("compact and memory-mapped schematrees are read-only")
+
+// flatTree holds the arrays of a compact or mapped schematree. Nodes are referenced by their index.
+type flatTree struct {
+	items           []*IItem // items by sort order
+	nodeItem        []uint32
+	nodeParent      []uint32
+	nodeSupport     supportArray
+	childStarts     []uint32
+	traversalStarts []uint32
+	traversal       []uint32
+	unmap           func() error // releases the mapping, nil for compact trees
+}
+
+// SaveFlat stores the schematree in the flat layout, which can be memory-mapped by LoadMapped.
+func (tree *SchemaTree) SaveFlat(filePath string) error {
+	t1 := time.Now()
+	fmt.Printf("Writing flat schema to file %v... ", filePath)
+
+	flat := tree.flat
+	if flat == nil {
+		flat = tree.toFlat()
+	}
+
+	stringStarts := make([]uint64, len(flat.items)+1)
+	for i, item := range flat.items {
+		stringStarts[i+1] = stringStarts[i] + uint64(len(*item.Str))
+	}
+	itemCounts := make([]uint64, len(flat.items))
+	for i, item := range flat.items {
+		itemCounts[i] = item.TotalCount
+	}
+
+	meta := tree.Meta
+	meta.Typed = tree.Typed
+	meta.FormatVersion = uint16(flatFormatVersion)
+	metadata, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fw := &flatWriter{w: bufio.NewWriterSize(f, 4*1024*1024)}
+
+	header := make([]byte, flatHeaderSize)
+	copy(header, flatMagic)
+	binary.LittleEndian.PutUint32(header[8:], flatFormatVersion)
+	binary.LittleEndian.PutUint32(header[12:], uint32(len(flat.items)))
+	binary.LittleEndian.PutUint64(header[16:], uint64(len(flat.nodeItem)))
+	binary.LittleEndian.PutUint64(header[24:], uint64(len(metadata)))
+	binary.LittleEndian.PutUint64(header[32:], stringStarts[len(flat.items)])
+	binary.LittleEndian.PutUint32(header[40:], supportWidth(flat.nodeSupport))
+	fw.write(header)
+	fw.write(metadata)
+	fw.pad()
+	fw.uint64s(itemCounts)
+	fw.uint64s(stringStarts)
+	for _, item := range flat.items {
+		fw.write([]byte(*item.Str))
+	}
+	fw.pad()
+	fw.uint32s(flat.nodeItem)
+	fw.uint32s(flat.nodeParent)
+	if flat.nodeSupport.isWide() {
+		fw.uint64s(flat.nodeSupport.wide)
+	} else {
+		fw.uint32s(flat.nodeSupport.narrow)
+	}
+	fw.uint32s(flat.childStarts)
+	fw.uint32s(flat.traversalStarts)
+	fw.uint32s(flat.traversal)
+	if fw.err == nil {
+		fw.err = fw.w.Flush()
+	}
+	if fw.err == nil {
+		fw.err = f.Close()
+	}
+
+	if fw.err == nil {
+		fmt.Printf("%v nodes, %v bytes (%v)\n", len(flat.nodeItem), fw.n, time.Since(t1))
+	} else {
+		fmt.Printf("Saving flat schema failed with error: %v\n", fw.err)
+	}
+	return fw.err
+}
+
+// flatWriter writes little-endian sections and keeps the first error.
+type flatWriter struct {
+	w   *bufio.Writer
+	n   uint64
+	err error
+}
+
+func (fw *flatWriter) write(p []byte) {
+	if fw.err != nil {
+		return
+	}
+	n, err := fw.w.Write(p)
+	fw.n += uint64(n)
+	fw.err = err
+}
+
+// pad fills up the current section to a multiple of 8 bytes.
+func (fw *flatWriter) pad() {
+	var zeros [8]byte
+	fw.write(zeros[:alignedSize(fw.n)-fw.n])
+}
+
+func (fw *flatWriter) uint32s(values []uint32) {
+	var buf [4]byte
+	for _, v := range values {
+		binary.LittleEndian.PutUint32(buf[:], v)
+		fw.write(buf[:])
+	}
+	fw.pad()
+}
+
+func (fw *flatWriter) uint64s(values []uint64) {
+	var buf [8]byte
+	for _, v := range values {
+		binary.LittleEndian.PutUint64(buf[:], v)
+		fw.write(buf[:])
+	}
+	fw.pad()
+}
+
+func alignedSize(n uint64) uint64 {
+	return (n + 7) &^ 7
+}
+
+// isFlat checks whether the reader starts with the magic bytes of the flat layout, without consuming anything.
+func isFlat(r *bufio.Reader) bool {
+	magic, err := r.Peek(len(flatMagic))
+	return err == nil && bytes.Equal(magic, flatMagic)
+}
+
+// LoadMapped memory-maps a schematree that has been stored by SaveFlat. Only the item table is copied
+// to the heap, so loading is independent of the size of the tree. The tree supports RecommendProperty,
+// RecommendPropertiesAndTypes and Support; it is read-only and must not be used after Close.
+func LoadMapped(filePath string) (*SchemaTree, error) {
+	fmt.Printf("Mapping schema (from file %v): ", filePath)
+	t1 := time.Now()
+	tree, err := mapFlat(filePath)
+	if err != nil {
+		fmt.Printf("Encountered error while mapping the file: %v\n", err)
+		return nil, err
+	}
+	fmt.Println(time.Since(t1))
+	return tree, nil
+}
+
+// Close releases the mapping of a tree loaded by LoadMapped. It does nothing for other trees.
+func (tree *SchemaTree) Close() error {
+	if tree.flat == nil || tree.flat.unmap == nil {
+		return nil
+	}
+	err := tree.flat.unmap()
+	tree.flat.unmap = nil
+	return err
+}
+
+func mapFlat(filePath string) (*SchemaTree, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // the mapping stays valid after the file is closed
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < flatHeaderSize {
+		return nil, ErrUnknownFormat
+	}
+	if uint64(info.Size()) > uint64(math.MaxInt) {
+		return nil, fmt.Errorf("file is too large to be mapped")
+	}
+	data, unmap, err := mapFile(f, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+
+	flat, meta, err := openFlat(data)
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+	flat.unmap = unmap
+
+	tree := &SchemaTree{
+		PropMap: make(propMap, len(flat.items)),
+		MinSup:  1,
+		Typed:   meta.Typed,
+		Meta:    meta,
+		flat:    flat,
+	}
+	tree.init()
+	for _, item := range flat.items {
+		tree.PropMap[*item.Str] = item
+	}
+	tree.Root = SchemaNode{ID: flat.items[flat.nodeItem[0]], Support: flat.nodeSupport.get(0)}
+	tree.Config() // fill in the configuration of older files while the tree is not shared
+	fmt.Printf("%v properties, %v nodes... ", len(flat.items), len(flat.nodeItem))
+	return tree, nil
+}
+
+// nativeLittleEndian is true if the arrays of the flat layout can be used without conversion.
+var nativeLittleEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// openFlat sets up the arrays on top of the mapped data. Only the header and the item table are
+// checked, the rest of the file is not touched so that mapping stays instant, see Verify.
+func openFlat(data []byte) (*flatTree, Metadata, error) {
+	var meta Metadata
+	if len(data) < flatHeaderSize || !bytes.Equal(data[:len(flatMagic)], flatMagic) {
+		return nil, meta, ErrUnknownFormat
+	}
+	version := binary.LittleEndian.Uint32(data[8:])
+	if version == 0 || version > flatFormatVersion {
+		return nil, meta, fmt.Errorf("%w: flat version %v, this build reads up to version %v", ErrUnsupportedVersion, version, flatFormatVersion)
+	}
+	if !nativeLittleEndian {
+		return nil, meta, errors.New("the flat schematree layout can only be mapped on little-endian machines")
+	}
+	numItems := uint64(binary.LittleEndian.Uint32(data[12:]))
+	numNodes := binary.LittleEndian.Uint64(data[16:])
+	metaLength := binary.LittleEndian.Uint64(data[24:])
+	stringsLength := binary.LittleEndian.Uint64(data[32:])
+	width := uint64(4)
+	if version >= 2 {
+		width = uint64(binary.LittleEndian.Uint32(data[40:]))
+	}
+	if width != 4 && width != 8 {
+		return nil, meta, fmt.Errorf("%w: invalid support width %v", ErrCorrupted, width)
+	}
+	if numNodes == 0 || numNodes >= math.MaxUint32 || numItems == 0 {
+		return nil, meta, fmt.Errorf("%w: invalid number of nodes or items", ErrCorrupted)
+	}
+
+	// cut the sections
+	size := uint64(len(data))
+	offset := uint64(flatHeaderSize)
+	section := func(length uint64) []byte {
+		if offset > size || length > size-offset {
+			offset = size + 1
+			return nil
+		}
+		s := data[offset : offset+length]
+		offset = alignedSize(offset + length)
+		return s
+	}
+	metadata := section(metaLength)
+	itemCounts := section(8 * numItems)
+	stringStarts := section(8 * (numItems + 1))
+	strs := section(stringsLength)
+	flat := &flatTree{
+		nodeItem:        uint32s(section(4 * numNodes)),
+		nodeParent:      uint32s(section(4 * numNodes)),
+		nodeSupport:     supportsOf(section(width*numNodes), width),
+		childStarts:     uint32s(section(4 * (numNodes + 1))),
+		traversalStarts: uint32s(section(4 * (numItems + 1))),
+		traversal:       uint32s(section(4 * numNodes)),
+	}
+	if offset > size {
+		return nil, meta, fmt.Errorf("%w: file is truncated", ErrCorrupted)
+	}
+	if err := json.Unmarshal(metadata, &meta); err != nil {
+		return nil, meta, fmt.Errorf("%w: invalid metadata: %v", ErrCorrupted, err)
+	}
+
+	// the item table is copied, it is needed for the property map
+	flat.items = make([]*IItem, numItems)
+	for i := range flat.items {
+		start := binary.LittleEndian.Uint64(stringStarts[8*i:])
+		end := binary.LittleEndian.Uint64(stringStarts[8*i+8:])
+		if start > end || end > stringsLength {
+			return nil, meta, fmt.Errorf("%w: invalid item table", ErrCorrupted)
+		}
+		str := string(strs[start:end])
+		flat.items[i] = &IItem{Str: &str, TotalCount: binary.LittleEndian.Uint64(itemCounts[8*i:]), SortOrder: uint32(i)}
+	}
+
+	if uint64(flat.childStarts[numNodes]) != numNodes || uint64(flat.traversalStarts[numItems]) != numNodes ||
+		uint64(flat.nodeItem[0]) >= numItems {
+		return nil, meta, fmt.Errorf("%w: inconsistent node arrays", ErrCorrupted)
+	}
+	return flat, meta, nil
+}
+
+// Verify checks every index of the node arrays of a compact or memory-mapped tree, which reads the whole
+// mapped file. Corrupted arrays result in an error wrapping ErrCorrupted. Without Verify, lookups with an
+// index out of range panic and walks through the tree stop at corrupted nodes, see flatTree.parent.
+func (tree *SchemaTree) Verify() error {
+	if tree.flat == nil {
+		return nil
+	}
+	if err := tree.flat.check(uint64(len(tree.flat.nodeItem)), uint64(len(tree.flat.items))); err != nil {
+		return fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+	return nil
+}
+
+// check makes sure that every index of the node arrays is within the number of nodes or items, so that a
+// corrupted file cannot make lookups panic or walks towards the root loop forever.
+func (flat *flatTree) check(numNodes, numItems uint64) error {
+	if uint64(len(flat.nodeItem)) != numNodes || uint64(len(flat.nodeParent)) != numNodes ||
+		uint64(flat.nodeSupport.len()) != numNodes || uint64(len(flat.childStarts)) != numNodes+1 ||
+		uint64(len(flat.traversalStarts)) != numItems+1 || uint64(len(flat.traversal)) != numNodes ||
+		uint64(len(flat.items)) != numItems {
+		return errors.New("inconsistent lengths of the node arrays")
+	}
+	for node, item := range flat.nodeItem {
+		if uint64(item) >= numItems {
+			return fmt.Errorf("node %v has the invalid item %v", node, item)
+		}
+	}
+	for node, parent := range flat.nodeParent {
+		// breadth-first order, the parent comes first
+		if node > 0 && parent >= uint32(node) {
+			return fmt.Errorf("node %v has the invalid parent %v", node, parent)
+		}
+	}
+	if err := checkStarts(flat.childStarts, numNodes); err != nil {
+		return fmt.Errorf("invalid children: %v", err)
+	}
+	if err := checkStarts(flat.traversalStarts, numNodes); err != nil {
+		return fmt.Errorf("invalid traversal lists: %v", err)
+	}
+	for _, node := range flat.traversal {
+		if uint64(node) >= numNodes {
+			return fmt.Errorf("the traversal lists contain the invalid node %v", node)
+		}
+	}
+	return nil
+}
+
+// checkStarts checks that the starts of lists are ascending and that the last one is end.
+func checkStarts(starts []uint32, end uint64) error {
+	for i := 1; i < len(starts); i++ {
+		if starts[i] < starts[i-1] {
+			return fmt.Errorf("start %v is before the previous one", i)
+		}
+	}
+	if uint64(starts[len(starts)-1]) != end {
+		return fmt.Errorf("the lists end at %v instead of %v", starts[len(starts)-1], end)
+	}
+	return nil
+}
+
+// supportWidth returns the number of bytes used per support.
+func supportWidth(s supportArray) uint32 {
+	if s.isWide() {
+		return 8
+	}
+	return 4
+}
+
+// supportsOf reinterprets aligned little-endian data as supports of the given width without copying it.
+func supportsOf(b []byte, width uint64) supportArray {
+	if width == 8 {
+		if len(b) == 0 {
+			return supportArray{wide: []uint64{}}
+		}
+		return supportArray{wide: unsafe.Slice((*uint64)(unsafe.Pointer(&b[0])), len(b)/8)}
+	}
+	return supportArray{narrow: uint32s(b)}
+}
+
+// uint32s reinterprets aligned little-endian data as an array without copying it.
+func uint32s(b []byte) []uint32 {
+	if len(b) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*uint32)(unsafe.Pointer(&b[0])), len(b)/4)
+}
+
+// parent returns the parent of a node. Parents come before their children in breadth-first order, a corrupted
+// parent that does not is replaced by the root, so that walks towards the root always end.
+func (flat *flatTree) parent(node uint32) uint32 {
+	if parent := flat.nodeParent[node]; parent < node {
+		return parent
+	}
+	return 0
+}
+
+// children returns the range of the children of a node. Children come after their parent in breadth-first
+// order, corrupted starts before that are raised, so that walks down the tree always end.
+func (flat *flatTree) children(node uint32) (start, end uint32) {
+	start, end = flat.childStarts[node], flat.childStarts[node+1]
+	if start <= node {
+		start = node + 1
+	}
+	return
+}
+
+// instances returns the traversal list of an item, i.e. the indexes of all nodes of that item.
+func (flat *flatTree) instances(item *IItem) []uint32 {
+	return flat.traversal[flat.traversalStarts[item.SortOrder]:flat.traversalStarts[item.SortOrder+1]]
+}
+
+// prefixContains checks if all properties of a given list are ancestors of a node, see SchemaNode.prefixContains.
+func (flat *flatTree) prefixContains(node uint32, propertyPath IList) bool {
+	nextP := len(propertyPath) - 1
+	for cur := node; cur != 0; cur = flat.parent(cur) {
+		item := flat.nodeItem[cur]
+		if item < propertyPath[nextP].SortOrder {
+			return false
+		}
+		if item == propertyPath[nextP].SortOrder {
+			nextP--
+			if nextP < 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// support returns the cooccurrence-frequency of the properties, which have to be sorted.
+func (flat *flatTree) support(properties IList) (support uint64) {
+	for _, node := range flat.instances(properties[len(properties)-1]) {
+		if flat.prefixContains(node, properties) {
+			support += flat.nodeSupport.get(node)
+		}
+	}
+	return
+}
+
+// candidates collects the cooccurring items of the properties, which have to be sorted, together with
+// their supports and the support of the property set. Only the items accepted by accept are included,
+// all of them if accept is nil.
+func (flat *flatTree) candidates(properties IList, accept func(*IItem) bool) (map[*IItem]uint64, uint64) {
+	counts := make(map[uint32]uint64)
+
+	var makeCandidates func(node uint32)
+	makeCandidates = func(node uint32) {
+		start, end := flat.children(node)
+		for child := start; child < end; child++ {
+			counts[flat.nodeItem[child]] += flat.nodeSupport.get(child)
+			makeCandidates(child)
+		}
+	}
+
+	var setSupport uint64
+	for _, leaf := range flat.instances(properties[len(properties)-1]) {
+		if flat.prefixContains(leaf, properties) {
+			support := flat.nodeSupport.get(leaf)
+			setSupport += support
+
+			// walk up
+			for cur := leaf; cur != 0; cur = flat.parent(cur) {
+				counts[flat.nodeItem[cur]] += support
+			}
+			// walk down
+			makeCandidates(leaf)
+		}
+	}
+
+	pSet := properties.toSet()
+	candidates := make(map[*IItem]uint64, len(counts))
+	for sortOrder, support := range counts {
+		item := flat.items[sortOrder]
+		if !pSet[item] && (accept == nil || accept(item)) {
+			candidates[item] = support
+		}
+	}
+	return candidates, setSupport
+}
+
+// writeGob encodes a node and its descendants like SchemaNode.writeGob.
+func (flat *flatTree) writeGob(e *gob.Encoder, node uint32) error {
+	if err := e.Encode(flat.nodeItem[node]); err != nil {
+		return err
+	}
+	if err := e.Encode(flat.nodeSupport.get(node)); err != nil {
+		return err
+	}
+	start, end := flat.children(node)
+	if start > end {
+		start = end
+	}
+	if err := e.Encode(int(end - start)); err != nil {
+		return err
+	}
+	for child := start; child < end; child++ {
+		if err := flat.writeGob(e, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package schematree
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// asMap makes recommendations comparable, the order of equally ranked candidates is not defined.
+func asMap(recs PropertyRecommendations) map[string]float64 {
+	m := make(map[string]float64, len(recs))
+	for _, r := range recs {
+		m[*r.Property.Str] = r.Probability
+	}
+	return m
+}
+
+func TestFlatTree(t *testing.T) {
+	tree, err := Load(typedTreepath)
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "tree.flat")
+	assert.NoError(t, tree.SaveFlat(path))
+	mapped, err := LoadMapped(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer mapped.Close()
+
+	// lists of the mapped tree, built from the same property strings
+	lists := func(props ...string) (IList, IList) {
+		var a, b IList
+		for _, p := range props {
+			a = append(a, tree.PropMap[p])
+			b = append(b, mapped.PropMap[p])
+		}
+		return a, b
+	}
+
+	t.Run("metadata and items", func(t *testing.T) {
+		assert.True(t, mapped.Typed)
+		assert.Equal(t, tree.Root.Support, mapped.Root.Support)
+		assert.Equal(t, "root", *mapped.Root.ID.Str)
+		assert.Equal(t, len(tree.PropMap), len(mapped.PropMap))
+		for str, item := range tree.PropMap {
+			assert.Equal(t, item.TotalCount, mapped.PropMap[str].TotalCount)
+			assert.Equal(t, item.SortOrder, mapped.PropMap[str].SortOrder)
+		}
+	})
+
+	t.Run("queries", func(t *testing.T) {
+		var checked int
+		for str, item := range tree.PropMap {
+			if item == tree.Root.ID || item.SortOrder%7 != 0 {
+				continue
+			}
+			for _, props := range [][]string{{str}, {str, *tree.Root.Children[0].ID.Str}} {
+				a, b := lists(props...)
+				assert.Equal(t, tree.Support(a), mapped.Support(b), props)
+				assert.Equal(t, asMap(tree.RecommendProperty(a)), asMap(mapped.RecommendProperty(b)), props)
+				assert.Equal(t, asMap(tree.RecommendPropertiesAndTypes(a)), asMap(mapped.RecommendPropertiesAndTypes(b)), props)
+				checked++
+			}
+		}
+		assert.Greater(t, checked, 10)
+		assert.Equal(t, asMap(tree.RecommendProperty(IList{})), asMap(mapped.RecommendProperty(IList{})))
+	})
+
+	t.Run("loaded through Load", func(t *testing.T) {
+		loaded, err := Load(path)
+		assert.NoError(t, err)
+		defer loaded.Close()
+		assert.Equal(t, ErrReadOnly, loaded.Remove(&SubjectSummary{}))
+		assert.Equal(t, ErrReadOnly, loaded.Rebalance())
+
+		// mapped trees can be stored in the container format again
+		copyPath := filepath.Join(t.TempDir(), "copy.bin")
+		assert.NoError(t, loaded.Save(copyPath))
+		copied, err := Load(copyPath)
+		assert.NoError(t, err)
+		assert.Equal(t, transactionsOf(tree), transactionsOf(copied))
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		truncated := filepath.Join(t.TempDir(), "truncated.flat")
+		assert.NoError(t, os.WriteFile(truncated, content[:len(content)-8], 0644))
+		_, err = LoadMapped(truncated)
+		assert.True(t, errors.Is(err, ErrCorrupted), err)
+	})
+
+	t.Run("invalid indexes", func(t *testing.T) {
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		_, _, err = openFlat(content)
+		assert.NoError(t, err)
+
+		// the arrays of an opened file point into its data, so they can be corrupted in place
+		corruptions := map[string]func(flat *flatTree){
+			"item":      func(flat *flatTree) { flat.nodeItem[1] = uint32(len(flat.items)) },
+			"parent":    func(flat *flatTree) { flat.nodeParent[len(flat.nodeParent)-1] = uint32(len(flat.nodeParent)) },
+			"cycle":     func(flat *flatTree) { flat.nodeParent[1] = 1 },
+			"children":  func(flat *flatTree) { flat.childStarts[0] = uint32(len(flat.nodeItem) + 1) },
+			"traversal": func(flat *flatTree) { flat.traversal[0] = uint32(len(flat.nodeItem)) },
+		}
+		for name, corrupt := range corruptions {
+			data := append([]byte(nil), content...)
+			flat, _, err := openFlat(data)
+			assert.NoError(t, err)
+			assert.NoError(t, (&SchemaTree{flat: flat}).Verify())
+			corrupt(flat)
+
+			// opening stays instant, only Verify reads the node arrays
+			flat, _, err = openFlat(data)
+			assert.NoError(t, err, name)
+			assert.True(t, errors.Is((&SchemaTree{flat: flat}).Verify(), ErrCorrupted), name)
+		}
+
+		// walks through a cycle of parents end at the root
+		data := append([]byte(nil), content...)
+		flat, _, _ := openFlat(data)
+		corruptions["cycle"](flat)
+		assert.NotPanics(t, func() { flat.support(IList{flat.items[flat.nodeItem[1]]}) })
+	})
+}
@@ -0,0 +1,216 @@
+package schematree
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ConstructionShards makes Create build trees with SinglePass and this many sharded sub-trees instead of
+// TwoPass. Zero keeps the two-pass construction.
+var ConstructionShards int
+
+// ErrIncompatibleTrees is returned by Merge for trees that cannot be combined.
+var ErrIncompatibleTrees = errors.New("the schematrees cannot be merged")
+
+// SinglePass constructs a SchemaTree from the firstN subjects of the given NTriples file, reading it only
+// once. The subjects are distributed over independent sub-trees, one per shard, which are built without
+// the global locks of the tree. As the property frequencies are only known at the end, the sub-trees sort
+// their paths by the order in which the properties were first seen. The merge step re-sorts every path
+// of the sub-trees by frequency and inserts it into the tree. A shards value below one uses one shard per CPU.
+//
+// The resulting tree is the same as the one built by TwoPass: with a MinSup above one, the merge leaves the
+// infrequent properties out of the transactions and nodes below MinSup are pruned afterwards. The ObjectStats
+// of the tree have no types of the objects (ObjectStats.Sampled stays zero), as they are only known after a
+// second pass.
+func (tree *SchemaTree) SinglePass(fileName string, firstN uint64, shards int) {
+	if shards < 1 {
+		shards = runtime.NumCPU()
+	}
+	t1 := time.Now()
+
+	// the sub-trees are handed out to the reader routines, so each of them is only used by one routine at a time
+	pool := newBuilderPool(shards, tree.Root.ID)
+	cardinalities := &cardinalityCollector{}
+	inserter := func(s *SubjectSummary) {
+		properties := s.iList()
+		for _, prop := range properties {
+			prop.increment()
+		}
+		cardinalities.add(s)
+		properties.Sort() // by first appearance, which stays fixed while the file is read
+		pool.insert(properties, 1)
+	}
+	objects := newObjectCollector()
+	subjectCount := readSubjectSummaries(fileName, tree.PropMap, tree.Config(), inserter, firstN, tree.Typed, objects)
+
+	propCount, typeCount := tree.PropMap.count()
+	fmt.Printf("%v subjects, %v properties, %v types in %v shards\n", subjectCount, propCount, typeCount, shards)
+	fmt.Println("Sharded Pass:", time.Since(t1))
+	PrintMemUsage()
+
+	// merge the sub-trees, mapping the items from their order of appearance to the frequency order
+	t2 := time.Now()
+	items := tree.itemsBySortOrder()
+	tree.updateSortOrder()
+	if CompactLayout {
+		tree.builder = newBuilderPool(0, tree.Root.ID)
+	}
+	insert := tree.insertTransaction
+	if tree.MinSup > 1 {
+		// like in the second pass of TwoPass, only now that the frequencies are known
+		insert = func(properties IList, count uint64) {
+			frequent := properties[:0]
+			for _, prop := range properties {
+				if prop.TotalCount >= tree.MinSup {
+					frequent = append(frequent, prop)
+				}
+			}
+			tree.insertTransaction(frequent, count)
+		}
+	}
+	var wg sync.WaitGroup
+	for _, b := range pool.builders {
+		wg.Add(1)
+		go func(b *compactBuilder) {
+			defer wg.Done()
+			b.eachTransaction(items, insert)
+		}(b)
+	}
+	wg.Wait()
+	tree.finishCompact()
+
+	tree.Meta.Dataset = fileName
+	tree.Meta.Subjects = subjectCount
+	tree.Meta.TypePredicates = tree.Config().TypePredicates
+	tree.Meta.ObjectStats = objects.stats()
+	tree.Meta.Cardinalities = cardinalities.cardinalities()
+	fmt.Println("Merge:", time.Since(t2))
+	PrintMemUsage()
+
+	if tree.MinSup > 1 {
+		tree.Prune(tree.MinSup)
+	}
+	tree.Meta.BuildTime = time.Now()
+}
+
+// eachTransaction calls visit for every path of the builder that subjects end in, together with the number
+// of these subjects. The items of the nodes are looked up in items by their index.
+func (b *compactBuilder) eachTransaction(items []*IItem, visit func(properties IList, count uint64)) {
+	var collect func(node uint32, path IList)
+	collect = func(node uint32, path IList) {
+		ends := b.support.get(node)
+		children := b.appendChildren(node, nil)
+		for _, child := range children {
+			ends -= b.support.get(child)
+		}
+		if ends > 0 {
+			visit(append(IList(nil), path...), ends)
+		}
+		for _, child := range children {
+			collect(child, append(path, items[b.item[child]]))
+		}
+	}
+	collect(0, IList{})
+}
+
+// eachTransaction calls visit for every path of the tree that subjects end in, together with the number of
+// these subjects. The paths are copies and may be modified by visit.
+func (tree *SchemaTree) eachTransaction(visit func(properties IList, count uint64)) {
+	if tree.flat != nil {
+		flat := tree.flat
+		var collect func(node uint32, path IList)
+		collect = func(node uint32, path IList) {
+			ends := flat.nodeSupport.get(node)
+			start, end := flat.children(node)
+			for child := start; child < end; child++ {
+				ends -= flat.nodeSupport.get(child)
+			}
+			if ends > 0 {
+				visit(append(IList(nil), path...), ends)
+			}
+			for child := start; child < end; child++ {
+				collect(child, append(path, flat.items[flat.nodeItem[child]]))
+			}
+		}
+		collect(0, IList{})
+		return
+	}
+
+	var collect func(node *SchemaNode, path IList)
+	collect = func(node *SchemaNode, path IList) {
+		if node.Support > node.childSupport() {
+			visit(append(IList(nil), path...), node.Support-node.childSupport())
+		}
+		for _, child := range node.Children {
+			collect(child, append(path, child.ID))
+		}
+	}
+	collect(&tree.Root, IList{})
+}
+
+// Merge adds all subjects of another tree, e.g. one built on a different shard of a dataset or on another
+// machine, to this tree. The property frequencies are summed up. If the merged frequencies change the sort
+// order of the properties, the paths of this tree are re-sorted first (see Rebalance). The other tree is not
+// modified and may have any layout, this tree has to use the pointer layout.
+//
+// Trees that have been pruned only keep their frequent paths, so the supports of the merged tree are lower
+// bounds for property sets that are infrequent in one of the trees.
+func (tree *SchemaTree) Merge(other *SchemaTree) error {
+	if tree.flat != nil {
+		return ErrReadOnly
+	}
+	if tree.Typed != other.Typed {
+		return fmt.Errorf("%w: one is typed, the other untyped", ErrIncompatibleTrees)
+	}
+	if !tree.Config().equal(other.Config()) {
+		return fmt.Errorf("%w: they were built with different type predicates or filters", ErrIncompatibleTrees)
+	}
+	t1 := time.Now()
+
+	// map the items of the other tree to the items of this tree, creating missing ones at the end of the sort order
+	items := make(map[*IItem]*IItem, len(other.PropMap))
+	for str, item := range other.PropMap {
+		mapped := tree.PropMap.get(str)
+		if item != other.Root.ID {
+			mapped.TotalCount += item.TotalCount
+		}
+		items[item] = mapped
+	}
+	if tree.SortOrderDrift() > 0 {
+		if err := tree.Rebalance(); err != nil {
+			return err
+		}
+	}
+
+	var merged uint64
+	other.eachTransaction(func(properties IList, count uint64) {
+		for i, prop := range properties {
+			properties[i] = items[prop]
+		}
+		tree.insertTransaction(properties, count)
+		merged += count
+	})
+
+	tree.Meta.Subjects += other.Meta.Subjects
+	tree.Meta.ObjectStats = mergeObjectStats(tree.Meta.ObjectStats, other.Meta.ObjectStats)
+	tree.Meta.Cardinalities = mergeCardinalities(tree.Meta.Cardinalities, other.Meta.Cardinalities)
+	if len(tree.Meta.TypePredicates) == 0 {
+		tree.Meta.TypePredicates = other.Meta.TypePredicates
+	}
+	if other.Meta.Dataset != "" && other.Meta.Dataset != tree.Meta.Dataset {
+		if tree.Meta.Dataset != "" {
+			tree.Meta.Dataset += " + "
+		}
+		tree.Meta.Dataset += other.Meta.Dataset
+	}
+	if other.MinSup > tree.MinSup {
+		tree.MinSup = other.MinSup
+	}
+	tree.Meta.BuildTime = time.Now()
+
+	fmt.Printf("Merged %v subjects (%v)\n", merged, time.Since(t1))
+	return nil
+}
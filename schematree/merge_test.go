@@ -0,0 +1,133 @@
+package schematree
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSinglePass(t *testing.T) {
+	expected := New(true, 1)
+	expected.TwoPass(filePath, 0)
+
+	sortOrders := func(tree *SchemaTree) map[string]uint32 {
+		result := make(map[string]uint32)
+		for str, item := range tree.PropMap {
+			if item != tree.Root.ID {
+				result[str] = item.SortOrder
+			}
+		}
+		return result
+	}
+
+	for _, shards := range []int{1, 3} {
+		tree := New(true, 1)
+		tree.SinglePass(filePath, 0, shards)
+		assert.Equal(t, expected.Root.Support, tree.Root.Support)
+		assert.Equal(t, expected.Meta.Subjects, tree.Meta.Subjects)
+		assert.Equal(t, transactionsOf(expected), transactionsOf(tree))
+		assert.Equal(t, sortOrders(expected), sortOrders(tree))
+		assert.Equal(t, 0.0, tree.SortOrderDrift())
+	}
+
+	t.Run("minimum support", func(t *testing.T) {
+		pruned := New(true, 3)
+		pruned.TwoPass(filePath, 0)
+		assert.NotEqual(t, transactionsOf(expected), transactionsOf(pruned))
+		tree := New(true, 3)
+		tree.SinglePass(filePath, 0, 3)
+		assert.Equal(t, pruned.Root.Support, tree.Root.Support)
+		assert.Equal(t, transactionsOf(pruned), transactionsOf(tree))
+	})
+
+	t.Run("compact layout", func(t *testing.T) {
+		CompactLayout = true
+		defer func() { CompactLayout = false }()
+		tree := New(true, 1)
+		tree.SinglePass(filePath, 0, 2)
+		assert.NotNil(t, tree.flat)
+		assert.Equal(t, expected.Root.Support, tree.Root.Support)
+		assert.Equal(t, transactionsOf(expected), transactionsOf(tree))
+	})
+}
+
+func TestMerge(t *testing.T) {
+	first := writeLines(t, "first.nt",
+		`<http://ex.org/a> <http://ex.org/p> "1" .`,
+		`<http://ex.org/a> <http://ex.org/q> "1" .`,
+		`<http://ex.org/b> <http://ex.org/p> "1" .`,
+		`<http://ex.org/c> <http://ex.org/p> "1" .`,
+		`<http://ex.org/c> <http://ex.org/r> "1" .`,
+	)
+	second := writeLines(t, "second.nt",
+		`<http://ex.org/d> <http://ex.org/r> "1" .`,
+		`<http://ex.org/d> <http://ex.org/s> "1" .`,
+		`<http://ex.org/e> <http://ex.org/s> "1" .`,
+		`<http://ex.org/f> <http://ex.org/s> "1" .`,
+		`<http://ex.org/f> <http://ex.org/q> "1" .`,
+		`<http://ex.org/g> <http://ex.org/s> "1" .`,
+	)
+	both := writeLines(t, "both.nt",
+		`<http://ex.org/a> <http://ex.org/p> "1" .`,
+		`<http://ex.org/a> <http://ex.org/q> "1" .`,
+		`<http://ex.org/b> <http://ex.org/p> "1" .`,
+		`<http://ex.org/c> <http://ex.org/p> "1" .`,
+		`<http://ex.org/c> <http://ex.org/r> "1" .`,
+		`<http://ex.org/d> <http://ex.org/r> "1" .`,
+		`<http://ex.org/d> <http://ex.org/s> "1" .`,
+		`<http://ex.org/e> <http://ex.org/s> "1" .`,
+		`<http://ex.org/f> <http://ex.org/s> "1" .`,
+		`<http://ex.org/f> <http://ex.org/q> "1" .`,
+		`<http://ex.org/g> <http://ex.org/s> "1" .`,
+	)
+	expected := New(false, 1)
+	expected.TwoPass(both, 0)
+
+	t.Run("re-sorting paths", func(t *testing.T) {
+		tree := New(false, 1)
+		tree.TwoPass(first, 0)
+		other := New(false, 1)
+		other.TwoPass(second, 0)
+
+		assert.NoError(t, tree.Merge(other))
+		assert.Equal(t, transactionsOf(expected), transactionsOf(tree))
+		assert.Equal(t, 0.0, tree.SortOrderDrift())
+		assert.EqualValues(t, 7, tree.Root.Support)
+		assert.EqualValues(t, 7, tree.Meta.Subjects)
+		assert.EqualValues(t, 4, tree.PropMap["http://ex.org/s"].TotalCount)
+		assert.EqualValues(t, 2, tree.Support(IList{tree.PropMap["http://ex.org/q"]}))
+		assert.EqualValues(t, 1, tree.Support(IList{tree.PropMap["http://ex.org/q"], tree.PropMap["http://ex.org/s"]}))
+
+		// the other tree is unchanged
+		assert.EqualValues(t, 4, other.Root.Support)
+		assert.EqualValues(t, 1, other.PropMap["http://ex.org/q"].TotalCount)
+	})
+
+	t.Run("from stored and compact trees", func(t *testing.T) {
+		other := New(false, 1)
+		other.TwoPass(second, 0)
+		other.Compact()
+		path := filepath.Join(t.TempDir(), "second.bin")
+		assert.NoError(t, other.Save(path))
+		loaded, err := Load(path)
+		assert.NoError(t, err)
+
+		tree := New(false, 1)
+		tree.TwoPass(first, 0)
+		assert.NoError(t, tree.Merge(loaded))
+		assert.Equal(t, transactionsOf(expected), transactionsOf(tree))
+	})
+
+	t.Run("incompatible trees", func(t *testing.T) {
+		tree := New(false, 1)
+		tree.TwoPass(first, 0)
+		assert.ErrorIs(t, tree.Merge(New(true, 1)), ErrIncompatibleTrees)
+		other := New(false, 1)
+		other.Meta.Config = &BuildConfig{TypePredicates: []string{"http://schema.org/additionalType"}}
+		assert.ErrorIs(t, tree.Merge(other), ErrIncompatibleTrees)
+
+		tree.Compact()
+		assert.Equal(t, ErrReadOnly, tree.Merge(New(false, 1)))
+	})
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package schematree
+
+import (
+	"io"
+	"os"
+	"unsafe"
+)
+
+// mapFile reads the whole file into memory on systems without mmap support. The buffer is allocated
+// as words, so the arrays of the flat layout are aligned.
+func mapFile(f *os.File, size int) (data []byte, unmap func() error, err error) {
+	words := make([]uint64, (size+7)/8)
+	data = unsafe.Slice((*byte)(unsafe.Pointer(&words[0])), size)
+	if _, err = io.ReadFull(f, data); err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
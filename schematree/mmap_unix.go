@@ -0,0 +1,17 @@
+//go:build unix
+
+package schematree
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the file read-only into memory. The pages are shared with all processes mapping the same file.
+func mapFile(f *os.File, size int) (data []byte, unmap func() error, err error) {
+	data, err = syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
@@ -0,0 +1,237 @@
+package schematree
+
+import (
+	"math/rand"
+	"sort"
+
+	rio "recommender/io"
+)
+
+// ObjectStats describes the objects of a property, so that users know what kind of value to enter for a
+// recommended property. The histograms only keep their most frequent entries, see maxObjectStatsEntries.
+type ObjectStats struct {
+	IRIs       uint64            `json:"iris"`
+	BlankNodes uint64            `json:"blankNodes"`
+	Literals   uint64            `json:"literals"`
+	Datatypes  map[string]uint64 `json:"datatypes,omitempty"` // datatypes of the literals, xsd:string and rdf:langString for plain literals
+	Languages  map[string]uint64 `json:"languages,omitempty"` // language tags of the literals
+
+	// ObjectTypes counts the types of a sample of the IRI and blank node objects. Only objects that are
+	// subjects of the dataset have types, Sampled is the size of the whole sample.
+	Sampled     uint64            `json:"sampled,omitempty"`
+	ObjectTypes map[string]uint64 `json:"objectTypes,omitempty"`
+
+	// Stale is set if subjects with the property have been added or removed after the statistics were collected,
+	// see ApplyDelta, so they describe the dataset the tree was built from.
+	Stale bool `json:"stale,omitempty"`
+}
+
+// maxObjectStatsEntries is the number of entries kept per histogram of the ObjectStats.
+const maxObjectStatsEntries = 10
+
+// maxObjectSamples is the number of objects sampled per property to find their types.
+const maxObjectSamples = 100
+
+const (
+	xsdString     = "http://www.w3.org/2001/XMLSchema#string"
+	rdfLangString = "http://www.w3.org/1999/02/22-rdf-syntax-ns#langString"
+)
+
+// ObjectStatsOf returns the statistics of the objects of a property, nil if the tree has none.
+func (tree *SchemaTree) ObjectStatsOf(property *IItem) *ObjectStats {
+	return tree.Meta.ObjectStats[*property.Str]
+}
+
+// markStaleObjectStats marks the statistics of the properties of a subject that has been added or removed after
+// the construction, as their objects cannot be counted again without the dataset.
+func (tree *SchemaTree) markStaleObjectStats(s *SubjectSummary) {
+	for item := range s.Properties {
+		if stats := tree.Meta.ObjectStats[*item.Str]; stats != nil {
+			stats.Stale = true
+		}
+	}
+}
+
+// objectCounts collects the statistics of the objects of one property.
+type objectCounts struct {
+	ObjectStats
+	datatypes map[string]*uint64
+	languages map[string]*uint64
+	types     map[string]uint64
+	seen      uint64   // number of IRI and blank node objects offered to the sample
+	sample    []string // reservoir sample of the IRI and blank node objects
+}
+
+// objectCollector gathers the ObjectStats of all properties while a dataset is read. In the first pass, the
+// kinds of the objects are counted and a sample of them is drawn. The types of the sampled objects are only
+// known once their own triples are read, which is done in a second pass.
+// NOT thread-safe, it is used by the parsing routine of SubjectSummaryReader.
+type objectCollector struct {
+	properties map[string]*objectCounts
+	random     *rand.Rand
+	sampled    map[string][]*objectCounts // properties that sampled an object, once per time it was sampled
+}
+
+func newObjectCollector() *objectCollector {
+	return &objectCollector{
+		properties: make(map[string]*objectCounts),
+		random:     rand.New(rand.NewSource(1)), // reproducible samples
+	}
+}
+
+// observe records the object of a triple. Only the objects of predicates that are read as properties are counted,
+// the types of the sampled objects are taken from all type annotations.
+func (c *objectCollector) observe(trip *rio.Triple, class *predicateClass, isTypeObject bool) {
+	if c.sampled != nil {
+		// second pass: find the types of the sampled objects
+		if isTypeObject {
+			if properties, ok := c.sampled[trip.Subject.Identifier()]; ok {
+				typeStr := trip.Object.Identifier()
+				for _, counts := range properties {
+					counts.types[typeStr]++
+				}
+			}
+		}
+		return
+	}
+
+	if class.item == nil {
+		return
+	}
+	if class.objects == nil {
+		class.objects = &objectCounts{datatypes: make(map[string]*uint64), languages: make(map[string]*uint64)}
+		c.properties[*class.item.Str] = class.objects
+	}
+	counts := class.objects
+	object := &trip.Object
+	switch object.Kind {
+	case rio.IRI, rio.BlankNode:
+		if object.Kind == rio.IRI {
+			counts.IRIs++
+		} else {
+			counts.BlankNodes++
+		}
+		counts.seen++
+		if len(counts.sample) < maxObjectSamples {
+			counts.sample = append(counts.sample, object.Identifier())
+		} else if i := c.random.Int63n(int64(counts.seen)); i < maxObjectSamples {
+			counts.sample[i] = object.Identifier()
+		}
+	case rio.Literal:
+		counts.Literals++
+		switch {
+		case len(object.Lang) > 0:
+			increment(counts.datatypes, []byte(rdfLangString))
+			increment(counts.languages, object.Lang)
+		case len(object.Datatype) > 0:
+			increment(counts.datatypes, object.Datatype)
+		default:
+			increment(counts.datatypes, []byte(xsdString))
+		}
+	}
+}
+
+// increment counts a key without allocating a string for keys that have been seen before.
+func increment(counts map[string]*uint64, key []byte) {
+	if n, ok := counts[string(key)]; ok {
+		*n++
+		return
+	}
+	n := uint64(1)
+	counts[string(key)] = &n
+}
+
+// startTypePass prepares the second pass, which looks up the types of the sampled objects.
+func (c *objectCollector) startTypePass() {
+	c.sampled = make(map[string][]*objectCounts)
+	for _, counts := range c.properties {
+		counts.types = make(map[string]uint64)
+		counts.Sampled = uint64(len(counts.sample))
+		for _, object := range counts.sample {
+			c.sampled[object] = append(c.sampled[object], counts)
+		}
+		counts.sample = nil
+	}
+}
+
+// stats returns the statistics of all properties that have been read, keeping the most frequent entries.
+func (c *objectCollector) stats() map[string]*ObjectStats {
+	result := make(map[string]*ObjectStats, len(c.properties))
+	for iri, counts := range c.properties {
+		stats := counts.ObjectStats
+		stats.Datatypes = topEntries(dereference(counts.datatypes))
+		stats.Languages = topEntries(dereference(counts.languages))
+		stats.ObjectTypes = topEntries(counts.types)
+		result[iri] = &stats
+	}
+	return result
+}
+
+func dereference(counts map[string]*uint64) map[string]uint64 {
+	result := make(map[string]uint64, len(counts))
+	for key, n := range counts {
+		result[key] = *n
+	}
+	return result
+}
+
+// topEntries keeps the maxObjectStatsEntries most frequent entries of a histogram, nil if it is empty.
+func topEntries(counts map[string]uint64) map[string]uint64 {
+	if len(counts) == 0 {
+		return nil
+	}
+	if len(counts) <= maxObjectStatsEntries {
+		return counts
+	}
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	top := make(map[string]uint64, maxObjectStatsEntries)
+	for _, key := range keys[:maxObjectStatsEntries] {
+		top[key] = counts[key]
+	}
+	return top
+}
+
+// mergeObjectStats adds the statistics of another tree, e.g. when trees are merged.
+func mergeObjectStats(into map[string]*ObjectStats, other map[string]*ObjectStats) map[string]*ObjectStats {
+	if len(other) == 0 {
+		return into
+	}
+	if into == nil {
+		into = make(map[string]*ObjectStats, len(other))
+	}
+	sum := func(a, b map[string]uint64) map[string]uint64 {
+		result := make(map[string]uint64, len(a)+len(b))
+		for key, n := range a {
+			result[key] += n
+		}
+		for key, n := range b {
+			result[key] += n
+		}
+		return topEntries(result)
+	}
+	for iri, o := range other {
+		s, ok := into[iri]
+		if !ok {
+			s = &ObjectStats{}
+			into[iri] = s
+		}
+		s.IRIs += o.IRIs
+		s.BlankNodes += o.BlankNodes
+		s.Literals += o.Literals
+		s.Sampled += o.Sampled
+		s.Datatypes = sum(s.Datatypes, o.Datatypes)
+		s.Languages = sum(s.Languages, o.Languages)
+		s.ObjectTypes = sum(s.ObjectTypes, o.ObjectTypes)
+		s.Stale = s.Stale || o.Stale
+	}
+	return into
+}
@@ -0,0 +1,76 @@
+package schematree
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectStats(t *testing.T) {
+	dataset := writeLines(t, "dataset.nt",
+		`<http://ex.org/a> <http://ex.org/name> "a" .`,
+		`<http://ex.org/a> <http://ex.org/label> "A"@en .`,
+		`<http://ex.org/a> <http://ex.org/label> "A"@de .`,
+		`<http://ex.org/a> <http://ex.org/country> <http://ex.org/Germany> .`,
+		`<http://ex.org/a> <http://ex.org/population> "5"^^<http://www.w3.org/2001/XMLSchema#integer> .`,
+		`<http://ex.org/a> <http://ex.org/address> _:x .`,
+		`<http://ex.org/b> <http://ex.org/name> "b" .`,
+		`<http://ex.org/b> <http://ex.org/country> <http://ex.org/France> .`,
+		`<http://ex.org/Germany> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/Country> .`,
+		`<http://ex.org/Germany> <http://ex.org/name> "Germany" .`,
+		`_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/PostalAddress> .`,
+	)
+	tree := New(false, 1)
+	tree.TwoPass(dataset, 0)
+	stats := tree.Meta.ObjectStats
+
+	t.Run("object kinds and literals", func(t *testing.T) {
+		assert.Equal(t, &ObjectStats{Literals: 3, Datatypes: map[string]uint64{xsdString: 3}}, stats["http://ex.org/name"])
+		assert.Equal(t, &ObjectStats{
+			Literals:  2,
+			Datatypes: map[string]uint64{rdfLangString: 2},
+			Languages: map[string]uint64{"en": 1, "de": 1},
+		}, stats["http://ex.org/label"])
+		assert.Equal(t, map[string]uint64{"http://www.w3.org/2001/XMLSchema#integer": 1}, stats["http://ex.org/population"].Datatypes)
+		assert.Equal(t, stats["http://ex.org/name"], tree.ObjectStatsOf(tree.PropMap["http://ex.org/name"]))
+	})
+
+	t.Run("object types", func(t *testing.T) {
+		assert.Equal(t, &ObjectStats{IRIs: 2, Sampled: 2, ObjectTypes: map[string]uint64{"http://ex.org/Country": 1}}, stats["http://ex.org/country"])
+		assert.Equal(t, &ObjectStats{BlankNodes: 1, Sampled: 1, ObjectTypes: map[string]uint64{"http://ex.org/PostalAddress": 1}}, stats["http://ex.org/address"])
+	})
+
+	t.Run("stored in the metadata", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tree.bin")
+		assert.NoError(t, tree.Save(path))
+		meta, err := ReadMetadata(path)
+		assert.NoError(t, err)
+		assert.Equal(t, stats, meta.ObjectStats)
+	})
+
+	t.Run("single pass and merge", func(t *testing.T) {
+		single := New(false, 1)
+		single.SinglePass(dataset, 0, 2)
+		assert.Equal(t, stats["http://ex.org/label"], single.Meta.ObjectStats["http://ex.org/label"])
+		assert.EqualValues(t, 2, single.Meta.ObjectStats["http://ex.org/country"].IRIs)
+		assert.Nil(t, single.Meta.ObjectStats["http://ex.org/country"].ObjectTypes)
+
+		assert.NoError(t, single.Merge(tree))
+		assert.EqualValues(t, 6, single.Meta.ObjectStats["http://ex.org/name"].Literals)
+		assert.Equal(t, map[string]uint64{"en": 2, "de": 2}, single.Meta.ObjectStats["http://ex.org/label"].Languages)
+		assert.Equal(t, map[string]uint64{"http://ex.org/Country": 1}, single.Meta.ObjectStats["http://ex.org/country"].ObjectTypes)
+	})
+
+	t.Run("most frequent entries", func(t *testing.T) {
+		counts := make(map[string]uint64)
+		for i := 0; i < maxObjectStatsEntries+5; i++ {
+			counts[fmt.Sprint(i)] = uint64(i)
+		}
+		top := topEntries(counts)
+		assert.Len(t, top, maxObjectStatsEntries)
+		assert.Contains(t, top, fmt.Sprint(maxObjectStatsEntries+4))
+		assert.NotContains(t, top, "0")
+	})
+}
@@ -0,0 +1,185 @@
+package schematree
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PruneStats summarizes the pruning of a tree.
+type PruneStats struct {
+	MinSup      uint64
+	NodesBefore uint64
+	NodesAfter  uint64
+	BytesBefore uint64 // memory used by the nodes, see NodeMemUsage
+	BytesAfter  uint64
+}
+
+func (s PruneStats) String() string {
+	return fmt.Sprintf("pruned with minimum support %v: %v -> %v nodes, %.2f -> %.2f MiB node memory (%.1f%% saved)",
+		s.MinSup, s.NodesBefore, s.NodesAfter, bToMb(s.BytesBefore), bToMb(s.BytesAfter),
+		100*(1-float64(s.BytesAfter)/float64(s.BytesBefore)))
+}
+
+// Prune removes all nodes whose support is below minSup, together with their descendants, and sets MinSup
+// of the tree. Since the support of a node never exceeds the support of its parent, the remaining nodes
+// still form a tree. The supports of the remaining nodes are not changed, so Support stays exact for all
+// property sets whose nodes are kept. Compact and mapped trees are pruned into a new compact tree.
+func (tree *SchemaTree) Prune(minSup uint64) (stats PruneStats) {
+	t1 := time.Now()
+	stats.MinSup = minSup
+	stats.NodesBefore, stats.BytesBefore = tree.NodeMemUsage()
+
+	if tree.flat != nil {
+		pruned := tree.flat.prune(minSup)
+		tree.Close()
+		tree.flat = pruned
+	} else {
+		tree.Root.pruneChildren(minSup)
+		tree.relinkTraversalLists()
+	}
+	if minSup > tree.MinSup {
+		tree.MinSup = minSup
+	}
+
+	stats.NodesAfter, stats.BytesAfter = tree.NodeMemUsage()
+	fmt.Printf("Pruning: %v (%v)\n", stats, time.Since(t1))
+	return
+}
+
+// pruneChildren removes all descendants of the node whose support is below minSup.
+func (node *SchemaNode) pruneChildren(minSup uint64) {
+	kept := node.Children[:0]
+	for _, child := range node.Children {
+		if child.Support >= minSup {
+			child.pruneChildren(minSup)
+			kept = append(kept, child)
+		}
+	}
+	for i := len(kept); i < len(node.Children); i++ {
+		node.Children[i] = nil // allow the removed subtrees to be garbage collected
+	}
+	node.Children = kept
+}
+
+// relinkTraversalLists rebuilds the traversal lists of all items from the nodes of the tree.
+func (tree *SchemaTree) relinkTraversalLists() {
+	for _, item := range tree.PropMap {
+		item.traversalPointer = nil
+	}
+	var link func(node *SchemaNode)
+	link = func(node *SchemaNode) {
+		for _, child := range node.Children {
+			child.nextSameID = child.ID.traversalPointer
+			child.ID.traversalPointer = child
+			link(child)
+		}
+	}
+	link(&tree.Root)
+}
+
+// prune copies all nodes with a support of at least minSup into a new flat tree. The breadth-first order
+// of the kept nodes is unchanged, so their children stay packed.
+func (flat *flatTree) prune(minSup uint64) *flatTree {
+	n := len(flat.nodeItem)
+	keep := func(node int) bool { return node == 0 || flat.nodeSupport.get(uint32(node)) >= minSup }
+
+	// keptBefore counts the kept nodes in front of every position, which is the new index of kept nodes
+	keptBefore := make([]uint32, n+1)
+	for node := 0; node < n; node++ {
+		keptBefore[node+1] = keptBefore[node]
+		if keep(node) {
+			keptBefore[node+1]++
+		}
+	}
+	kept := int(keptBefore[n])
+
+	pruned := &flatTree{
+		items:       flat.items,
+		nodeItem:    make([]uint32, kept),
+		nodeParent:  make([]uint32, kept),
+		nodeSupport: newSupportArray(kept, flat.nodeSupport.isWide()),
+		childStarts: make([]uint32, kept+1),
+	}
+	for node := 0; node < n; node++ {
+		if !keep(node) {
+			continue
+		}
+		i := keptBefore[node]
+		pruned.nodeItem[i] = flat.nodeItem[node]
+		pruned.nodeParent[i] = keptBefore[flat.nodeParent[node]]
+		pruned.nodeSupport.set(i, flat.nodeSupport.get(uint32(node)))
+		pruned.childStarts[i] = keptBefore[flat.childStarts[node]]
+	}
+	pruned.childStarts[kept] = uint32(kept)
+	pruned.buildTraversalLists()
+	return pruned
+}
+
+// SampleQuality measures how well a tree recommends properties of held-out subjects. Every property of a
+// sample subject is left out once and has to be recommended from the remaining properties and types.
+type SampleQuality struct {
+	Subjects uint64  // sample subjects with at least two known properties
+	Cases    uint64  // left-out properties
+	HitsAt1  float64 // share of the cases in which the left-out property is ranked first
+	HitsAt10 float64 // share of the cases in which the left-out property is among the first ten
+	MRR      float64 // mean reciprocal rank of the left-out properties, 0 if they are not recommended
+}
+
+func (q SampleQuality) String() string {
+	return fmt.Sprintf("%v subjects, %v cases: hits@1 %.4f, hits@10 %.4f, MRR %.4f", q.Subjects, q.Cases, q.HitsAt1, q.HitsAt10, q.MRR)
+}
+
+// EvaluateSample measures the recommendation quality on the first firstN subjects of a held-out dataset
+// (all subjects if firstN is zero). Properties unknown to the tree are ignored, the tree is not modified.
+func (tree *SchemaTree) EvaluateSample(fileName string, firstN uint64) (q SampleQuality) {
+	var lock sync.Mutex
+	var reciprocalRanks float64
+	var hits1, hits10 uint64
+
+	evaluate := func(s *SubjectSummary, properties IList, _ []string) {
+		if len(properties) < 2 {
+			return
+		}
+
+		var rr float64
+		var cases, h1, h10 uint64
+		for i, left := range properties {
+			if !left.IsProp() {
+				continue
+			}
+			input := make(IList, 0, len(properties)-1)
+			input = append(input, properties[:i]...)
+			input = append(input, properties[i+1:]...)
+			cases++
+			for rank, rec := range tree.RecommendProperty(input) {
+				if rec.Property == left {
+					rr += 1 / float64(rank+1)
+					if rank == 0 {
+						h1++
+					}
+					if rank < 10 {
+						h10++
+					}
+					break
+				}
+			}
+		}
+
+		lock.Lock()
+		q.Subjects++
+		q.Cases += cases
+		reciprocalRanks += rr
+		hits1 += h1
+		hits10 += h10
+		lock.Unlock()
+	}
+	tree.readKnownSubjects(fileName, firstN, evaluate)
+
+	if q.Cases > 0 {
+		q.HitsAt1 = float64(hits1) / float64(q.Cases)
+		q.HitsAt10 = float64(hits10) / float64(q.Cases)
+		q.MRR = reciprocalRanks / float64(q.Cases)
+	}
+	return
+}
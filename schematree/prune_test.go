@@ -0,0 +1,80 @@
+package schematree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// minNodeSupport returns the smallest support of all nodes below the root.
+func minNodeSupport(tree *SchemaTree) uint64 {
+	min := tree.Root.Support
+	for _, item := range tree.PropMap {
+		for node := item.traversalPointer; node != nil; node = node.nextSameID {
+			if node != &tree.Root && node.Support < min {
+				min = node.Support
+			}
+		}
+	}
+	return min
+}
+
+func TestPrune(t *testing.T) {
+	const minSup = 2
+
+	tree := New(true, 1)
+	tree.TwoPass(filePath, 0)
+	before := tree.EvaluateSample(filePath, 200)
+	p := tree.Root.Children[0]
+	for _, child := range tree.Root.Children {
+		if child.Support > p.Support {
+			p = child
+		}
+	}
+	support := tree.Support(IList{p.ID})
+	assert.GreaterOrEqual(t, support, uint64(minSup))
+
+	stats := tree.Prune(minSup)
+
+	t.Run("pointer layout", func(t *testing.T) {
+		assert.Less(t, stats.NodesAfter, stats.NodesBefore)
+		assert.Less(t, stats.BytesAfter, stats.BytesBefore)
+		assert.EqualValues(t, minSup, tree.MinSup)
+		assert.GreaterOrEqual(t, minNodeSupport(tree), uint64(minSup))
+		assert.Equal(t, support, tree.Support(IList{p.ID}))
+		nodes, _ := tree.NodeMemUsage()
+		assert.Equal(t, stats.NodesAfter, nodes)
+	})
+
+	t.Run("compact layout", func(t *testing.T) {
+		compact := New(true, 1)
+		compact.TwoPass(filePath, 0)
+		compact.Compact()
+		compactStats := compact.Prune(minSup)
+		assert.Equal(t, stats.NodesAfter, compactStats.NodesAfter)
+		assert.Equal(t, transactionsOf(tree), transactionsOf(compact))
+		for str, item := range tree.PropMap {
+			assert.Equal(t, asMap(tree.RecommendProperty(IList{item})), asMap(compact.RecommendProperty(IList{compact.PropMap[str]})))
+		}
+	})
+
+	t.Run("sample quality", func(t *testing.T) {
+		after := tree.EvaluateSample(filePath, 200)
+		assert.Greater(t, before.Cases, uint64(0))
+		assert.Equal(t, before.Cases, after.Cases)
+		assert.LessOrEqual(t, after.HitsAt10, before.HitsAt10)
+		assert.Greater(t, before.MRR, 0.0)
+	})
+
+	t.Run("during construction", func(t *testing.T) {
+		pruned := New(true, minSup)
+		pruned.TwoPass(filePath, 0)
+		assert.GreaterOrEqual(t, minNodeSupport(pruned), uint64(minSup))
+		for _, item := range pruned.PropMap {
+			if item.TotalCount < minSup {
+				assert.Nil(t, item.traversalPointer, *item.Str)
+			}
+		}
+		assert.Equal(t, tree.Root.Support, pruned.Root.Support)
+	})
+}
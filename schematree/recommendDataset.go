@@ -0,0 +1,62 @@
+package schematree
+
+// SubjectRecommendation lists the properties that are recommended for a subject of a dataset and that it lacks.
+type SubjectRecommendation struct {
+	Subject         string                `json:"subject"`
+	Recommendations []RecommendedProperty `json:"recommendations"`
+}
+
+// RecommendedProperty is a recommended property with its probability and the raw supports behind it.
+type RecommendedProperty struct {
+	Property    string  `json:"property"`
+	Probability float64 `json:"probability"`
+	Support     uint64  `json:"support"`
+	SetSupport  uint64  `json:"setSupport"`
+}
+
+// RecommendDataset recommends up to k missing properties (all if k is not positive) for each of the first
+// firstN subjects of a dataset (all subjects if firstN is zero). recommend gets the known properties and types of
+// a subject and the number of needed recommendations (all if it is zero), e.g. to run a workflow. It is asked for
+// k plus the number of items of the subject, and for all recommendations if that is not enough. Recommended
+// properties that the subject already has are left out, as well as types. Items of the dataset that the tree does
+// not know are ignored and the tree is not modified. report is called with the result of every subject,
+// concurrently and in no particular order.
+func (tree *SchemaTree) RecommendDataset(
+	fileName string,
+	firstN uint64,
+	k int,
+	recommend func(properties IList, k int) PropertyRecommendations,
+	report func(*SubjectRecommendation),
+) {
+	handle := func(s *SubjectSummary, list IList, _ []string) {
+		present := list.toSet()
+
+		result := &SubjectRecommendation{Subject: s.Str}
+		collect := func(recs PropertyRecommendations) {
+			result.Recommendations = []RecommendedProperty{}
+			for _, rec := range recs {
+				if k > 0 && len(result.Recommendations) == k {
+					break
+				}
+				if present[rec.Property] || !rec.Property.IsProp() || rec.Property == tree.Root.ID {
+					continue
+				}
+				result.Recommendations = append(result.Recommendations,
+					RecommendedProperty{*rec.Property.Str, rec.Probability, rec.Support, rec.SetSupport})
+			}
+		}
+
+		// backoff procedures may recommend present properties again, so k of them might be filtered out
+		needed := 0
+		if k > 0 {
+			needed = k + len(present)
+		}
+		recs := recommend(list, needed)
+		collect(recs)
+		if k > 0 && len(result.Recommendations) < k && len(recs) >= needed {
+			collect(recommend(list, 0)) // even more were filtered, e.g. types
+		}
+		report(result)
+	}
+	tree.readKnownSubjects(fileName, firstN, handle)
+}
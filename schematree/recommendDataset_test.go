@@ -0,0 +1,96 @@
+package schematree
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecommendDataset(t *testing.T) {
+	lines := []string{}
+	for i := 0; i < 10; i++ {
+		lines = append(lines,
+			fmt.Sprintf(`<http://ex.org/c%v> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/City> .`, i),
+			fmt.Sprintf(`<http://ex.org/c%v> <http://ex.org/name> "%v" .`, i, i),
+			fmt.Sprintf(`<http://ex.org/c%v> <http://ex.org/country> <http://ex.org/Germany> .`, i),
+		)
+		if i < 5 {
+			lines = append(lines, fmt.Sprintf(`<http://ex.org/c%v> <http://ex.org/mayor> <http://ex.org/m%v> .`, i, i))
+		}
+	}
+	tree := New(true, 1)
+	tree.TwoPass(writeLines(t, "dataset.nt", lines...), 0)
+
+	dataset := writeLines(t, "check.nt",
+		`<http://ex.org/a> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/City> .`,
+		`<http://ex.org/a> <http://ex.org/name> "a" .`,
+		`<http://ex.org/a> <http://ex.org/shoeSize> "42" .`,
+		`<http://ex.org/b> <http://ex.org/name> "b" .`,
+		`<http://ex.org/b> <http://ex.org/country> <http://ex.org/Germany> .`,
+		`<http://ex.org/b> <http://ex.org/mayor> <http://ex.org/m> .`,
+	)
+	recommend := func(properties IList, k int) PropertyRecommendations { return tree.RecommendTopK(properties, k) }
+	runWith := func(recommend func(IList, int) PropertyRecommendations, firstN uint64, k int) map[string][]RecommendedProperty {
+		var lock sync.Mutex
+		results := map[string][]RecommendedProperty{}
+		tree.RecommendDataset(dataset, firstN, k, recommend, func(result *SubjectRecommendation) {
+			lock.Lock()
+			defer lock.Unlock()
+			results[result.Subject] = result.Recommendations
+		})
+		return results
+	}
+	run := func(firstN uint64, k int) map[string][]RecommendedProperty { return runWith(recommend, firstN, k) }
+
+	t.Run("missing properties", func(t *testing.T) {
+		results := run(0, 10)
+		assert.Len(t, results, 2)
+		// the unknown shoe size is ignored, present properties and types are left out
+		a := results["http://ex.org/a"]
+		if assert.Len(t, a, 2) {
+			assert.Equal(t, RecommendedProperty{"http://ex.org/country", 1, 10, 10}, a[0])
+			assert.Equal(t, RecommendedProperty{"http://ex.org/mayor", 0.5, 5, 10}, a[1])
+		}
+		// all subjects with mayors are cities, the type itself is not recommended
+		b := results["http://ex.org/b"]
+		if assert.Len(t, b, 1) {
+			assert.Equal(t, "http://www.w3.org/1999/02/22-rdf-syntax-ns#type", b[0].Property)
+		}
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		results := run(0, 1)
+		assert.Len(t, results["http://ex.org/a"], 1)
+		assert.Len(t, run(1, 10), 1)
+	})
+
+	t.Run("present properties recommended again", func(t *testing.T) {
+		// like a backoff that leaves out input properties, the most frequent properties are present ones
+		unconditional := func(properties IList, k int) PropertyRecommendations { return tree.RecommendTopK(IList{}, k) }
+		a := runWith(unconditional, 0, 1)["http://ex.org/a"]
+		if assert.Len(t, a, 1) {
+			assert.Equal(t, "http://ex.org/country", a[0].Property)
+		}
+
+		// all k asked for are present, so all recommendations are needed
+		calls := 0
+		presentFirst := func(properties IList, k int) PropertyRecommendations {
+			calls++
+			if k > 0 {
+				recs := PropertyRecommendations{}
+				for len(recs) < k {
+					recs = append(recs, RankedPropertyCandidate{Property: properties[len(recs)%len(properties)]})
+				}
+				return recs
+			}
+			return tree.RecommendTopK(IList{}, 0)
+		}
+		b := runWith(presentFirst, 1, 1)["http://ex.org/a"]
+		if assert.Len(t, b, 1) {
+			assert.Equal(t, "http://ex.org/country", b[0].Property)
+		}
+		assert.Equal(t, 2, calls)
+	})
+}
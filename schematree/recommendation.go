@@ -3,12 +3,15 @@ package schematree
 import (
 	"fmt"
 	"sort"
+	"strings"
 )
 
 // RankedPropertyCandidate is a struct to rank suggestions
 type RankedPropertyCandidate struct {
 	Property    *IItem
 	Probability float64
+	Support     uint64 // number of subjects with the input set and the property
+	SetSupport  uint64 // number of subjects with the input set, the probability is only as reliable as this is large
 }
 
 // PropertyRecommendations is a list of RankedPropertyCandidates
@@ -53,10 +56,12 @@ func (tree *SchemaTree) Recommend(properties []string, types []string) PropertyR
 func (tree *SchemaTree) BuildPropertyList(properties []string, types []string) IList {
 
 	list := []*IItem{}
-	// Find IItems of property strings
+	// Find IItems of property strings, leaving out the predicates that are excluded by the build configuration.
+	// Incoming relations are given as inverse properties, e.g. `^http://www.wikidata.org/prop/direct/P50`.
+	config := tree.Config()
 	for _, pString := range properties {
 		p, ok := tree.PropMap[pString]
-		if ok {
+		if ok && config.ReadsPredicate(pString) {
 			list = append(list, p)
 		}
 	}
@@ -73,6 +78,43 @@ func (tree *SchemaTree) BuildPropertyList(properties []string, types []string) I
 	return list
 }
 
+// Direction selects outgoing properties, incoming (inverse) properties or both, see BuildConfig.InverseProperties.
+type Direction uint8
+
+// Directions of the recommended properties.
+const (
+	BothDirections Direction = iota
+	Outgoing
+	Incoming
+)
+
+// ParseDirection parses the name of a direction: "both" (or empty), "outgoing" or "incoming".
+func ParseDirection(name string) (Direction, error) {
+	switch name {
+	case "", "both":
+		return BothDirections, nil
+	case "outgoing", "out":
+		return Outgoing, nil
+	case "incoming", "in":
+		return Incoming, nil
+	}
+	return BothDirections, fmt.Errorf("unknown direction '%v', expected one of: both, outgoing, incoming", name)
+}
+
+// FilterDirection returns the recommendations of the given direction. Types are kept in every direction.
+func (ps PropertyRecommendations) FilterDirection(direction Direction) PropertyRecommendations {
+	if direction == BothDirections {
+		return ps
+	}
+	filtered := make(PropertyRecommendations, 0, len(ps))
+	for _, p := range ps {
+		if p.Property.IsType() || p.Property.IsInverse() == (direction == Incoming) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
 // RecommendProperty recommends a ranked list of property candidates by given IItems
 func (tree *SchemaTree) RecommendProperty(properties IList) (ranked PropertyRecommendations) {
 
@@ -80,127 +122,155 @@ func (tree *SchemaTree) RecommendProperty(properties IList) (ranked PropertyReco
 
 		properties.Sort() // descending by support
 
-		pSet := properties.toSet()
+		// now that all candidates have been collected, rank them
+		ranked = rankCandidates(tree.conditionalCandidates(properties, (*IItem).IsProp))
+	} else {
+		// as before, the marginal recommendations include the types, only value items are left out
+		ranked = tree.marginalCandidates(func(item *IItem) bool { return !item.IsValue() })
+	}
 
-		candidates := make(map[*IItem]uint32)
+	return
+}
 
-		var makeCandidates func(startNode *SchemaNode)
-		makeCandidates = func(startNode *SchemaNode) { // head hunter function ;)
-			for _, child := range startNode.Children {
-				if child.ID.IsProp() {
-					candidates[child.ID] += child.Support
-				}
-				makeCandidates(child)
-			}
-		}
+// RecommendPropertiesAndTypes recommends a ranked list of property and type candidates by given IItems
+func (tree *SchemaTree) RecommendPropertiesAndTypes(properties IList) (ranked PropertyRecommendations) {
 
-		// the least frequent property from the list is farthest from the root
-		rarestProperty := properties[len(properties)-1]
-
-		var setSupport uint64
-		// walk from each "leaf" instance of that property towards the root...
-		for leaf := rarestProperty.traversalPointer; leaf != nil; leaf = leaf.nextSameID { // iterate all instances for that property
-			if leaf.prefixContains(properties) {
-				setSupport += uint64(leaf.Support) // number of occuences of this set of properties in the current branch
-
-				// walk up
-				for cur := leaf; cur.parent != nil; cur = cur.parent {
-					if !(pSet[cur.ID]) {
-						if cur.ID.IsProp() {
-							candidates[cur.ID] += leaf.Support
-						}
-					}
-				}
-				// walk down
-				makeCandidates(leaf)
-			}
-		}
+	if len(properties) > 0 {
+
+		properties.Sort() // descending by support
 
 		// now that all candidates have been collected, rank them
-		i := 0
-		setSup := float64(setSupport)
-		ranked = make([]RankedPropertyCandidate, len(candidates), len(candidates))
-		for candidate, support := range candidates {
-			ranked[i] = RankedPropertyCandidate{candidate, float64(support) / setSup}
-			i++
+		ranked = rankCandidates(tree.conditionalCandidates(properties, func(item *IItem) bool { return !item.IsValue() }))
+	} else {
+		ranked = tree.marginalCandidates(func(item *IItem) bool { return !item.IsValue() })
+	}
+
+	return
+}
+
+// RecommendTypes recommends a ranked list of types (classes) for a subject with the given properties and types.
+// The probability of a type is its conditional probability given the input, i.e. the share of the subjects with
+// all given properties and types that also have the type. Untyped trees have no type recommendations.
+func (tree *SchemaTree) RecommendTypes(properties []string, types []string) PropertyRecommendations {
+	return tree.RecommendType(tree.BuildPropertyList(properties, types))
+}
+
+// marginalCandidates ranks the accepted items by the share of all transactions they occur in, which are the
+// recommendations for the empty input. The items are already sorted by their TotalCount.
+func (tree *SchemaTree) marginalCandidates(accept func(*IItem) bool) PropertyRecommendations {
+	// TODO: Race condition on propMap: fatal error: concurrent map iteration and map write
+	items := make([]*IItem, len(tree.PropMap))
+	for _, prop := range tree.PropMap {
+		items[int(prop.SortOrder)] = prop
+	}
+	setSup := float64(tree.Root.Support) // empty set occured in all transactions
+	ranked := make([]RankedPropertyCandidate, 0, len(items))
+	for _, prop := range items {
+		if accept(prop) {
+			ranked = append(ranked, RankedPropertyCandidate{prop, float64(prop.TotalCount) / setSup, prop.TotalCount, tree.Root.Support})
 		}
+	}
+	return ranked
+}
 
-		// sort descending by support
-		sort.Slice(ranked, func(i, j int) bool { return ranked[i].Probability > ranked[j].Probability })
-	} else {
-		// TODO: Race condition on propMap: fatal error: concurrent map iteration and map write
-		// fmt.Println(tree.Root.Support)
-		setSup := float64(tree.Root.Support) // empty set occured in all transactions
-		ranked = make([]RankedPropertyCandidate, len(tree.PropMap), len(tree.PropMap))
-		for _, prop := range tree.PropMap {
-			ranked[int(prop.SortOrder)] = RankedPropertyCandidate{prop, float64(prop.TotalCount) / setSup}
+// RecommendType recommends a ranked list of type candidates by given IItems, see RecommendTypes
+func (tree *SchemaTree) RecommendType(properties IList) PropertyRecommendations {
+	if len(properties) == 0 {
+		// the types occured in TotalCount of all transactions
+		candidates := make(map[*IItem]uint64)
+		for _, item := range tree.PropMap {
+			if item.IsType() && item.TotalCount > 0 {
+				candidates[item] = item.TotalCount
+			}
 		}
+		return rankCandidates(candidates, tree.Root.Support)
 	}
 
-	return
+	properties.Sort() // descending by support
+	return rankCandidates(tree.conditionalCandidates(properties, (*IItem).IsType))
 }
 
-// RecommendPropertiesAndTypes recommends a ranked list of property and type candidates by given IItems
-func (tree *SchemaTree) RecommendPropertiesAndTypes(properties IList) (ranked PropertyRecommendations) {
+// RecommendValues recommends likely values of a value predicate (see BuildConfig.ValuePredicates) for a
+// subject with the given properties. The candidates are the property=value items of the predicate, ranked
+// by their probability to cooccur with the properties. Known values can be part of the properties.
+func (tree *SchemaTree) RecommendValues(properties IList, predicate string) PropertyRecommendations {
+	prefix := valueItem(predicate, "")
+	isValue := func(item *IItem) bool { return strings.HasPrefix(*item.Str, prefix) }
+
+	if len(properties) == 0 {
+		// the values occured in TotalCount of all transactions
+		candidates := make(map[*IItem]uint64)
+		for _, item := range tree.PropMap {
+			if isValue(item) && item.TotalCount > 0 {
+				candidates[item] = item.TotalCount
+			}
+		}
+		return rankCandidates(candidates, tree.Root.Support)
+	}
 
-	if len(properties) > 0 {
+	properties.Sort() // descending by support
+	return rankCandidates(tree.conditionalCandidates(properties, isValue))
+}
 
-		properties.Sort() // descending by support
+// conditionalCandidates collects the items that cooccur with the properties, which have to be sorted, together
+// with their supports and the support of the property set. Only the items accepted by accept are collected,
+// all of them if accept is nil.
+func (tree *SchemaTree) conditionalCandidates(properties IList, accept func(*IItem) bool) (map[*IItem]uint64, uint64) {
+	if tree.flat != nil {
+		return tree.flat.candidates(properties, accept)
+	}
 
-		pSet := properties.toSet()
+	pSet := properties.toSet()
 
-		candidates := make(map[*IItem]uint32)
+	candidates := make(map[*IItem]uint64)
 
-		var makeCandidates func(startNode *SchemaNode)
-		makeCandidates = func(startNode *SchemaNode) { // head hunter function ;)
-			for _, child := range startNode.Children {
+	var makeCandidates func(startNode *SchemaNode)
+	makeCandidates = func(startNode *SchemaNode) { // head hunter function ;)
+		for _, child := range startNode.Children {
+			if accept == nil || accept(child.ID) {
 				candidates[child.ID] += child.Support
-				makeCandidates(child)
 			}
+			makeCandidates(child)
 		}
+	}
 
-		// the least frequent property from the list is farthest from the root
-		rarestProperty := properties[len(properties)-1]
+	// the least frequent property from the list is farthest from the root
+	rarestProperty := properties[len(properties)-1]
 
-		var setSupport uint64
-		// walk from each "leaf" instance of that property towards the root...
-		for leaf := rarestProperty.traversalPointer; leaf != nil; leaf = leaf.nextSameID { // iterate all instances for that property
-			if leaf.prefixContains(properties) {
-				setSupport += uint64(leaf.Support) // number of occuences of this set of properties in the current branch
+	var setSupport uint64
+	// walk from each "leaf" instance of that property towards the root...
+	for leaf := rarestProperty.traversalPointer; leaf != nil; leaf = leaf.nextSameID { // iterate all instances for that property
+		if leaf.prefixContains(properties) {
+			setSupport += leaf.Support // number of occuences of this set of properties in the current branch
 
-				// walk up
-				for cur := leaf; cur.parent != nil; cur = cur.parent {
-					if !(pSet[cur.ID]) {
+			// walk up
+			for cur := leaf; cur.parent != nil; cur = cur.parent {
+				if !(pSet[cur.ID]) {
+					if accept == nil || accept(cur.ID) {
 						candidates[cur.ID] += leaf.Support
 					}
 				}
-				// walk down
-				makeCandidates(leaf)
 			}
+			// walk down
+			makeCandidates(leaf)
 		}
+	}
+	return candidates, setSupport
+}
 
-		// now that all candidates have been collected, rank them
-		i := 0
-		setSup := float64(setSupport)
-		ranked = make([]RankedPropertyCandidate, len(candidates), len(candidates))
-		for candidate, support := range candidates {
-			ranked[i] = RankedPropertyCandidate{candidate, float64(support) / setSup}
-			i++
-		}
-
-		// sort descending by support
-		sort.Slice(ranked, func(i, j int) bool { return ranked[i].Probability > ranked[j].Probability })
-	} else {
-		// TODO: Race condition on propMap: fatal error: concurrent map iteration and map write
-		// fmt.Println(tree.Root.Support)
-		setSup := float64(tree.Root.Support) // empty set occured in all transactions
-		ranked = make([]RankedPropertyCandidate, len(tree.PropMap), len(tree.PropMap))
-		for _, prop := range tree.PropMap {
-			ranked[int(prop.SortOrder)] = RankedPropertyCandidate{prop, float64(prop.TotalCount) / setSup}
-		}
+// rankCandidates computes the probabilities of the candidates and sorts them descending
+func rankCandidates(candidates map[*IItem]uint64, setSupport uint64) PropertyRecommendations {
+	i := 0
+	setSup := float64(setSupport)
+	ranked := make([]RankedPropertyCandidate, len(candidates), len(candidates))
+	for candidate, support := range candidates {
+		ranked[i] = RankedPropertyCandidate{candidate, float64(support) / setSup, support, setSupport}
+		i++
 	}
 
-	return
+	// sort descending by support
+	sort.Slice(ranked, func(i, j int) bool { return ranked[i].Probability > ranked[j].Probability })
+	return ranked
 }
 
 // func (tree *schemaTree) recommendType(properties iList) typeRecommendations {
@@ -53,4 +53,64 @@ func TestRecommendProperty(t *testing.T) {
 		assert.False(t, list.contains("http://www.wikidata.org/prop/direct/P625", 0.5))            // coordinate location
 	})
 
+	t.Run("Empty input", func(t *testing.T) {
+		list := tree.RecommendProperty(IList{})
+		assert.True(t, list.contains("http://www.wikidata.org/prop/direct/P31", 0)) // InstanceOf
+		assert.True(t, list.contains("t#http://www.wikidata.org/entity/Q515", 0))   // City
+	})
+
+}
+
+func TestRecommendTypes(t *testing.T) {
+	dataset := writeLines(t, "dataset.nt",
+		`<http://ex.org/berlin> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/City> .`,
+		`<http://ex.org/berlin> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/Capital> .`,
+		`<http://ex.org/berlin> <http://ex.org/mayor> <http://ex.org/x> .`,
+		`<http://ex.org/bonn> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/City> .`,
+		`<http://ex.org/bonn> <http://ex.org/mayor> <http://ex.org/y> .`,
+		`<http://ex.org/alice> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/Person> .`,
+		`<http://ex.org/alice> <http://ex.org/name> "Alice" .`,
+		`<http://ex.org/unknown> <http://ex.org/name> "?" .`,
+	)
+	tree := New(true, 1)
+	tree.TwoPass(dataset, 0)
+
+	t.Run("conditional probabilities", func(t *testing.T) {
+		recs := tree.RecommendTypes([]string{"http://ex.org/mayor"}, nil)
+		assert.Equal(t, map[string]float64{"t#http://ex.org/City": 1, "t#http://ex.org/Capital": 0.5}, asMap(recs))
+		assert.Equal(t, "t#http://ex.org/City", *recs[0].Property.Str)
+
+		recs = tree.RecommendTypes([]string{"http://ex.org/name"}, nil)
+		assert.Equal(t, map[string]float64{"t#http://ex.org/Person": 0.5}, asMap(recs))
+
+		// known types are part of the input and not recommended again
+		recs = tree.RecommendTypes(nil, []string{"http://ex.org/Capital"})
+		assert.Equal(t, map[string]float64{"t#http://ex.org/City": 1}, asMap(recs))
+	})
+
+	t.Run("without input", func(t *testing.T) {
+		recs := tree.RecommendTypes(nil, nil)
+		assert.Equal(t, map[string]float64{
+			"t#http://ex.org/City":    0.5,
+			"t#http://ex.org/Capital": 0.25,
+			"t#http://ex.org/Person":  0.25,
+		}, asMap(recs))
+		iri, ok := recs[0].Property.TypeOf()
+		assert.True(t, ok)
+		assert.Equal(t, "http://ex.org/City", iri)
+	})
+
+	t.Run("compact trees", func(t *testing.T) {
+		compact := New(true, 1)
+		compact.TwoPass(dataset, 0)
+		compact.Compact()
+		assert.Equal(t, asMap(tree.RecommendTypes([]string{"http://ex.org/mayor"}, nil)),
+			asMap(compact.RecommendTypes([]string{"http://ex.org/mayor"}, nil)))
+	})
+
+	t.Run("untyped trees", func(t *testing.T) {
+		untyped := New(false, 1)
+		untyped.TwoPass(dataset, 0)
+		assert.Empty(t, untyped.RecommendTypes([]string{"http://ex.org/mayor"}, nil))
+	})
 }
@@ -0,0 +1,330 @@
+package schematree
+
+import (
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MiningOptions are the thresholds of MineRules.
+type MiningOptions struct {
+	MinSupport    uint64  `json:"minSupport"`    // minimum number of subjects of a frequent set
+	MaxSize       int     `json:"maxSize"`       // maximum number of items of a frequent set, 0 for no limit
+	MinConfidence float64 `json:"minConfidence"` // minimum confidence of a rule
+	MinLift       float64 `json:"minLift"`       // minimum lift of a rule
+}
+
+// FrequentSet is a set of items that at least MinSupport subjects have in common.
+type FrequentSet struct {
+	Items   []string `json:"items"`
+	Support uint64   `json:"support"` // number of subjects with all items
+}
+
+// AssociationRule states that subjects with all items of the antecedent tend to have the items of the
+// consequent as well.
+type AssociationRule struct {
+	Antecedent []string `json:"antecedent"`
+	Consequent []string `json:"consequent"`
+	Support    uint64   `json:"support"`    // number of subjects with the items of both sides
+	Confidence float64  `json:"confidence"` // share of the subjects with the antecedent that have the consequent
+	Lift       float64  `json:"lift"`       // confidence relative to the share of all subjects with the consequent
+}
+
+// MiningResult holds the frequent sets and rules mined from a tree.
+type MiningResult struct {
+	Subjects     uint64            `json:"subjects"`
+	Options      MiningOptions     `json:"options"`
+	FrequentSets []FrequentSet     `json:"frequentSets"`
+	Rules        []AssociationRule `json:"rules"`
+}
+
+// maxRuleItems is the size of the largest frequent sets that rules are derived from, as every split of a
+// set into antecedent and consequent is a rule.
+const maxRuleItems = 16
+
+// MineRules runs FP-Growth over the tree and returns all frequent item sets, i.e. sets of properties, types
+// and property=value items, together with the association rules between them that pass the thresholds.
+// The tree is the initial FP-tree: the prefix paths of the nodes of an item are its conditional pattern
+// base. Since the items on every path are sorted by their sort order, the conditional FP-trees that are
+// grown from these bases stay consistent. Works on all layouts; pruned trees only yield the sets whose
+// nodes have been kept.
+func (tree *SchemaTree) MineRules(options MiningOptions) *MiningResult {
+	t1 := time.Now()
+	if options.MinSupport == 0 {
+		options.MinSupport = 1
+	}
+	m := &miner{options: options, supports: make(map[string]uint64)}
+
+	for _, item := range tree.itemsBySortOrder() {
+		if item == tree.Root.ID {
+			continue
+		}
+		var support uint64
+		tree.prefixPaths(item, func(path []uint32, count uint64) {
+			support += count
+		})
+		if support < options.MinSupport {
+			continue
+		}
+		set := []uint32{item.SortOrder}
+		m.emit(set, support)
+		if options.MaxSize != 1 {
+			m.grow(m.conditional(func(visit func([]uint32, uint64)) { tree.prefixPaths(item, visit) }), set)
+		}
+	}
+
+	result := &MiningResult{
+		Subjects:     tree.Root.Support,
+		Options:      options,
+		FrequentSets: []FrequentSet{},
+		Rules:        []AssociationRule{},
+	}
+	items := tree.itemsBySortOrder()
+	names := func(set []uint32) []string {
+		strs := make([]string, len(set))
+		for i, sortOrder := range set {
+			strs[i] = *items[sortOrder].Str
+		}
+		return strs
+	}
+	for _, set := range m.sets {
+		result.FrequentSets = append(result.FrequentSets, FrequentSet{names(set), m.supports[setKey(set)]})
+	}
+	for _, set := range m.sets {
+		if len(set) < 2 || len(set) > maxRuleItems {
+			continue
+		}
+		support := m.supports[setKey(set)]
+		// every non-empty proper subset is an antecedent
+		for mask := 1; mask < 1<<len(set)-1; mask++ {
+			var antecedent, consequent []uint32
+			for i, item := range set {
+				if mask&(1<<i) != 0 {
+					antecedent = append(antecedent, item)
+				} else {
+					consequent = append(consequent, item)
+				}
+			}
+			confidence := float64(support) / float64(m.supports[setKey(antecedent)])
+			lift := confidence / (float64(m.supports[setKey(consequent)]) / float64(result.Subjects))
+			if confidence < options.MinConfidence || lift < options.MinLift {
+				continue
+			}
+			result.Rules = append(result.Rules, AssociationRule{names(antecedent), names(consequent), support, confidence, lift})
+		}
+	}
+
+	sort.SliceStable(result.FrequentSets, func(i, j int) bool {
+		a, b := result.FrequentSets[i], result.FrequentSets[j]
+		if a.Support != b.Support {
+			return a.Support > b.Support
+		}
+		if len(a.Items) != len(b.Items) {
+			return len(a.Items) < len(b.Items)
+		}
+		return strings.Join(a.Items, " ") < strings.Join(b.Items, " ")
+	})
+	sort.SliceStable(result.Rules, func(i, j int) bool {
+		a, b := result.Rules[i], result.Rules[j]
+		if a.Confidence != b.Confidence {
+			return a.Confidence > b.Confidence
+		}
+		if a.Lift != b.Lift {
+			return a.Lift > b.Lift
+		}
+		if a.Support != b.Support {
+			return a.Support > b.Support
+		}
+		return fmt.Sprint(a.Antecedent, a.Consequent) < fmt.Sprint(b.Antecedent, b.Consequent)
+	})
+	fmt.Printf("Mined %v frequent sets and %v rules with minimum support %v (%v)\n",
+		len(result.FrequentSets), len(result.Rules), options.MinSupport, time.Since(t1))
+	return result
+}
+
+// prefixPaths calls visit for every node of the item with the sort orders of its ancestors, starting at the
+// root, and the support of the node. The path is only valid during the call.
+func (tree *SchemaTree) prefixPaths(item *IItem, visit func(path []uint32, count uint64)) {
+	var path []uint32
+	if tree.flat != nil {
+		flat := tree.flat
+		for _, node := range flat.instances(item) {
+			path = path[:0]
+			for cur := flat.parent(node); cur != 0; cur = flat.parent(cur) {
+				path = append(path, flat.nodeItem[cur])
+			}
+			reverse(path)
+			visit(path, flat.nodeSupport.get(node))
+		}
+		return
+	}
+	for node := item.traversalPointer; node != nil; node = node.nextSameID {
+		path = path[:0]
+		for cur := node.parent; cur.parent != nil; cur = cur.parent {
+			path = append(path, cur.ID.SortOrder)
+		}
+		reverse(path)
+		visit(path, node.Support)
+	}
+}
+
+func reverse(path []uint32) {
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+}
+
+// fpTree is a conditional FP-tree of FP-Growth. Items are identified by their sort order in the SchemaTree.
+type fpTree struct {
+	root   fpNode
+	heads  map[uint32]*fpNode // first node of every item, the others are linked by next
+	counts map[uint32]uint64  // support of every item
+}
+
+type fpNode struct {
+	item     uint32
+	count    uint64
+	parent   *fpNode
+	children map[uint32]*fpNode
+	next     *fpNode
+}
+
+// insert adds a path with the given count.
+func (t *fpTree) insert(path []uint32, count uint64) {
+	node := &t.root
+	for _, item := range path {
+		child, ok := node.children[item]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[uint32]*fpNode)
+			}
+			child = &fpNode{item: item, parent: node, next: t.heads[item]}
+			t.heads[item] = child
+			node.children[item] = child
+		}
+		child.count += count
+		t.counts[item] += count
+		node = child
+	}
+}
+
+// prefixPaths works like SchemaTree.prefixPaths.
+func (t *fpTree) prefixPaths(item uint32, visit func(path []uint32, count uint64)) {
+	var path []uint32
+	for node := t.heads[item]; node != nil; node = node.next {
+		path = path[:0]
+		for cur := node.parent; cur != &t.root; cur = cur.parent {
+			path = append(path, cur.item)
+		}
+		reverse(path)
+		visit(path, node.count)
+	}
+}
+
+// miner collects the frequent sets found by FP-Growth.
+type miner struct {
+	options  MiningOptions
+	sets     [][]uint32        // sorted by sort order
+	supports map[string]uint64 // support by setKey
+}
+
+func (m *miner) emit(set []uint32, support uint64) {
+	sorted := append([]uint32(nil), set...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	m.sets = append(m.sets, sorted)
+	m.supports[setKey(sorted)] = support
+}
+
+// conditional builds the conditional FP-tree of a pattern base, leaving out the infrequent items.
+func (m *miner) conditional(base func(visit func(path []uint32, count uint64))) *fpTree {
+	counts := make(map[uint32]uint64)
+	base(func(path []uint32, count uint64) {
+		for _, item := range path {
+			counts[item] += count
+		}
+	})
+	t := &fpTree{heads: make(map[uint32]*fpNode), counts: make(map[uint32]uint64)}
+	var frequent []uint32
+	base(func(path []uint32, count uint64) {
+		frequent = frequent[:0]
+		for _, item := range path {
+			if counts[item] >= m.options.MinSupport {
+				frequent = append(frequent, item)
+			}
+		}
+		if len(frequent) > 0 {
+			t.insert(frequent, count)
+		}
+	})
+	return t
+}
+
+// grow emits the frequent sets that extend the suffix by items of its conditional FP-tree.
+func (m *miner) grow(t *fpTree, suffix []uint32) {
+	for item, support := range t.counts {
+		set := append(append([]uint32(nil), suffix...), item)
+		m.emit(set, support)
+		if m.options.MaxSize > 0 && len(set) >= m.options.MaxSize {
+			continue
+		}
+		item := item
+		cond := m.conditional(func(visit func([]uint32, uint64)) { t.prefixPaths(item, visit) })
+		if len(cond.counts) > 0 {
+			m.grow(cond, set)
+		}
+	}
+}
+
+// setKey identifies a sorted set of items.
+func setKey(set []uint32) string {
+	b := make([]byte, 4*len(set))
+	for i, item := range set {
+		binary.LittleEndian.PutUint32(b[4*i:], item)
+	}
+	return string(b)
+}
+
+// WriteJSON writes the frequent sets and rules as one JSON document.
+func (r *MiningResult) WriteJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(r)
+}
+
+// itemsCell writes the items of a set or of a side of a rule as a JSON array, since property=value items can
+// contain any separator.
+func itemsCell(items []string) string {
+	cell, _ := json.Marshal(items) // strings always encode
+	return string(cell)
+}
+
+// WriteSetsCSV writes the frequent sets as CSV. The items of a set are written as a JSON array.
+func (r *MiningResult) WriteSetsCSV(w io.Writer) error {
+	c := csv.NewWriter(w)
+	c.Comma = ';'
+	c.Write([]string{"Items", "Size", "Support", "RelativeSupport"})
+	for _, set := range r.FrequentSets {
+		c.Write([]string{itemsCell(set.Items), fmt.Sprint(len(set.Items)), fmt.Sprint(set.Support),
+			fmt.Sprint(float64(set.Support) / float64(r.Subjects))})
+	}
+	c.Flush()
+	return c.Error()
+}
+
+// WriteRulesCSV writes the rules as CSV. The items of each side are written as a JSON array.
+func (r *MiningResult) WriteRulesCSV(w io.Writer) error {
+	c := csv.NewWriter(w)
+	c.Comma = ';'
+	c.Write([]string{"Antecedent", "Consequent", "Support", "RelativeSupport", "Confidence", "Lift"})
+	for _, rule := range r.Rules {
+		c.Write([]string{itemsCell(rule.Antecedent), itemsCell(rule.Consequent), fmt.Sprint(rule.Support),
+			fmt.Sprint(float64(rule.Support) / float64(r.Subjects)), fmt.Sprint(rule.Confidence), fmt.Sprint(rule.Lift)})
+	}
+	c.Flush()
+	return c.Error()
+}
@@ -0,0 +1,123 @@
+package schematree
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMineRules(t *testing.T) {
+	dataset := writeLines(t, "dataset.nt",
+		`<http://ex.org/a> <http://ex.org/name> "a" .`,
+		`<http://ex.org/a> <http://ex.org/country> <http://ex.org/Germany> .`,
+		`<http://ex.org/a> <http://ex.org/mayor> <http://ex.org/x> .`,
+		`<http://ex.org/a> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/City> .`,
+		`<http://ex.org/b> <http://ex.org/name> "b" .`,
+		`<http://ex.org/b> <http://ex.org/country> <http://ex.org/France> .`,
+		`<http://ex.org/b> <http://ex.org/mayor> <http://ex.org/y> .`,
+		`<http://ex.org/b> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/City> .`,
+		`<http://ex.org/c> <http://ex.org/name> "c" .`,
+		`<http://ex.org/c> <http://ex.org/country> <http://ex.org/France> .`,
+		`<http://ex.org/d> <http://ex.org/name> "d" .`,
+		`<http://ex.org/d> <http://ex.org/birthDate> "2000" .`,
+		`<http://ex.org/e> <http://ex.org/name> "e" .`,
+		`<http://ex.org/e> <http://ex.org/birthDate> "2001" .`,
+		`<http://ex.org/e> <http://ex.org/country> <http://ex.org/France> .`,
+	)
+	tree := New(true, 1)
+	tree.TwoPass(dataset, 0)
+
+	// count the supports of all subsets of the transactions
+	expected := make(map[string]uint64)
+	for transaction, count := range transactionsOf(tree) {
+		items := strings.Split(transaction, " ")
+		for mask := 1; mask < 1<<len(items); mask++ {
+			var set []string
+			for i, item := range items {
+				if mask&(1<<i) != 0 {
+					set = append(set, item)
+				}
+			}
+			expected[strings.Join(set, " ")] += count
+		}
+	}
+	frequent := func(minSupport uint64) map[string]uint64 {
+		result := make(map[string]uint64)
+		for set, support := range expected {
+			if support >= minSupport {
+				result[set] = support
+			}
+		}
+		return result
+	}
+	setsOf := func(r *MiningResult) map[string]uint64 {
+		result := make(map[string]uint64)
+		for _, set := range r.FrequentSets {
+			items := append([]string(nil), set.Items...)
+			sort.Strings(items)
+			result[strings.Join(items, " ")] = set.Support
+		}
+		return result
+	}
+
+	t.Run("frequent sets", func(t *testing.T) {
+		assert.Equal(t, frequent(1), setsOf(tree.MineRules(MiningOptions{})))
+		assert.Equal(t, frequent(2), setsOf(tree.MineRules(MiningOptions{MinSupport: 2})))
+
+		limited := tree.MineRules(MiningOptions{MinSupport: 1, MaxSize: 2})
+		for _, set := range limited.FrequentSets {
+			assert.LessOrEqual(t, len(set.Items), 2)
+		}
+		assert.Equal(t, []string{"http://ex.org/name"}, limited.FrequentSets[0].Items)
+
+		compact := New(true, 1)
+		compact.TwoPass(dataset, 0)
+		compact.Compact()
+		assert.Equal(t, frequent(2), setsOf(compact.MineRules(MiningOptions{MinSupport: 2})))
+	})
+
+	t.Run("rules", func(t *testing.T) {
+		result := tree.MineRules(MiningOptions{MinSupport: 2, MinConfidence: 0.9, MinLift: 1.1})
+		assert.Contains(t, result.Rules, AssociationRule{
+			Antecedent: []string{"t#http://ex.org/City"},
+			Consequent: []string{"http://ex.org/mayor"},
+			Support:    2,
+			Confidence: 1,
+			Lift:       2.5,
+		})
+		for _, rule := range result.Rules {
+			assert.GreaterOrEqual(t, rule.Confidence, 0.9)
+			assert.GreaterOrEqual(t, rule.Lift, 1.1)
+		}
+		// every subject has a name, so it is no interesting consequent
+		for _, rule := range result.Rules {
+			assert.NotEqual(t, []string{"http://ex.org/name"}, rule.Consequent)
+		}
+	})
+
+	t.Run("output", func(t *testing.T) {
+		result := tree.MineRules(MiningOptions{MinSupport: 2, MinConfidence: 0.9, MinLift: 1.1})
+		var csv bytes.Buffer
+		assert.NoError(t, result.WriteRulesCSV(&csv))
+		assert.Contains(t, csv.String(), "Antecedent;Consequent;Support;RelativeSupport;Confidence;Lift\n")
+		assert.Contains(t, csv.String(), `"[""t#http://ex.org/City""]";"[""http://ex.org/mayor""]";2;0.4;1;2.5`+"\n")
+
+		csv.Reset()
+		assert.NoError(t, result.WriteSetsCSV(&csv))
+		assert.Contains(t, csv.String(), `"[""http://ex.org/name""]";1;5;1`+"\n")
+
+		// items with spaces stay apart
+		values := &MiningResult{Subjects: 1, Rules: []AssociationRule{
+			{Antecedent: []string{`v#http://ex.org/name="New York"`, "http://ex.org/mayor"}, Consequent: []string{"http://ex.org/x"}}}}
+		csv.Reset()
+		assert.NoError(t, values.WriteRulesCSV(&csv))
+		assert.Contains(t, csv.String(), `"[""v#http://ex.org/name=\""New York\"""",""http://ex.org/mayor""]"`)
+
+		var json bytes.Buffer
+		assert.NoError(t, result.WriteJSON(&json))
+		assert.Contains(t, json.String(), `"frequentSets"`)
+	})
+}
@@ -15,7 +15,7 @@ type SchemaNode struct {
 	parent     *SchemaNode
 	Children   []*SchemaNode
 	nextSameID *SchemaNode // node traversal pointer
-	Support    uint32      // total frequency of the node in the path
+	Support    uint64      // total frequency of the node in the path
 }
 
 //newRootNode creates a new root node for a given propMap
@@ -107,7 +107,17 @@ func (node *SchemaNode) decodeGob(d *gob.Decoder, props []*IItem) error {
 
 //incrementSupport increments the support of the schema node by one
 func (node *SchemaNode) incrementSupport() {
-	atomic.AddUint32(&node.Support, 1)
+	atomic.AddUint64(&node.Support, 1)
+}
+
+// addSupport increments the support of the schema node by n
+func (node *SchemaNode) addSupport(n uint64) {
+	atomic.AddUint64(&node.Support, n)
+}
+
+// decrementSupport decrements the support of the schema node by one
+func (node *SchemaNode) decrementSupport() {
+	atomic.AddUint64(&node.Support, ^uint64(0))
 }
 
 // thread-safe!
@@ -169,6 +179,55 @@ func (node *SchemaNode) getOrCreateChild(term *IItem) *SchemaNode {
 	return newChild
 }
 
+// getChild returns the child of a node associated to a IItem, or nil if there is no such child.
+// thread-safe!
+func (node *SchemaNode) getChild(term *IItem) *SchemaNode {
+	globalNodeLocks[uintptr(unsafe.Pointer(node))%lockPrime].RLock()
+	defer globalNodeLocks[uintptr(unsafe.Pointer(node))%lockPrime].RUnlock()
+
+	children := node.Children
+	i := sort.Search(
+		len(children),
+		func(i int) bool {
+			return uintptr(unsafe.Pointer(children[i].ID)) >= uintptr(unsafe.Pointer(term))
+		})
+	if i < len(children) && children[i].ID == term {
+		return children[i]
+	}
+	return nil
+}
+
+// detach removes the node (including its descendants) from the children of its parent. The nodes stay in
+// the traversal lists of their items until they are unlinked or the lists are relinked.
+// The tree must not be modified concurrently.
+func (node *SchemaNode) detach() {
+	parent := node.parent
+	for i, child := range parent.Children {
+		if child == node {
+			parent.Children = append(parent.Children[:i], parent.Children[i+1:]...)
+			break
+		}
+	}
+}
+
+// unlink removes the node and its descendants from the traversal lists of their items. Every node walks
+// the list of its item, so many removals are cheaper with a single relinkTraversalLists.
+func (node *SchemaNode) unlink() {
+	for _, child := range node.Children {
+		child.unlink()
+	}
+	if node.ID.traversalPointer == node {
+		node.ID.traversalPointer = node.nextSameID
+		return
+	}
+	for cur := node.ID.traversalPointer; cur != nil; cur = cur.nextSameID {
+		if cur.nextSameID == node {
+			cur.nextSameID = node.nextSameID
+			return
+		}
+	}
+}
+
 // prefixContains checks if all properties of a given list are ancestors of a node
 // internal! propertyPath *MUST* be sorted in sortOrder (i.e. descending support)
 // thread-safe!
@@ -189,7 +248,7 @@ func (node *SchemaNode) prefixContains(propertyPath IList) bool {
 	return false
 }
 
-func (node *SchemaNode) graphViz(minSup uint32) string {
+func (node *SchemaNode) graphViz(minSup uint64) string {
 	s := ""
 	// // draw horizontal links
 	// if node.nextSameID != nil && node.nextSameID.Support >= minSup {
@@ -37,9 +37,13 @@ func TestNewRootNode(t *testing.T) {
 
 func TestIncrementSupport(t *testing.T) {
 	node := SchemaNode{testPropertyMap().get("root"), nil, []*SchemaNode{}, nil, 1}
-	assert.Equal(t, uint32(1), node.Support)
-	atomic.AddUint32(&node.Support, 1)
-	assert.Equal(t, uint32(2), node.Support)
-	atomic.AddUint32(&node.Support, 3)
-	assert.Equal(t, uint32(5), node.Support)
+	assert.Equal(t, uint64(1), node.Support)
+	atomic.AddUint64(&node.Support, 1)
+	assert.Equal(t, uint64(2), node.Support)
+	atomic.AddUint64(&node.Support, 3)
+	assert.Equal(t, uint64(5), node.Support)
+
+	// supports are not limited to 32 bits
+	node.addSupport(1 << 32)
+	assert.Equal(t, uint64(1<<32+5), node.Support)
 }
@@ -1,31 +1,41 @@
 package schematree
 
 import (
-	"encoding/gob"
+	"bufio"
 	"fmt"
 	"log"
 	"os"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
-
-	gzip "github.com/klauspost/pgzip"
 )
 
 // TypedSchemaTree is a schematree that includes type information as property nodes
 type SchemaTree struct {
 	PropMap propMap    // PropMap maps the string representations of properties to the corresponding IItem
 	Root    SchemaNode // Root is the root node of the schematree. All further nodes are descendants of this node.
-	MinSup  uint32     // TODO (not used)
+	MinSup  uint64     // MinSup is the minimum support of the nodes, infrequent branches are pruned
 	Typed   bool       // Typed indicates if this schematree includes type information as properties
+	Meta    Metadata   // Meta describes how the schematree has been built
+
+	flat    *flatTree        // flat holds the nodes of compact and memory-mapped trees, Root has no children then
+	builder *builderPool     // builder holds the nodes while a compact tree is constructed
+	objects *objectCollector // objects collects the ObjectStats between the passes of TwoPass
+
+	properties atomic.Value // properties caches the propertyList of the estimators
 }
 
 // Create creates a new schema tree from given dataset with given first n subjects, typed and minSup
-func Create(filename string, firstNsubjects uint64, typed bool, minSup uint32) (*SchemaTree, error) {
+func Create(filename string, firstNsubjects uint64, typed bool, minSup uint64) (*SchemaTree, error) {
 
 	schema := New(typed, minSup)
-	schema.TwoPass(filename, uint64(firstNsubjects))
+	if ConstructionShards > 0 {
+		schema.SinglePass(filename, uint64(firstNsubjects), ConstructionShards)
+	} else {
+		schema.TwoPass(filename, uint64(firstNsubjects))
+	}
 	var err error
 	if typed {
 		err = schema.Save(filename + ".schemaTree.typed.bin")
@@ -37,7 +47,7 @@ func Create(filename string, firstNsubjects uint64, typed bool, minSup uint32) (
 }
 
 // New returns a newly allocated and initialized schema tree
-func New(typed bool, minSup uint32) (tree *SchemaTree) {
+func New(typed bool, minSup uint64) (tree *SchemaTree) {
 	if minSup < 1 {
 		minSup = 1
 	}
@@ -48,6 +58,7 @@ func New(typed bool, minSup uint32) (tree *SchemaTree) {
 		Root:    newRootNode(pMap),
 		MinSup:  minSup,
 		Typed:   typed,
+		Meta:    Metadata{Config: DefaultBuildConfig},
 	}
 	tree.init()
 	return
@@ -63,8 +74,9 @@ func (tree *SchemaTree) init() {
 
 // Insert inserts all properties of a new subject into the schematree
 // The subject is given by
+// Compact trees only accept insertions while they are constructed by TwoPass, afterwards ErrReadOnly is returned.
 // thread-safe
-func (tree *SchemaTree) Insert(e *SubjectSummary) {
+func (tree *SchemaTree) Insert(e *SubjectSummary) error {
 
 	// transform into iList of properties
 	properties := make(IList, len(e.Properties), len(e.Properties))
@@ -77,6 +89,15 @@ func (tree *SchemaTree) Insert(e *SubjectSummary) {
 	// sort the properties descending by support
 	properties.Sort()
 
+	if tree.flat != nil {
+		return ErrReadOnly
+	}
+	if tree.builder != nil {
+		tree.Root.incrementSupport()
+		tree.builder.insert(properties, 1)
+		return nil
+	}
+
 	// insert sorted item list into the schemaTree
 	node := &tree.Root
 	node.incrementSupport()
@@ -84,7 +105,7 @@ func (tree *SchemaTree) Insert(e *SubjectSummary) {
 		node = node.getOrCreateChild(prop) // recurse, i.e., node.getOrCreateChild(prop).insert(properties[1:], types)
 		node.incrementSupport()
 	}
-
+	return nil
 }
 
 // updateSortOrder updates iList according to actual frequencies
@@ -119,8 +140,8 @@ func (tree *SchemaTree) updateSortOrder() {
 }
 
 // Support returns the total cooccurrence-frequency of the given property list
-func (tree *SchemaTree) Support(properties IList) uint32 {
-	var support uint32
+func (tree *SchemaTree) Support(properties IList) uint64 {
+	var support uint64
 
 	if len(properties) == 0 {
 		return tree.Root.Support // empty set occured in all transactions
@@ -128,6 +149,10 @@ func (tree *SchemaTree) Support(properties IList) uint32 {
 
 	properties.Sort() // descending by support
 
+	if tree.flat != nil {
+		return tree.flat.support(properties)
+	}
+
 	// check all branches that include least frequent term
 	for term := properties[len(properties)-1].traversalPointer; term != nil; term = term.nextSameID {
 		if term.prefixContains(properties) {
@@ -149,40 +174,12 @@ func (tree *SchemaTree) Save(filePath string) error {
 	}
 	defer f.Close()
 
-	w := gzip.NewWriter(f)
-	defer w.Close()
-
-	e := gob.NewEncoder(w)
-
-	// encode propMap
-	props := make([]*IItem, len(tree.PropMap), len(tree.PropMap))
-	for _, p := range tree.PropMap {
-		props[int(p.SortOrder)] = p
-	}
-	err = e.Encode(props)
-	if err != nil {
-		return err
-	}
-
-	// encode MinSup
-
-	err = e.Encode(tree.MinSup)
-	if err != nil {
-		return err
-	}
-
-	// encode root
-	err = tree.Root.writeGob(e)
-
-	// encode Typed
-	if tree.Typed {
-		err = e.Encode(1)
-	} else {
-		err = e.Encode(2)
-	}
-	err = e.Encode(tree.Typed)
-	if err != nil {
-		return err
+	meta := tree.Meta
+	meta.Typed = tree.Typed
+	meta.TypePredicates = tree.Config().TypePredicates // for readers that do not know the configuration
+	err = writeContainer(f, meta, tree.writePayload)
+	if err == nil {
+		err = f.Close()
 	}
 
 	if err == nil {
@@ -194,7 +191,10 @@ func (tree *SchemaTree) Save(filePath string) error {
 	return err
 }
 
-// Load loads a binarized SchemaTree from disk
+// Load loads a binarized SchemaTree from disk. Both the current container format and the legacy
+// format without header are accepted. Corrupted files result in an error wrapping ErrCorrupted.
+// Files in the flat layout written by SaveFlat are memory-mapped, see LoadMapped. Other files are
+// converted into the compact layout after decoding if CompactLayout is set.
 func Load(filePath string) (*SchemaTree, error) {
 	// Alternatively via GobDecoder(...): https://stackoverflow.com/a/12854659
 
@@ -207,80 +207,56 @@ func Load(filePath string) (*SchemaTree, error) {
 		fmt.Printf("Encountered error while trying to open the file: %v\n", err)
 		return nil, err
 	}
-
-	r, err := gzip.NewReader(f)
-	if err != nil {
-		fmt.Printf("Encountered error while trying to decompress the file: %v\n", err)
-		return nil, err
-	}
-	defer r.Close()
+	defer f.Close()
+	r := bufio.NewReaderSize(f, 4*1024*1024)
 
 	/// decoding
-	tree := New(false, 1)
-	d := gob.NewDecoder(r)
-
-	// decode propMap
-	var props []*IItem
-	err = d.Decode(&props)
-	if err != nil {
-		return nil, err
-	}
-	for sortOrder, item := range props {
-		item.SortOrder = uint32(sortOrder)
-		tree.PropMap[*item.Str] = item
-	}
-	fmt.Printf("%v properties... ", len(props))
-
-	// decode MinSup
-	err = d.Decode(&tree.MinSup)
-	if err != nil {
-		return nil, err
-	}
-
-	// decode Root
-	fmt.Printf("decoding tree...")
-	err = tree.Root.decodeGob(d, props)
-
-	// legacy import bug workaround
-	if *tree.Root.ID.Str != "root" {
-		fmt.Println("WARNING!!! Encountered legacy root node import bug - root node counts will be incorrect!")
-		tree.Root.ID = tree.PropMap.get("root")
-	}
-
-	//decode Typed
-	var i int
-	err = d.Decode(&i)
-	if i == 1 {
-		tree.Typed = true
-	}
-	if err != nil {
-		return nil, err
+	var tree *SchemaTree
+	if isFlat(r) {
+		fmt.Printf("memory-mapping... ")
+		f.Close()
+		tree, err = mapFlat(filePath)
+	} else if isContainer(r) {
+		tree, err = loadContainer(r)
+	} else {
+		fmt.Printf("legacy format... ")
+		tree, err = loadLegacy(r)
 	}
-
 	if err != nil {
 		fmt.Printf("Encountered error while decoding the file: %v\n", err)
 		return nil, err
 	}
 
 	fmt.Println(time.Since(t1))
+	if CompactLayout {
+		tree.Compact()
+	}
 	return tree, err
 }
 
 // first pass: collect I-List and statistics
 func (tree *SchemaTree) firstPass(fileName string, firstN uint64) {
 	//	if _, err := os.Stat(fileName + ".firstPass.bin"); os.IsNotExist(err) {
+	cardinalities := &cardinalityCollector{}
 	counter := func(s *SubjectSummary) {
 		for prop := range s.Properties {
 			prop.increment()
 		}
+		cardinalities.add(s)
 	}
 
 	t1 := time.Now()
-	subjectCount := SubjectSummaryReader(fileName, tree.PropMap, counter, firstN, tree.Typed)
+	tree.objects = newObjectCollector()
+	subjectCount := readSubjectSummaries(fileName, tree.PropMap, tree.Config(), counter, firstN, tree.Typed, tree.objects)
 	propCount, typeCount := tree.PropMap.count()
 
 	fmt.Printf("%v subjects, %v properties, %v types\n", subjectCount, propCount, typeCount)
 
+	tree.Meta.Dataset = fileName
+	tree.Meta.Subjects = subjectCount
+	tree.Meta.TypePredicates = tree.Config().TypePredicates
+	tree.Meta.Cardinalities = cardinalities.cardinalities()
+
 	// f, _ := os.Create(fileName + ".propMap")
 	// gob.NewEncoder(f).Encode(schema.propMap)
 	// f.Close()
@@ -293,13 +269,6 @@ func (tree *SchemaTree) firstPass(fileName string, firstN uint64) {
 	fmt.Println("First Pass:", time.Since(t1))
 	PrintMemUsage()
 
-	const MaxUint32 = uint64(^uint32(0))
-	if subjectCount > MaxUint32 {
-		fmt.Print("\n#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#\n\n")
-		fmt.Printf("WARNING: uint32 OVERFLOW - Processed %v subjects but tree can only track support up to %v!\n", subjectCount, MaxUint32)
-		fmt.Print("\n#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#!#\n\n")
-	}
-
 	// Disabled saving the firstPass.bin for now, because using it between untyped and typed
 	// trees can possibly lead to unexpected errors.
 	//
@@ -335,22 +304,44 @@ func (tree *SchemaTree) firstPass(fileName string, firstN uint64) {
 // build schema tree
 func (tree *SchemaTree) secondPass(fileName string, firstN uint64) {
 	tree.updateSortOrder() // duplicate -- legacy compatability
+	if CompactLayout {
+		tree.builder = newBuilderPool(0, tree.Root.ID)
+	}
 
 	inserter := func(s *SubjectSummary) {
+		// properties below the minimum support cannot be part of any node that survives the pruning,
+		// so they are left out of the transactions right away
+		if tree.MinSup > 1 {
+			for p := range s.Properties {
+				if p.TotalCount < tree.MinSup {
+					delete(s.Properties, p)
+				}
+			}
+		}
 		tree.Insert(s)
 	}
 
 	// go countTreeNodes(schema)
 
 	t1 := time.Now()
-	SubjectSummaryReader(fileName, tree.PropMap, inserter, firstN, tree.Typed)
+	if tree.objects != nil {
+		tree.objects.startTypePass()
+	}
+	readSubjectSummaries(fileName, tree.PropMap, tree.Config(), inserter, firstN, tree.Typed, tree.objects)
+	tree.finishCompact()
+	if tree.objects != nil {
+		tree.Meta.ObjectStats = tree.objects.stats()
+		tree.objects = nil
+	}
 
 	fmt.Println("Second Pass:", time.Since(t1))
 	PrintMemUsage()
 	// PrintLockStats()
 }
 
-// TwoPass constructs a SchemaTree from the firstN subjects of the given NTriples file using a two-pass approach
+// TwoPass constructs a SchemaTree from the firstN subjects of the given NTriples file using a two-pass approach.
+// With a MinSup above one, infrequent properties are left out of the transactions and nodes below MinSup are
+// pruned afterwards.
 func (tree *SchemaTree) TwoPass(fileName string, firstN uint64) {
 	// go func() {
 	// 	for true {
@@ -360,6 +351,10 @@ func (tree *SchemaTree) TwoPass(fileName string, firstN uint64) {
 	// }()
 	tree.firstPass(fileName, firstN)
 	tree.secondPass(fileName, firstN)
+	if tree.MinSup > 1 {
+		tree.Prune(tree.MinSup)
+	}
+	tree.Meta.BuildTime = time.Now()
 }
 
 // WritePropFreqs writes all Properties together with their Support to the given File as CSV
@@ -396,7 +391,7 @@ func (tree SchemaTree) WriteTypeFreqs(file string) {
 
 // String returns the string represantation of the schema tree
 func (tree SchemaTree) String() string {
-	var minSupport uint32 = 100000
+	var minSupport uint64 = 100000
 	s := "digraph schematree { newrank=true; labelloc=b; color=blue; fontcolor=blue; style=dotted;\n"
 
 	s += tree.Root.graphViz(minSupport)
@@ -1,6 +1,11 @@
 package schematree
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	rio "recommender/io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -116,3 +121,52 @@ func TestUpdateSortOrder(t *testing.T) {
 	assert.EqualValues(t, tree.PropMap.get("p4").SortOrder, 3)
 
 }
+
+func TestUnsortedInput(t *testing.T) {
+	unsorted := filepath.Join(t.TempDir(), "unsorted.nt")
+	lines := strings.Join([]string{
+		`<http://ex.org/a> <http://ex.org/p> "1" .`,
+		`<http://ex.org/b> <http://ex.org/p> "2" .`,
+		`<http://ex.org/a> <http://ex.org/q> "3" .`,
+		`<http://ex.org/c> <http://ex.org/q> "4" .`,
+	}, "\n")
+	assert.NoError(t, os.WriteFile(unsorted, []byte(lines), 0644))
+
+	t.Run("detector", func(t *testing.T) {
+		f := newSubjectFilter()
+		assert.False(t, f.testAndAdd([]byte("<http://ex.org/a>")))
+		assert.False(t, f.testAndAdd([]byte("<http://ex.org/b>")))
+		assert.True(t, f.testAndAdd([]byte("<http://ex.org/a>")))
+
+		// the filter grows instead of filling up, so false reports stay rare
+		f = newSubjectFilter()
+		var reported uint64
+		for i := 0; i < 2000000; i++ {
+			if f.testAndAdd([]byte(fmt.Sprintf("<http://ex.org/s%v>", i))) {
+				reported++
+			}
+		}
+		assert.Greater(t, len(f.stages), 1)
+		assert.LessOrEqual(t, reported, 2*f.expectedFalseReports())
+		assert.True(t, f.testAndAdd([]byte("<http://ex.org/s0>")))
+		assert.True(t, f.testAndAdd([]byte("<http://ex.org/s1999999>")))
+	})
+
+	t.Run("contiguous mode splits subjects", func(t *testing.T) {
+		tree := New(false, 1)
+		count := SubjectSummaryReader(unsorted, tree.PropMap, nil, func(s *SubjectSummary) {}, 0, false)
+		assert.EqualValues(t, 4, count)
+	})
+
+	t.Run("grouping", func(t *testing.T) {
+		rio.GroupUnsorted = true
+		defer func() { rio.GroupUnsorted = false }()
+		defer rio.RemoveGroupedFiles()
+
+		tree := New(false, 1)
+		tree.TwoPass(unsorted, 0)
+		assert.EqualValues(t, 3, tree.Root.Support)
+		assert.EqualValues(t, 2, tree.PropMap["http://ex.org/p"].TotalCount)
+		assert.EqualValues(t, 2, tree.PropMap["http://ex.org/q"].TotalCount)
+	})
+}
@@ -7,26 +7,14 @@ I/O and RDF Parsing
 package schematree
 
 import (
-	"bufio"
-	"compress/bzip2"
-	"errors"
+	"bytes"
 	"fmt"
-	"io"
-	"io/ioutil"
+	"hash/fnv"
 	"log"
-	"os"
-	"path/filepath"
+	"math"
 	rio "recommender/io"
 	"runtime"
-	"strings"
 	"sync"
-	"time"
-	"unicode/utf8"
-
-	gzip "github.com/klauspost/pgzip"
-
-	"github.com/biogo/hts/bgzf"
-	pb "gopkg.in/cheggaaa/pb.v1"
 )
 
 // All type annotations (types) and properties (properties) for a fixed subject
@@ -46,26 +34,75 @@ func (subj *SubjectSummary) String() string {
 	return fmt.Sprintf("{\n  types:      [ %v ]\n  properties: [ %v ]\n}", 0, len(subj.Properties)) //TODO count types
 }
 
-// SubjectSummaryReader reads a RDF Dataset from disk (in N-Triples format) which is expected to be
-// grouped by subjects. For each subject group, the method will build a SubjectSummary structure and
+// SubjectSummaryReader reads a RDF Dataset from disk which is expected to be grouped by subjects. For each subject group, the method will build a SubjectSummary structure and
 // send it to a handler function.
 // It will always detect types, but may choose to ignore them.
 //
-// todo: The parsing is done a subset of N-Triple format files. If subjects, predicates or objects contain
-//       any spaces, even if inside quotes, it will break.
+// The file is parsed with the reader of the io module that matches rio.DefaultFormat or the file extension.
+// Malformed input is skipped with a warning, or terminates the program if the parser runs in strict mode.
+//
+// Subjects that reappear after other subjects have been read are reported, since they would be counted
+// as several transactions. Unsorted files can be read by setting rio.GroupUnsorted.
 func SubjectSummaryReader(
 	fileName string, // path to the file that should be parsed
 	pMap propMap, // maps of properties that the schematree recognizes
+	config *BuildConfig, // type predicates and predicate filters to apply, the defaults if nil
 	handler func(s *SubjectSummary), // handler function that gets executed after a SubjectSummary is completed
 	firstN uint64, // stop after N subjects are read; setting this to zero will read all entries
 	willConvertTypes bool, // true if the reader should convert identified type entries into TypeProperties.
+) (subjectCount uint64) {
+	return readSubjectSummaries(fileName, pMap, config, handler, firstN, willConvertTypes, nil)
+}
+
+// readKnownSubjects reads the first firstN subjects of a dataset (all subjects if firstN is zero) with a property
+// map of its own, so the map of the tree is not extended. handle gets every subject with the items that the tree
+// knows and the strings of the unknown ones, concurrently and in no particular order. The Properties of the
+// summary are translated to the items of the tree, i.e. they only hold the known items with their counts.
+func (tree *SchemaTree) readKnownSubjects(fileName string, firstN uint64, handle func(s *SubjectSummary, known IList, unknown []string)) {
+	translate := func(s *SubjectSummary) {
+		known := make(IList, 0, len(s.Properties))
+		properties := make(map[*IItem]uint32, len(s.Properties))
+		var unknown []string
+		for p, count := range s.Properties {
+			if item, ok := tree.PropMap[*p.Str]; ok {
+				known = append(known, item)
+				properties[item] = count
+			} else {
+				unknown = append(unknown, *p.Str)
+			}
+		}
+		s.Properties = properties
+		handle(s, known, unknown)
+	}
+	SubjectSummaryReader(fileName, make(propMap), tree.Config(), translate, firstN, tree.Typed)
+}
+
+// readSubjectSummaries is SubjectSummaryReader, which additionally passes the objects of the properties to a
+// collector of their statistics if one is given.
+func readSubjectSummaries(
+	fileName string,
+	pMap propMap,
+	config *BuildConfig,
+	handler func(s *SubjectSummary),
+	firstN uint64,
+	willConvertTypes bool,
+	objects *objectCollector,
 ) (subjectCount uint64) {
 	// IO setup
-	reader, err := rio.UniversalReader(fileName)
+	if config == nil {
+		config = DefaultBuildConfig
+	}
+	var tParser rio.TripleReader
+	var err error
+	if config.InverseProperties {
+		tParser, err = rio.OpenTripleReaderWithInverses(fileName)
+	} else {
+		tParser, err = rio.OpenTripleReader(fileName)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer reader.Close()
+	defer tParser.Close()
 
 	// set up concurrent handler routines
 	concurrency := runtime.NumCPU() // * 4    (should be fine with NumCPU since thats num of logical cpus and has no IO operation)
@@ -82,64 +119,76 @@ func SubjectSummaryReader(
 	}
 
 	// parse file
-	var isPrefix, skip bool
-	var line, token []byte
-	var lastSubj string
-	var bytesProcessed int
-	scanner := bufio.NewReaderSize(reader, 4*1024*1024) // 4MB line Buffer
+	var trip *rio.Triple
+	var lastSubj []byte
 	var summary *SubjectSummary
-	//summary := &SubjectSummary{Properties: make(map[*IItem]uint32)}
-	typeProps := []*IItem{
-		pMap.get("http://www.wikidata.org/prop/direct/P31"),
-		pMap.get("http://www.w3.org/1999/02/22-rdf-syntax-ns#type"),
-		pMap.get("http://dbpedia.org/ontology/type"),
+	var seen *subjectFilter
+	if !rio.GroupUnsorted && !config.InverseProperties {
+		seen = newSubjectFilter()
 	}
+	classes := newClassifier(config, pMap)
+	classes.objects = objects
 
-	for line, isPrefix, err = scanner.ReadLine(); err == nil; line, isPrefix, err = scanner.ReadLine() {
-		if isPrefix {
-			fmt.Printf("Line Buffer too small!!! Line prefix: %v\n", string(line[:200]))
-			skip = true
-			continue
-		}
-		if skip { // !isPrefix follows implicitly
-			skip = false
-			continue
-		}
-
-		// process subject
-		bytesProcessed, token = firstWord(line)
-
-		// if r, _ := utf8.DecodeRune(token); r == '#' { // line is a comment
-		if len(token) == 0 || token[0] == '#' { // line is a comment
-			continue
-		}
+	for trip, err = tParser.NextTriple(); trip != nil && err == nil; trip, err = tParser.NextTriple() {
 
 		// If this a new subject, emit the previous predicate set and start clean
-		if lastSubj != string(token) { // should only be allocated on stack - c.f. https://github.com/golang/go/issues/11777
-			if lastSubj != "" {
+		if !bytes.Equal(lastSubj, trip.Subject.Raw) {
+			if summary != nil {
 				summaries <- summary
 				if subjectCount++; firstN > 0 && subjectCount >= firstN {
 					break
 				}
 			}
 
-			lastSubj = string(token) // allocate string (on heap)
-			summary = &SubjectSummary{Properties: make(map[*IItem]uint32), Str: lastSubj}
+			lastSubj = trip.Subject.Raw // the triple owns its line, so no copy is needed
+			if seen != nil && seen.testAndAdd(lastSubj) {
+				seen.reappeared++
+				if seen.reappeared <= maxReappearanceWarnings {
+					log.Printf("WARNING: subject %s reappears after other subjects, the input is probably not grouped by subject\n", lastSubj)
+				}
+			}
+			summary = &SubjectSummary{Properties: make(map[*IItem]uint32), Str: trip.Subject.Identifier()}
 		}
 
-		// process predicate
-		line = line[bytesProcessed:]
-		bytesProcessed, token = firstWord(line)
+		summary.addTriple(trip, classes, willConvertTypes)
+	}
 
-		// c.f. https://www.mediawiki.org/wiki/Wikibase/Indexing/RDF_Dump_Format#Prefixes_used
-		if strings.HasPrefix(string(token), "http://www.wikidata.org/prop/") &&
-			!strings.HasPrefix(string(token), "http://www.wikidata.org/prop/direct/") {
-			continue
-		}
+	// dispatch last summary
+	if summary != nil && len(summary.Properties) > 0 && (firstN == 0 || subjectCount < firstN) {
+		summaries <- summary
+		subjectCount++
+	}
 
-		predicate := pMap.get(string(token))
+	if err != nil {
+		log.Fatalf("Parser encountered error while trying to parse triples: %v\n", err)
+	}
+	close(summaries)
+	wg.Wait()
 
-		summary.Properties[predicate]++
+	if seen != nil && seen.reappeared > 0 {
+		log.Printf("WARNING: %v subjects were not contiguous and have been split into several transactions"+
+			" (up to about %v of these reports may be false). Sort the input by subject or enable the grouping of"+
+			" unsorted input.\n", seen.reappeared, seen.expectedFalseReports())
+	}
+
+	return
+}
+
+// addTriple records the predicate of a triple in the summary of its subject, unless it is excluded by the
+// build configuration. Type annotations are counted and, if willConvertTypes is set, their objects are added
+// as type properties. Inverse triples add the inverse item of their predicate, triples of value predicates
+// add their property=value item.
+func (subj *SubjectSummary) addTriple(trip *rio.Triple, classes *classifier, willConvertTypes bool) {
+	if trip.Inverse {
+		if class := classes.classifyInverse(trip.Predicate.Value); class.item != nil {
+			subj.Properties[class.item]++
+		}
+		return
+	}
+	class := classes.classify(trip.Predicate.Value)
+
+	if class.item != nil {
+		subj.Properties[class.item]++
 
 		// Count the number of predicates found for that subject. Unfortunately
 		// it is NOT the number of unique predicates. Having multiple equal
@@ -148,169 +197,128 @@ func SubjectSummaryReader(
 		// storing them into the treeMap.
 		// This might make some impact because the TypeProp is usually used multiple
 		// times, one for each type the subject has.
-		summary.NumPredicates++
-
-		// Detect type properties to add them to the counters.
-		for _, typeProp := range typeProps {
-			if predicate == typeProp {
-				summary.NumTypePredicates++
-
-				// If set to convert types, then read the object to generate a type property from it.
-				if willConvertTypes {
-					line = line[bytesProcessed:]
-					bytesProcessed, token = firstWord(line)
-					tokenStr := "t#" + string(token) // prefix t# identifies properties that represent types
-					pType := pMap.get(tokenStr)
-					summary.Properties[pType]++
-				}
-				break
-			}
-		}
+		subj.NumPredicates++
 	}
 
-	// dispatch last summary
-	if len(summary.Properties) > 0 {
-		summaries <- summary
+	if class.isValue {
+		subj.Properties[classes.pMap.get(valueItem(string(trip.Predicate.Value), trip.Object.Identifier()))]++
 	}
 
-	if err != nil && err != io.EOF {
-		log.Fatalf("Scanner encountered error while trying to parse triples: %v\n", err)
+	isTypeObject := class.isTypeObject(&trip.Object)
+	if classes.objects != nil {
+		classes.objects.observe(trip, class, isTypeObject)
 	}
-	close(summaries)
-	wg.Wait()
 
-	return
-}
+	// Detect type annotations to add them to the counters.
+	if isTypeObject {
+		subj.NumTypePredicates++
 
-// Adapted from 'ScanWords' in https://golang.org/src/bufio/scan.go
-//
-// firstWord returns the first space-separated word of text, with
-// surrounding spaces & angle brackets deleted.
-func firstWord(data []byte) (advance int, token []byte) {
-	// Skip leading spaces.
-	start := 0
-	for width := 0; start < len(data); start += width {
-		if data[start] < utf8.RuneSelf {
-			if !isSpaceOrBracket(rune(data[start])) {
-				break
-			}
-			width = 1
-		} else {
-			var r rune
-			r, width = utf8.DecodeRune(data[start:])
-			if !isSpaceOrBracket(r) {
-				break
-			}
+		// If set to convert types, then read the object to generate a type property from it.
+		if willConvertTypes {
+			tokenStr := typePrefix + trip.Object.Identifier() // prefix t# identifies properties that represent types
+			pType := classes.pMap.get(tokenStr)
+			subj.Properties[pType]++
 		}
 	}
-	// Scan until space, marking end of word.
-	for width, i := 0, start; i < len(data); i += width {
-		// Fast path 1: ASCII.
-		if data[i] < utf8.RuneSelf {
-			if isSpaceOrBracket(rune(data[i])) {
-				return i + 1, data[start:i]
-			}
-			width = 1
-		} else {
-			var r rune
-			r, width = utf8.DecodeRune(data[i:])
-			if isSpaceOrBracket(r) {
-				return i + width, data[start:i]
-			}
-		}
-	}
-	// If we're at EOL, we have a final, non-empty, non-terminated word. Return it.
-	if len(data) > start {
-		return len(data), data[start:]
-	}
-	// Request more data.
-	return start, nil
 }
 
-// Adapted from 'isSpace' in https://golang.org/src/bufio/scan.go
-//
-// isSpace reports whether the character is a Unicode white space character .
-func isSpaceOrBracket(r rune) bool {
-	if r <= '\u00FF' {
-		// Obvious ASCII ones: \t through \r plus space. Plus two Latin-1 oddballs.
-		switch r {
-		case ' ', '\t', '\n', '\v', '\f', '\r', '<', '>':
-			return true
-		case '\u0085', '\u00A0':
+// maxReappearanceWarnings limits how many non-contiguous subjects are reported individually.
+const maxReappearanceWarnings = 10
+
+// subjectFilter is a scalable Bloom filter over the subjects that have been read so far. It allows to detect
+// non-contiguous subjects with little memory: when a stage is full, a stage of twice the size with a lower
+// error rate is added, so that at most about subjectFilterErrorRate of the new subjects are falsely reported
+// as reappearing, however large the input is. It needs four to seven bytes per subject.
+type subjectFilter struct {
+	stages     []*filterStage
+	added      uint64 // subjects that have been added
+	reappeared uint64
+}
+
+// filterStage is a Bloom filter with a fixed number of bits, a power of two, that holds up to capacity subjects.
+type filterStage struct {
+	bits     []uint64
+	hashes   uint64
+	capacity uint64
+	added    uint64
+}
+
+const (
+	subjectFilterErrorRate = 1e-5    // upper bound of the share of false reports among all new subjects
+	subjectFilterFirstBits = 1 << 23 // 1 MiB
+)
+
+func newSubjectFilter() *subjectFilter {
+	f := &subjectFilter{}
+	f.addStage()
+	return f
+}
+
+// addStage adds a stage that is twice as large as the last one. The error rates of the stages halve, so that
+// they sum up to less than subjectFilterErrorRate.
+func (f *subjectFilter) addStage() {
+	size := uint64(subjectFilterFirstBits) << uint(len(f.stages))
+	errorRate := subjectFilterErrorRate / 2 / float64(uint64(1)<<uint(len(f.stages)))
+	f.stages = append(f.stages, &filterStage{
+		bits:     make([]uint64, size/64),
+		hashes:   uint64(math.Ceil(math.Log2(1 / errorRate))),
+		capacity: uint64(float64(size) * math.Ln2 * math.Ln2 / math.Log(1/errorRate)),
+	})
+}
+
+// expectedFalseReports estimates how many of the added subjects have been reported although they were new.
+func (f *subjectFilter) expectedFalseReports() uint64 {
+	return uint64(math.Ceil(float64(f.added) * subjectFilterErrorRate))
+}
+
+// testAndAdd adds the subject to the filter and returns true if it was probably added before.
+func (f *subjectFilter) testAndAdd(subject []byte) bool {
+	h := fnv.New64a()
+	h.Write(subject)
+	sum := h.Sum64()
+	h1, h2 := mix64(sum), mix64(sum^0x9e3779b97f4a7c15)|1 // FNV alone spreads the last bytes badly
+
+	for _, stage := range f.stages {
+		if stage.contains(h1, h2) {
 			return true
 		}
-		return false
-	}
-	// High-valued ones.
-	if '\u2000' <= r && r <= '\u200a' {
-		return true
 	}
-	switch r {
-	case '\u1680', '\u2028', '\u2029', '\u202f', '\u205f', '\u3000':
-		return true
+	last := f.stages[len(f.stages)-1]
+	if last.added >= last.capacity {
+		f.addStage()
+		last = f.stages[len(f.stages)-1]
 	}
+	last.add(h1, h2)
+	f.added++
 	return false
 }
 
-// UniversalReader opens a file for read mode. It is able to automatically decompress
-// files that use either GZ, BGZ or BZ2. It will also display a loading bar on stdout.
-func UniversalReader(fileName string) (reader io.ReadCloser, err error) {
-	if stat, _ := os.Stdin.Stat(); (stat.Mode() & os.ModeCharDevice) == 0 {
-		fmt.Println("Reading data from stdin")
-		reader = os.Stdin
-	} else {
-		var file *os.File
-		file, err = os.Open(fileName)
-		if err != nil {
-			return
-		}
-
-		var stat os.FileInfo
-		stat, err = file.Stat()
-		if err != nil {
-			return
-		}
-		if stat.IsDir() {
-			err = errors.New("Reading entire directories is not yet possible")
-			return
-		}
-		fmt.Printf("Reading data from file '%v'. Progress w.r.t. on-disk-size: \n", fileName)
-
-		// create and start progress bar
-		bar := pb.New(int(stat.Size())).SetUnits(pb.U_BYTES).SetRefreshRate(500 * time.Millisecond).Start()
-		bar.ShowElapsedTime = true
-		bar.ShowSpeed = true
-		reader = bar.NewProxyReader(file)
-
-		// decompress stream if applicable
-		switch ext := filepath.Ext(fileName); ext {
-		case ".bz2":
-			reader = ioutil.NopCloser(bzip2.NewReader(reader)) // Decompression
-		case ".gz":
-			reader, err = gzip.NewReaderN(reader, 8*1024*1024, 48) // readahead
-		case ".bgz":
-			reader, err = bgzf.NewReader(reader, 0)
-			//case ".zst", ".zstd":
-			//reader = releaseCloser{gozstd.NewReader(reader)}
-		}
+// mix64 is the finalizer of MurmurHash3, which makes every bit of the result depend on all bits of x.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
 
-		if err != nil {
-			return
+func (s *filterStage) contains(h1, h2 uint64) bool {
+	mask := uint64(len(s.bits))*64 - 1
+	for i := uint64(0); i < s.hashes; i++ {
+		bit := (h1 + i*h2) & mask
+		if s.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
 		}
-
-		reader = finishCloser{reader, bar, file}
 	}
-	return
+	return true
 }
 
-type finishCloser struct {
-	io.ReadCloser
-	bar  *pb.ProgressBar
-	file *os.File
-}
-
-func (r finishCloser) Close() error {
-	r.bar.Finish()
-	defer r.file.Close()
-	return r.ReadCloser.Close()
+func (s *filterStage) add(h1, h2 uint64) {
+	mask := uint64(len(s.bits))*64 - 1
+	for i := uint64(0); i < s.hashes; i++ {
+		bit := (h1 + i*h2) & mask
+		s.bits[bit/64] |= 1 << (bit % 64)
+	}
+	s.added++
 }
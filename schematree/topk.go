@@ -0,0 +1,200 @@
+package schematree
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// RecommendTopK recommends the k most likely property candidates by given IItems, with the same probabilities
+// as the first k recommendations of RecommendProperty (equally likely candidates may be swapped). All of them
+// are returned if k is not positive.
+//
+// Instead of walking the whole subtree below every node of the input set, the subtrees are walked in rounds,
+// each going down to a deeper sort order. The items of a tree path are sorted, so after a round the supports
+// of all items up to that sort order are complete. An item further down can at most have the summed support
+// of the unwalked subtree roots before it and at most its TotalCount. Once the k-th best complete support
+// reaches that bound, the remaining subtrees can not change the top k and are skipped.
+func (tree *SchemaTree) RecommendTopK(properties IList, k int) PropertyRecommendations {
+	if k <= 0 || len(properties) == 0 {
+		ranked := tree.RecommendProperty(properties)
+		if k > 0 && len(ranked) > k {
+			ranked = ranked[:k]
+		}
+		return ranked
+	}
+	properties.Sort() // descending by support
+	pSet := properties.toSet()
+	accept := func(item *IItem) bool { return !pSet[item] && item.IsProp() }
+
+	// the items above the nodes of the set are counted completely, the children of the nodes are the first roots
+	items := tree.itemsBySortOrder()
+	counts := make([]uint64, len(items)) // by sort order
+	var setSupport uint64
+	var roots []topKNode
+	for _, node := range tree.setNodes(properties) {
+		setSupport += node.support
+		tree.ancestorsOf(node, func(item *IItem) { counts[item.SortOrder] += node.support })
+		tree.childrenOf(node, func(child topKNode) { roots = append(roots, child) })
+	}
+
+	top := &candidateHeap{}
+	depth := properties[len(properties)-1].SortOrder
+	for step := uint32(k); ; step *= 2 {
+		if depth += step; depth < step { // overflow
+			depth = ^uint32(0)
+		}
+		var next []topKNode
+		var walk func(node topKNode)
+		walk = func(node topKNode) {
+			if node.item.SortOrder > depth {
+				next = append(next, node)
+				return
+			}
+			counts[node.item.SortOrder] += node.support
+			tree.childrenOf(node, walk)
+		}
+		for _, root := range roots {
+			walk(root)
+		}
+		roots = next
+
+		top.candidates = top.candidates[:0]
+		for sortOrder, support := range counts {
+			if support > 0 && accept(items[sortOrder]) {
+				top.offer(RankedPropertyCandidate{items[sortOrder], float64(support) / float64(setSupport), support, setSupport}, k)
+			}
+		}
+		if len(roots) == 0 || len(top.candidates) == k && top.candidates[0].Support >= deeperBound(roots, items, depth, accept) {
+			break
+		}
+	}
+
+	ranked := PropertyRecommendations(top.candidates)
+	sort.Slice(ranked, func(i, j int) bool { return top.better(ranked[i], ranked[j]) })
+	return ranked
+}
+
+// deeperBound is the largest support that an accepted item below the given sort order can have, given the
+// subtree roots that have not been walked.
+func deeperBound(roots []topKNode, items []*IItem, depth uint32, accept func(*IItem) bool) (bound uint64) {
+	if uint64(depth)+1 >= uint64(len(items)) {
+		return 0
+	}
+	rootSupports := make([]uint64, len(items)) // by the sort order of the roots
+	for _, root := range roots {
+		rootSupports[root.item.SortOrder] += root.support
+	}
+	var below uint64 // summed support of the roots up to the sort order of the item
+	for _, item := range items[depth+1:] {
+		below += rootSupports[item.SortOrder]
+		if !accept(item) {
+			continue
+		}
+		if b := minUint64(below, item.TotalCount); b > bound {
+			bound = b
+		}
+	}
+	return
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// topKNode is a node of either layout of the tree, node is nil and index is set in compact and mapped trees.
+type topKNode struct {
+	node    *SchemaNode
+	index   uint32
+	item    *IItem
+	support uint64
+}
+
+// setNodes returns the nodes of the rarest of the properties, which have to be sorted, that have all others
+// as ancestors.
+func (tree *SchemaTree) setNodes(properties IList) (nodes []topKNode) {
+	rarest := properties[len(properties)-1]
+	if flat := tree.flat; flat != nil {
+		for _, index := range flat.instances(rarest) {
+			if flat.prefixContains(index, properties) {
+				nodes = append(nodes, topKNode{nil, index, rarest, flat.nodeSupport.get(index)})
+			}
+		}
+		return
+	}
+	for node := rarest.traversalPointer; node != nil; node = node.nextSameID {
+		if node.prefixContains(properties) {
+			nodes = append(nodes, topKNode{node, 0, rarest, node.Support})
+		}
+	}
+	return
+}
+
+// ancestorsOf calls visit with the items of the node and its ancestors, the root excluded.
+func (tree *SchemaTree) ancestorsOf(node topKNode, visit func(*IItem)) {
+	if flat := tree.flat; flat != nil {
+		for cur := node.index; cur != 0; cur = flat.parent(cur) {
+			visit(flat.items[flat.nodeItem[cur]])
+		}
+		return
+	}
+	for cur := node.node; cur.parent != nil; cur = cur.parent {
+		visit(cur.ID)
+	}
+}
+
+// childrenOf calls visit with every child of the node.
+func (tree *SchemaTree) childrenOf(node topKNode, visit func(topKNode)) {
+	if flat := tree.flat; flat != nil {
+		start, end := flat.children(node.index)
+		for child := start; child < end; child++ {
+			visit(topKNode{nil, child, flat.items[flat.nodeItem[child]], flat.nodeSupport.get(child)})
+		}
+		return
+	}
+	for _, child := range node.node.Children {
+		visit(topKNode{child, 0, child.ID, child.Support})
+	}
+}
+
+// candidateHeap is a min-heap of the best candidates, so that the worst of them is replaced first.
+type candidateHeap struct {
+	candidates []RankedPropertyCandidate
+}
+
+// better orders by support and then by sort order, so that the top k do not depend on the order of the map.
+func (h *candidateHeap) better(a, b RankedPropertyCandidate) bool {
+	if a.Support != b.Support {
+		return a.Support > b.Support
+	}
+	return a.Property.SortOrder < b.Property.SortOrder
+}
+
+// offer adds a candidate if there are less than k candidates or if it is better than the worst of them.
+func (h *candidateHeap) offer(c RankedPropertyCandidate, k int) {
+	if h.Len() < k {
+		h.candidates = append(h.candidates, c)
+		if h.Len() == k {
+			heap.Init(h)
+		}
+	} else if h.better(c, h.candidates[0]) {
+		h.candidates[0] = c
+		heap.Fix(h, 0)
+	}
+}
+
+func (h *candidateHeap) Len() int           { return len(h.candidates) }
+func (h *candidateHeap) Less(i, j int) bool { return h.better(h.candidates[j], h.candidates[i]) }
+func (h *candidateHeap) Swap(i, j int) {
+	h.candidates[i], h.candidates[j] = h.candidates[j], h.candidates[i]
+}
+func (h *candidateHeap) Push(x interface{}) {
+	h.candidates = append(h.candidates, x.(RankedPropertyCandidate))
+}
+func (h *candidateHeap) Pop() interface{} {
+	last := h.candidates[len(h.candidates)-1]
+	h.candidates = h.candidates[:len(h.candidates)-1]
+	return last
+}
@@ -0,0 +1,109 @@
+package schematree
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// topKInputs are property sets of the 10M tree of increasing rarity.
+var topKInputs = [][]string{
+	{"http://www.wikidata.org/prop/direct/P31"},
+	{"t#http://www.wikidata.org/entity/Q5"},
+	{"http://www.wikidata.org/prop/direct/P31", "http://www.wikidata.org/prop/direct/P17"},
+	{"t#http://www.wikidata.org/entity/Q515", "http://www.wikidata.org/prop/direct/P625"},
+}
+
+func TestRecommendTopK(t *testing.T) {
+	tree, err := Load(typedTreepath)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "tree.flat")
+	assert.NoError(t, tree.SaveFlat(path))
+	mapped, err := LoadMapped(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer mapped.Close()
+
+	// the top k have the probabilities of the first k recommendations, the candidates may differ on ties
+	check := func(t *testing.T, tree *SchemaTree, list IList, k int) {
+		all := tree.RecommendProperty(append(IList(nil), list...))
+		top := tree.RecommendTopK(append(IList(nil), list...), k)
+		if len(all) > k {
+			all = all[:k]
+		}
+		if !assert.Len(t, top, len(all)) {
+			return
+		}
+		full := asMap(tree.RecommendProperty(append(IList(nil), list...)))
+		for i := range top {
+			assert.Equal(t, all[i].Probability, top[i].Probability)
+			assert.Equal(t, full[*top[i].Property.Str], top[i].Probability)
+			assert.Equal(t, all[i].SetSupport, top[i].SetSupport)
+		}
+	}
+
+	for _, layout := range []struct {
+		name string
+		tree *SchemaTree
+	}{{"pointer", tree}, {"mapped", mapped}} {
+		t.Run(layout.name, func(t *testing.T) {
+			for _, input := range topKInputs {
+				list := IList{}
+				for _, iri := range input {
+					list = append(list, layout.tree.PropMap[iri])
+				}
+				for _, k := range []int{1, 10, 100, 5000} {
+					check(t, layout.tree, list, k)
+				}
+			}
+			assert.Len(t, layout.tree.RecommendTopK(IList{}, 10), 10)
+			assert.Equal(t, len(layout.tree.RecommendProperty(IList{})), len(layout.tree.RecommendTopK(IList{}, 0)))
+		})
+	}
+
+	t.Run("unseen set", func(t *testing.T) {
+		list := IList{tree.PropMap["t#http://www.wikidata.org/entity/Q5"], tree.PropMap["t#http://www.wikidata.org/entity/Q515"]}
+		assert.Empty(t, tree.RecommendTopK(list, 10))
+	})
+}
+
+func benchmarkRecommend(b *testing.B, recommend func(tree *SchemaTree, list IList) PropertyRecommendations) {
+	tree, err := Load(typedTreepath)
+	if err != nil {
+		b.Fatal(err)
+	}
+	lists := make([]IList, len(topKInputs))
+	for i, input := range topKInputs {
+		for _, iri := range input {
+			lists[i] = append(lists[i], tree.PropMap[iri])
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recommend(tree, lists[i%len(lists)])
+	}
+}
+
+func BenchmarkRecommendProperty(b *testing.B) {
+	benchmarkRecommend(b, func(tree *SchemaTree, list IList) PropertyRecommendations {
+		recs := tree.RecommendProperty(list)
+		if len(recs) > 10 {
+			recs = recs[:10]
+		}
+		return recs
+	})
+}
+
+func BenchmarkRecommendTopK10(b *testing.B) {
+	benchmarkRecommend(b, func(tree *SchemaTree, list IList) PropertyRecommendations {
+		return tree.RecommendTopK(list, 10)
+	})
+}
+
+func BenchmarkRecommendTopK500(b *testing.B) {
+	benchmarkRecommend(b, func(tree *SchemaTree, list IList) PropertyRecommendations {
+		return tree.RecommendTopK(list, 500)
+	})
+}
@@ -0,0 +1,316 @@
+package schematree
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	rio "recommender/io"
+	"time"
+)
+
+// ErrTransactionNotFound is returned when a subject should be removed whose property set is not in the tree.
+var ErrTransactionNotFound = errors.New("the property set of the subject is not contained in the schematree")
+
+// ErrInverseDelta is returned by ApplyDelta for trees with inverse properties, whose transactions also depend on
+// the triples of other subjects.
+var ErrInverseDelta = errors.New("delta files cannot be applied to schematrees with inverse properties")
+
+// Add inserts a subject into an existing schematree, including the update of the property frequencies.
+// Unlike during the construction, the sort order of the properties is not changed, so the tree stays
+// consistent. New properties are appended to the end of the sort order. Compact and memory-mapped trees
+// return ErrReadOnly and are left unchanged.
+// thread-safe
+func (tree *SchemaTree) Add(e *SubjectSummary) error {
+	if tree.flat != nil {
+		return ErrReadOnly
+	}
+	for prop := range e.Properties {
+		prop.increment()
+	}
+	return tree.Insert(e)
+}
+
+// Remove deletes a subject that has been inserted before. The supports along its path are decremented
+// and nodes whose support drops to zero are pruned. ErrTransactionNotFound is returned, and the tree is left
+// unchanged, if the property set of the subject does not end in any node of the tree.
+// NOT thread-safe
+func (tree *SchemaTree) Remove(e *SubjectSummary) error {
+	if tree.flat != nil {
+		return ErrReadOnly
+	}
+	pruned, err := tree.removePath(e)
+	if pruned != nil {
+		pruned.unlink()
+	}
+	return err
+}
+
+// removePath decrements the supports along the path of the subject and detaches the topmost node that is no
+// longer supported, which is returned. The detached nodes are left in the traversal lists.
+func (tree *SchemaTree) removePath(e *SubjectSummary) (*SchemaNode, error) {
+	properties := e.iList()
+	properties.Sort()
+
+	// find the path first, so nothing is changed if the transaction is missing
+	path := make([]*SchemaNode, 0, len(properties)+1)
+	path = append(path, &tree.Root)
+	for _, prop := range properties {
+		child := path[len(path)-1].getChild(prop)
+		if child == nil {
+			return nil, ErrTransactionNotFound
+		}
+		path = append(path, child)
+	}
+
+	// the transaction has to end at the last node, i.e. not all of its support comes from longer paths
+	last := path[len(path)-1]
+	if last.Support <= last.childSupport() {
+		return nil, ErrTransactionNotFound
+	}
+
+	for _, node := range path {
+		node.decrementSupport()
+	}
+	for _, prop := range properties {
+		if prop.TotalCount > 0 {
+			prop.TotalCount--
+		}
+	}
+
+	// prune the topmost node that is no longer supported, which takes its descendants with it
+	for _, node := range path[1:] {
+		if node.Support == 0 {
+			node.detach()
+			return node, nil
+		}
+	}
+	return nil, nil
+}
+
+// childSupport sums up the support of all children of the node.
+func (node *SchemaNode) childSupport() (sum uint64) {
+	for _, child := range node.Children {
+		sum += child.Support
+	}
+	return
+}
+
+// iList returns the properties of the subject.
+func (subj *SubjectSummary) iList() IList {
+	properties := make(IList, 0, len(subj.Properties))
+	for p := range subj.Properties {
+		properties = append(properties, p)
+	}
+	return properties
+}
+
+// SortOrderDrift measures how far the stored sort order has drifted from the current property frequencies.
+// It is the share of neighbouring properties in the sort order whose frequencies are in the wrong order.
+// Zero means that the tree is still sorted by frequency.
+func (tree *SchemaTree) SortOrderDrift() float64 {
+	ordered := make(IList, 0, len(tree.PropMap))
+	for _, item := range tree.PropMap {
+		if item != tree.Root.ID { // the root item is never counted
+			ordered = append(ordered, item)
+		}
+	}
+	ordered.Sort()
+
+	if len(ordered) < 2 {
+		return 0
+	}
+	misordered := 0
+	for i := 1; i < len(ordered); i++ {
+		if ordered[i-1].TotalCount < ordered[i].TotalCount {
+			misordered++
+		}
+	}
+	return float64(misordered) / float64(len(ordered)-1)
+}
+
+// Rebalance rebuilds the tree with a sort order that matches the current property frequencies. The
+// transactions are taken from the tree itself, so the input dataset is not needed.
+// Compact and memory-mapped trees cannot be rebalanced and return ErrReadOnly.
+func (tree *SchemaTree) Rebalance() error {
+	if tree.flat != nil {
+		return ErrReadOnly
+	}
+	t1 := time.Now()
+
+	// collect all transactions, i.e. the paths together with the number of subjects that end in them
+	type transaction struct {
+		properties IList
+		count      uint64
+	}
+	var transactions []transaction
+	tree.eachTransaction(func(properties IList, count uint64) {
+		transactions = append(transactions, transaction{properties, count})
+	})
+
+	// reset the tree and reinsert everything with the new sort order
+	for _, item := range tree.PropMap {
+		item.traversalPointer = nil
+	}
+	tree.Root = newRootNode(tree.PropMap)
+	tree.updateSortOrder()
+	for _, t := range transactions {
+		tree.insertTransaction(t.properties, t.count)
+	}
+
+	fmt.Printf("Rebalanced %v transactions (%v)\n", len(transactions), time.Since(t1))
+	return nil
+}
+
+// insertTransaction inserts a property set that has been seen count times.
+// thread-safe
+func (tree *SchemaTree) insertTransaction(properties IList, count uint64) {
+	properties.Sort()
+	if tree.builder != nil {
+		tree.Root.addSupport(count)
+		tree.builder.insert(properties, count)
+		return
+	}
+	node := &tree.Root
+	node.addSupport(count)
+	for _, prop := range properties {
+		node = node.getOrCreateChild(prop)
+		node.addSupport(count)
+	}
+}
+
+// DeltaStats summarizes the application of a delta file.
+type DeltaStats struct {
+	Added      uint64  // subjects (or new property sets of changed subjects) that were added
+	Removed    uint64  // subjects (or old property sets of changed subjects) that were removed
+	Mismatched uint64  // removals that did not match any property set of the tree and were skipped
+	Drift      float64 // SortOrderDrift after the update, before a possible rebalancing
+	Rebalanced bool    // true if the tree has been rebalanced
+}
+
+// ApplyDelta updates the tree with the changes listed in a delta file and rebalances it if the sort order
+// drift exceeds rebalanceThreshold. A threshold of zero or less never rebalances.
+//
+// The delta file contains N-Triples that are prefixed with `+` or `-` and grouped by subject. For every
+// subject, the `-` triples describe its old state, which is removed from the tree, and the `+` triples its
+// new state, which is added. Removed subjects therefore only have `-` triples, new subjects only `+` triples
+// and changed subjects both. Empty lines and lines starting with `#` are ignored.
+//
+// The number of subjects in the metadata follows the added and removed subjects, the build time is set to the
+// time of the update and the cardinalities count the values of the changed subjects. The object statistics
+// of the changed properties are marked as stale, since the objects of the other subjects are unknown.
+// The traversal lists are rebuilt once at the end if any nodes have been pruned.
+func (tree *SchemaTree) ApplyDelta(fileName string, rebalanceThreshold float64) (stats DeltaStats, err error) {
+	if tree.flat != nil {
+		return stats, ErrReadOnly
+	}
+	if tree.Config().InverseProperties {
+		return stats, ErrInverseDelta
+	}
+	reader, err := rio.UniversalReader(fileName)
+	if err != nil {
+		return stats, err
+	}
+	defer reader.Close()
+
+	classes := newClassifier(tree.Config(), tree.PropMap)
+	var lastSubj []byte
+	var removed, added *SubjectSummary
+	pruned := false
+	newSummary := func() *SubjectSummary {
+		return &SubjectSummary{Properties: make(map[*IItem]uint32)}
+	}
+	flush := func() {
+		if len(removed.Properties) > 0 {
+			if node, err := tree.removePath(removed); err != nil {
+				stats.Mismatched++
+				if stats.Mismatched <= 10 {
+					log.Printf("WARNING: cannot remove subject %s: %v\n", lastSubj, err)
+				}
+			} else {
+				pruned = pruned || node != nil
+				stats.Removed++
+				tree.markStaleObjectStats(removed)
+				tree.updateCardinalities(removed, true)
+			}
+		}
+		if len(added.Properties) > 0 {
+			tree.Add(added)
+			stats.Added++
+			tree.markStaleObjectStats(added)
+			tree.updateCardinalities(added, false)
+		}
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	var lineNum uint64
+	for scanner.Scan() {
+		lineNum++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		if line[0] != '+' && line[0] != '-' {
+			err = &rio.ParseError{Line: lineNum, Column: 1, Msg: "delta lines must start with '+' or '-'"}
+		}
+
+		var trip *rio.Triple
+		if err == nil {
+			trip, err = rio.ParseTriple(append([]byte(nil), line[1:]...), 3, rio.DefaultParseMode)
+			if perr, ok := err.(*rio.ParseError); ok {
+				perr.Line = lineNum
+				perr.Column++ // account for the prefix
+			}
+		}
+		if err != nil {
+			if rio.DefaultParseMode == rio.Strict {
+				return stats, err
+			}
+			log.Printf("Skipping malformed delta line: %v\n", err)
+			err = nil
+			continue
+		}
+		if trip == nil {
+			continue
+		}
+
+		if !bytes.Equal(lastSubj, trip.Subject.Raw) {
+			if lastSubj != nil {
+				flush()
+			}
+			lastSubj = trip.Subject.Raw
+			removed, added = newSummary(), newSummary()
+		}
+		if line[0] == '-' {
+			removed.addTriple(trip, classes, tree.Typed)
+		} else {
+			added.addTriple(trip, classes, tree.Typed)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return stats, err
+	}
+	if lastSubj != nil {
+		flush()
+	}
+	if pruned {
+		tree.relinkTraversalLists()
+	}
+	if tree.Meta.Subjects+stats.Added >= stats.Removed {
+		tree.Meta.Subjects = tree.Meta.Subjects + stats.Added - stats.Removed
+	} else {
+		tree.Meta.Subjects = 0 // the metadata did not count the subjects, e.g. of an old model
+	}
+	tree.Meta.BuildTime = time.Now()
+
+	stats.Drift = tree.SortOrderDrift()
+	if rebalanceThreshold > 0 && stats.Drift > rebalanceThreshold {
+		if err = tree.Rebalance(); err != nil {
+			return stats, err
+		}
+		stats.Rebalanced = true
+	}
+	return stats, nil
+}
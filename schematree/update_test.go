@@ -0,0 +1,142 @@
+package schematree
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// transactionsOf lists the property sets stored in the tree together with the number of subjects, in any layout.
+func transactionsOf(tree *SchemaTree) map[string]uint64 {
+	result := make(map[string]uint64)
+	var collect func(node *SchemaNode, path []string)
+	collect = func(node *SchemaNode, path []string) {
+		if ends := node.Support - node.childSupport(); ends > 0 {
+			key := append([]string(nil), path...)
+			sort.Strings(key)
+			result[strings.Join(key, " ")] += ends
+		}
+		for _, child := range node.Children {
+			collect(child, append(path, *child.ID.Str))
+		}
+	}
+	if tree.flat == nil {
+		collect(&tree.Root, nil)
+		return result
+	}
+
+	// compact and mapped trees
+	flat := tree.flat
+	var collectFlat func(node uint32, path []string)
+	collectFlat = func(node uint32, path []string) {
+		ends := flat.nodeSupport.get(node)
+		for child := flat.childStarts[node]; child < flat.childStarts[node+1]; child++ {
+			ends -= flat.nodeSupport.get(child)
+		}
+		if ends > 0 {
+			key := append([]string(nil), path...)
+			sort.Strings(key)
+			result[strings.Join(key, " ")] += ends
+		}
+		for child := flat.childStarts[node]; child < flat.childStarts[node+1]; child++ {
+			collectFlat(child, append(path, *flat.items[flat.nodeItem[child]].Str))
+		}
+	}
+	collectFlat(0, nil)
+	return result
+}
+
+func writeLines(t *testing.T, name string, lines ...string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644))
+	return path
+}
+
+func TestApplyDelta(t *testing.T) {
+	before := writeLines(t, "before.nt",
+		`<http://ex.org/a> <http://ex.org/p> "1" .`,
+		`<http://ex.org/a> <http://ex.org/q> "1" .`,
+		`<http://ex.org/b> <http://ex.org/p> "1" .`,
+		`<http://ex.org/c> <http://ex.org/p> "1" .`,
+		`<http://ex.org/c> <http://ex.org/r> "1" .`,
+	)
+	after := writeLines(t, "after.nt",
+		`<http://ex.org/a> <http://ex.org/p> "1" .`,
+		`<http://ex.org/a> <http://ex.org/q> "1" .`,
+		`<http://ex.org/c> <http://ex.org/r> "1" .`,
+		`<http://ex.org/c> <http://ex.org/s> "1" .`,
+		`<http://ex.org/d> <http://ex.org/s> "1" .`,
+		`<http://ex.org/e> <http://ex.org/s> "1" .`,
+	)
+	delta := writeLines(t, "delta.txt",
+		`# b is deleted, c is changed and d, e are new`,
+		`- <http://ex.org/b> <http://ex.org/p> "1" .`,
+		`- <http://ex.org/c> <http://ex.org/p> "1" .`,
+		`- <http://ex.org/c> <http://ex.org/r> "1" .`,
+		`+ <http://ex.org/c> <http://ex.org/r> "1" .`,
+		`+ <http://ex.org/c> <http://ex.org/s> "1" .`,
+		`+ <http://ex.org/d> <http://ex.org/s> "1" .`,
+		`+ <http://ex.org/e> <http://ex.org/s> "1" .`,
+		`- <http://ex.org/x> <http://ex.org/unknown> "1" .`,
+	)
+
+	expected := New(false, 1)
+	expected.TwoPass(after, 0)
+
+	t.Run("without rebalancing", func(t *testing.T) {
+		tree := New(false, 1)
+		tree.TwoPass(before, 0)
+		built := tree.Meta.BuildTime
+		stats, err := tree.ApplyDelta(delta, 0)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 4, tree.Meta.Subjects)
+		assert.True(t, tree.Meta.BuildTime.After(built))
+		// q is only used by a, which has not changed
+		assert.False(t, tree.Meta.ObjectStats["http://ex.org/q"].Stale)
+		assert.True(t, tree.Meta.ObjectStats["http://ex.org/p"].Stale)
+		assert.True(t, tree.Meta.ObjectStats["http://ex.org/r"].Stale)
+		assert.Equal(t, expected.Meta.Cardinalities, tree.Meta.Cardinalities)
+		assert.EqualValues(t, DeltaStats{Added: 3, Removed: 2, Mismatched: 1, Drift: stats.Drift}, stats)
+		assert.Equal(t, transactionsOf(expected), transactionsOf(tree))
+		assert.EqualValues(t, 4, tree.Root.Support)
+		assert.EqualValues(t, 3, tree.PropMap["http://ex.org/s"].TotalCount)
+		assert.EqualValues(t, 1, tree.PropMap["http://ex.org/p"].TotalCount)
+
+		// the support queries work on the pruned tree
+		assert.EqualValues(t, 1, tree.Support(IList{tree.PropMap["http://ex.org/p"]}))
+		assert.EqualValues(t, 3, tree.Support(IList{tree.PropMap["http://ex.org/s"]}))
+		assert.Greater(t, stats.Drift, 0.0)
+
+		// the pruned nodes are no longer in the traversal lists
+		for _, item := range tree.PropMap {
+			for node := item.traversalPointer; node != nil; node = node.nextSameID {
+				assert.Contains(t, node.parent.Children, node)
+			}
+		}
+	})
+
+	t.Run("with rebalancing", func(t *testing.T) {
+		tree := New(false, 1)
+		tree.TwoPass(before, 0)
+		stats, err := tree.ApplyDelta(delta, 0.01)
+		assert.NoError(t, err)
+		assert.True(t, stats.Rebalanced)
+		assert.Equal(t, 0.0, tree.SortOrderDrift())
+		assert.Equal(t, transactionsOf(expected), transactionsOf(tree))
+		assert.EqualValues(t, 3, tree.Support(IList{tree.PropMap["http://ex.org/s"]}))
+		assert.EqualValues(t, 1, tree.Support(IList{tree.PropMap["http://ex.org/r"], tree.PropMap["http://ex.org/s"]}))
+	})
+
+	t.Run("remove unknown property set", func(t *testing.T) {
+		tree := New(false, 1)
+		tree.TwoPass(before, 0)
+		q := tree.PropMap["http://ex.org/q"]
+		err := tree.Remove(&SubjectSummary{Properties: map[*IItem]uint32{q: 1}})
+		assert.Equal(t, ErrTransactionNotFound, err)
+		assert.EqualValues(t, 3, tree.Root.Support)
+	})
+}
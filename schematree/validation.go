@@ -0,0 +1,176 @@
+package schematree
+
+import (
+	"sort"
+	"strings"
+)
+
+// ValidationOptions are the thresholds of the validation of subject descriptions.
+type ValidationOptions struct {
+	MinProbability float64 // properties that are at least this likely given the description are expected
+	MaxProbability float64 // present properties that are less likely given the rest of the description are surprising
+	MaxShare       float64 // present properties whose number of values is rarer than this have a suspicious multiplicity
+}
+
+// DefaultValidationOptions are the thresholds of the /validate endpoint and the validate command.
+var DefaultValidationOptions = ValidationOptions{MinProbability: 0.9, MaxProbability: 0.01, MaxShare: 0.01}
+
+// ValidationReport lists the findings of the validation of a subject description.
+type ValidationReport struct {
+	Subject    string              `json:"subject,omitempty"`
+	Support    uint64              `json:"support"`    // number of subjects of the tree with the whole description
+	Missing    []ValidationFinding `json:"missing"`    // expected properties that the description lacks, the most likely first
+	Surprising []ValidationFinding `json:"surprising"` // present properties that are unlikely given the rest, the least likely first
+	// SuspiciousMultiplicities are present properties with an unusual number of values, the rarest first
+	SuspiciousMultiplicities []MultiplicityFinding `json:"suspiciousMultiplicities"`
+	UnknownProperties        []string              `json:"unknownProperties,omitempty"`
+	UnknownTypes             []string              `json:"unknownTypes,omitempty"`
+}
+
+// ValidationFinding is a property of a ValidationReport with its probability given the rest of the description.
+type ValidationFinding struct {
+	Property    string  `json:"property"`
+	Probability float64 `json:"probability"`
+}
+
+// MultiplicityFinding is a property of a ValidationReport whose number of values is rare, see Cardinality.Share.
+type MultiplicityFinding struct {
+	Property string  `json:"property"`
+	Values   uint32  `json:"values"`
+	Share    float64 `json:"share"`   // share of the subjects with the property that have about as many values
+	Typical  string  `json:"typical"` // the typical number of values, e.g. "usually 1 value"
+}
+
+// HasFindings tells whether the description lacks expected properties or has surprising or unknown ones.
+func (r *ValidationReport) HasFindings() bool {
+	return len(r.Missing) > 0 || len(r.Surprising) > 0 || len(r.SuspiciousMultiplicities) > 0 ||
+		len(r.UnknownProperties) > 0 || len(r.UnknownTypes) > 0
+}
+
+// Validate checks a complete subject description. Properties that are missing although they are at least
+// MinProbability likely given the description (see RecommendProperty) are expected, present properties whose
+// probability given the rest of the description is below MaxProbability are surprising. The probability of a
+// present property p is Support(description) / Support(description without p). A property that is given n
+// times has n values; if fewer than MaxShare of the subjects with the property have about as many values, its
+// multiplicity is suspicious. Properties and types that the tree does not know are reported separately,
+// predicates excluded by the build configuration are ignored.
+func (tree *SchemaTree) Validate(properties []string, types []string, options ValidationOptions) *ValidationReport {
+	report := &ValidationReport{}
+	config := tree.Config()
+	list := IList{}
+	values := make(map[*IItem]uint32)
+	for _, pString := range properties {
+		if !config.ReadsPredicate(pString) {
+			continue
+		}
+		if p, ok := tree.PropMap[pString]; ok {
+			list = append(list, p)
+			values[p]++
+		} else {
+			report.UnknownProperties = append(report.UnknownProperties, pString)
+		}
+	}
+	for _, tString := range types {
+		if p, ok := tree.PropMap[typePrefix+tString]; ok {
+			list = append(list, p)
+		} else if tree.Typed {
+			report.UnknownTypes = append(report.UnknownTypes, tString)
+		}
+	}
+	tree.validate(list, values, options, report)
+	return report
+}
+
+// ValidateDataset validates the first firstN subjects of a dataset (all subjects if firstN is zero) like
+// Validate and calls report with the report of each subject. The calls happen concurrently and in no
+// particular order. The tree is not modified.
+func (tree *SchemaTree) ValidateDataset(fileName string, firstN uint64, options ValidationOptions, report func(*ValidationReport)) {
+	validate := func(s *SubjectSummary, list IList, unknown []string) {
+		r := &ValidationReport{Subject: s.Str}
+		for _, str := range unknown {
+			if strings.HasPrefix(str, typePrefix) {
+				r.UnknownTypes = append(r.UnknownTypes, str[len(typePrefix):])
+			} else {
+				r.UnknownProperties = append(r.UnknownProperties, str)
+			}
+		}
+		sort.Strings(r.UnknownProperties)
+		sort.Strings(r.UnknownTypes)
+		tree.validate(list, s.Properties, options, r)
+		report(r)
+	}
+	tree.readKnownSubjects(fileName, firstN, validate)
+}
+
+// validate adds the missing and surprising properties of a list of known items to the report, as well as the
+// properties whose number of values is suspicious.
+func (tree *SchemaTree) validate(list IList, values map[*IItem]uint32, options ValidationOptions, report *ValidationReport) {
+	report.Missing = []ValidationFinding{}
+	report.Surprising = []ValidationFinding{}
+	report.SuspiciousMultiplicities = tree.suspiciousMultiplicities(values, options.MaxShare)
+	// without known properties nothing is expected
+	if len(list) == 0 {
+		report.Support = tree.Root.Support
+		return
+	}
+	list.sortAndDeduplicate()
+	report.Support = tree.Support(list)
+
+	// the type predicates are left out, as types are usually given separately
+	config := tree.Config()
+	for _, rec := range tree.RecommendProperty(append(IList(nil), list...)) {
+		if rec.Probability < options.MinProbability {
+			break // the recommendations are sorted by probability
+		}
+		if rec.Property.IsProp() && !config.IsTypePredicate(*rec.Property.Str) {
+			report.Missing = append(report.Missing, ValidationFinding{*rec.Property.Str, rec.Probability})
+		}
+	}
+
+	rest := make(IList, 0, len(list))
+	for i, item := range list {
+		if !item.IsProp() {
+			continue
+		}
+		rest = append(append(rest[:0], list[:i]...), list[i+1:]...)
+		restSupport := tree.Support(rest)
+		if restSupport == 0 {
+			continue // the rest of the description is unknown itself, so the property is not to blame
+		}
+		probability := float64(report.Support) / float64(restSupport)
+		if probability < options.MaxProbability {
+			report.Surprising = append(report.Surprising, ValidationFinding{*item.Str, probability})
+		}
+	}
+	sort.SliceStable(report.Surprising, func(i, j int) bool {
+		if report.Surprising[i].Probability != report.Surprising[j].Probability {
+			return report.Surprising[i].Probability < report.Surprising[j].Probability
+		}
+		return report.Surprising[i].Property < report.Surprising[j].Property
+	})
+}
+
+// suspiciousMultiplicities returns the properties whose number of values fewer than maxShare of the subjects with
+// the property have, the rarest first. Properties without a cardinality histogram are skipped.
+func (tree *SchemaTree) suspiciousMultiplicities(values map[*IItem]uint32, maxShare float64) []MultiplicityFinding {
+	findings := []MultiplicityFinding{}
+	for item, count := range values {
+		if !item.IsProp() {
+			continue
+		}
+		cardinality := tree.CardinalityOf(item)
+		if cardinality == nil || cardinality.Total() == 0 {
+			continue
+		}
+		if share := cardinality.Share(count); share < maxShare {
+			findings = append(findings, MultiplicityFinding{*item.Str, count, share, cardinality.String()})
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Share != findings[j].Share {
+			return findings[i].Share < findings[j].Share
+		}
+		return findings[i].Property < findings[j].Property
+	})
+	return findings
+}
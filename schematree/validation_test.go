@@ -0,0 +1,95 @@
+package schematree
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	lines := []string{
+		`<http://ex.org/z> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/City> .`,
+		`<http://ex.org/z> <http://ex.org/name> "z" .`,
+		`<http://ex.org/z> <http://ex.org/country> <http://ex.org/Germany> .`,
+		`<http://ex.org/z> <http://ex.org/shoeSize> "42" .`,
+	}
+	for i := 0; i < 9; i++ {
+		lines = append(lines,
+			fmt.Sprintf(`<http://ex.org/c%v> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/City> .`, i),
+			fmt.Sprintf(`<http://ex.org/c%v> <http://ex.org/name> "%v" .`, i, i),
+			fmt.Sprintf(`<http://ex.org/c%v> <http://ex.org/country> <http://ex.org/Germany> .`, i),
+		)
+	}
+	dataset := writeLines(t, "dataset.nt", lines...)
+	tree := New(true, 1)
+	tree.TwoPass(dataset, 0)
+	options := ValidationOptions{MinProbability: 0.9, MaxProbability: 0.2, MaxShare: 0.01}
+
+	t.Run("missing properties", func(t *testing.T) {
+		report := tree.Validate([]string{"http://ex.org/name"}, []string{"http://ex.org/City"}, options)
+		assert.EqualValues(t, 10, report.Support)
+		assert.Equal(t, []ValidationFinding{{"http://ex.org/country", 1}}, report.Missing)
+		assert.Empty(t, report.Surprising)
+		assert.False(t, tree.Validate([]string{"http://ex.org/name", "http://ex.org/country"}, []string{"http://ex.org/City"}, options).HasFindings())
+	})
+
+	t.Run("surprising properties", func(t *testing.T) {
+		report := tree.Validate([]string{"http://ex.org/name", "http://ex.org/country", "http://ex.org/shoeSize"},
+			[]string{"http://ex.org/City"}, options)
+		assert.EqualValues(t, 1, report.Support)
+		assert.Equal(t, []ValidationFinding{{"http://ex.org/shoeSize", 0.1}}, report.Surprising)
+		assert.Empty(t, report.Missing)
+	})
+
+	t.Run("unknown properties and types", func(t *testing.T) {
+		report := tree.Validate([]string{"http://ex.org/name", "http://ex.org/unknown"}, []string{"http://ex.org/Town"}, options)
+		assert.Equal(t, []string{"http://ex.org/unknown"}, report.UnknownProperties)
+		assert.Equal(t, []string{"http://ex.org/Town"}, report.UnknownTypes)
+		assert.True(t, report.HasFindings())
+
+		empty := tree.Validate(nil, nil, options)
+		assert.Empty(t, empty.Missing)
+		assert.EqualValues(t, 10, empty.Support)
+	})
+
+	t.Run("suspicious multiplicities", func(t *testing.T) {
+		// all cities have one name and one country
+		report := tree.Validate([]string{"http://ex.org/name", "http://ex.org/name", "http://ex.org/country"},
+			[]string{"http://ex.org/City"}, options)
+		assert.Equal(t, []MultiplicityFinding{{"http://ex.org/name", 2, 0, "usually 1 value"}}, report.SuspiciousMultiplicities)
+		assert.True(t, report.HasFindings())
+
+		lenient := options
+		lenient.MaxShare = 0
+		assert.Empty(t, tree.Validate([]string{"http://ex.org/name", "http://ex.org/name"}, nil, lenient).SuspiciousMultiplicities)
+	})
+
+	t.Run("datasets", func(t *testing.T) {
+		check := writeLines(t, "check.nt",
+			`<http://ex.org/q> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/City> .`,
+			`<http://ex.org/q> <http://ex.org/name> "q" .`,
+			`<http://ex.org/r> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/Town> .`,
+			`<http://ex.org/r> <http://ex.org/name> "r" .`,
+			`<http://ex.org/r> <http://ex.org/country> <http://ex.org/Germany> .`,
+			`<http://ex.org/r> <http://ex.org/country> <http://ex.org/France> .`,
+			`<http://ex.org/r> <http://ex.org/unknown> "r" .`,
+		)
+		var lock sync.Mutex
+		reports := make(map[string]*ValidationReport)
+		tree.ValidateDataset(check, 0, options, func(r *ValidationReport) {
+			lock.Lock()
+			reports[r.Subject] = r
+			lock.Unlock()
+		})
+		assert.Len(t, reports, 2)
+		assert.Equal(t, []ValidationFinding{{"http://ex.org/country", 1}}, reports["http://ex.org/q"].Missing)
+		assert.Equal(t, []string{"http://ex.org/unknown"}, reports["http://ex.org/r"].UnknownProperties)
+		assert.Equal(t, []string{"http://ex.org/Town"}, reports["http://ex.org/r"].UnknownTypes)
+		assert.Empty(t, reports["http://ex.org/r"].Missing)
+		assert.Equal(t, []MultiplicityFinding{{"http://ex.org/country", 2, 0, "usually 1 value"}}, reports["http://ex.org/r"].SuspiciousMultiplicities)
+		assert.Empty(t, reports["http://ex.org/q"].SuspiciousMultiplicities)
+		assert.NotContains(t, tree.PropMap, "http://ex.org/unknown")
+	})
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"recommender/glossary"
+	"recommender/internal/parallel"
+	"recommender/schematree"
+	"recommender/strategy"
+	"time"
+)
+
+// Limits of the batch recommender, so that a single batch cannot exhaust the memory of the server.
+const (
+	maxBatchBytes    = 16 << 20 // size of the request body
+	maxBatchRequests = 10000    // number of requests in a batch
+)
+
+// BatchResponseLine is one line of the NDJSON response of the batch recommender, the result of the request
+// at Index of the batch.
+type BatchResponseLine struct {
+	Index           int                         `json:"index"`
+	Recommendations []RecommendationOutputEntry `json:"recommendations"`
+	Error           string                      `json:"error,omitempty"` // what is wrong with the request, if anything
+}
+
+// setupBatchRecommender will setup a handler that answers a JSON array of /recommender requests. The requests
+// are evaluated concurrently by up to workers goroutines (one per CPU if workers is not positive) and the
+// results are streamed back as one JSON line per request, in the order of the requests. Batches of more than
+// maxBatchBytes or maxBatchRequests are rejected.
+func setupBatchRecommender(
+	model *schematree.SchemaTree,
+	glos *glossary.Glossary,
+	workflow *strategy.Workflow,
+	cache *strategy.Cache,
+	hardLimit int,
+	workers int,
+) func(http.ResponseWriter, *http.Request) {
+	return func(res http.ResponseWriter, req *http.Request) {
+
+		// Decode the JSON input, an array of requests
+		var inputs []RecommenderRequest
+		err := json.NewDecoder(http.MaxBytesReader(res, req.Body, maxBatchBytes)).Decode(&inputs)
+		if _, tooLarge := err.(*http.MaxBytesError); tooLarge {
+			http.Error(res, fmt.Sprintf("Batch too large. At most %v bytes are accepted", maxBatchBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			res.Write([]byte("Malformed Request. Expected an array of recommender requests"))
+			return
+		}
+		if len(inputs) > maxBatchRequests {
+			http.Error(res, fmt.Sprintf("Batch too large. At most %v requests are accepted", maxBatchRequests), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		// Make the recommendations concurrently and write each line as soon as all earlier ones are written.
+		res.Header().Set("Content-Type", "application/x-ndjson")
+		flusher, _ := res.(http.Flusher)
+		encoder := json.NewEncoder(res)
+		lines := make([]BatchResponseLine, len(inputs))
+		t1 := time.Now()
+		parallel.InOrder(len(inputs), workers, func(i int) {
+			recs, err := recommend(&inputs[i], model, glos, workflow, cache, hardLimit)
+			lines[i] = BatchResponseLine{Index: i, Recommendations: recs}
+			if err != nil {
+				lines[i].Error, lines[i].Recommendations = err.Error(), []RecommendationOutputEntry{}
+			}
+		}, func(i int) {
+			encoder.Encode(lines[i])
+			lines[i] = BatchResponseLine{}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		})
+		fmt.Println(time.Since(t1))
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// Reloadable serves the endpoints of the current model and switches to the endpoints of a new model on Replace.
+// Requests that have started before keep using the endpoints they started with.
+type Reloadable struct {
+	current atomic.Value // *generation
+}
+
+// generation is one set of endpoints. Every request holds its lock for reading.
+type generation struct {
+	handler http.Handler
+	lock    sync.RWMutex
+	retired bool // set once all requests of the generation have finished after Replace
+}
+
+// NewReloadable serves the handler until it is replaced.
+func NewReloadable(handler http.Handler) *Reloadable {
+	r := &Reloadable{}
+	r.current.Store(&generation{handler: handler})
+	return r
+}
+
+// ServeHTTP implements http.Handler.
+func (r *Reloadable) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	for {
+		g := r.current.Load().(*generation)
+		g.lock.RLock()
+		if !g.retired {
+			defer g.lock.RUnlock()
+			g.handler.ServeHTTP(res, req)
+			return
+		}
+		g.lock.RUnlock() // replaced in the meantime, take the new generation
+	}
+}
+
+// Replace serves all further requests with the handler. It returns once the requests of the previous handler
+// have finished, so that the model of the previous handler can be released afterwards.
+func (r *Reloadable) Replace(handler http.Handler) {
+	previous := r.current.Swap(&generation{handler: handler}).(*generation)
+	previous.lock.Lock()
+	previous.retired = true
+	previous.lock.Unlock()
+}
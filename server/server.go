@@ -1,8 +1,10 @@
 package server
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
@@ -17,6 +19,27 @@ type RecommenderRequest struct {
 	Lang       string   `json:"lang"`
 	Types      []string `json:"types"`
 	Properties []string `json:"properties"`
+	Direction  string   `json:"direction,omitempty"` // both (default), outgoing or incoming, see schematree.Direction
+	ValuesFor  string   `json:"valuesFor,omitempty"` // a value predicate to recommend values for instead of properties
+	Limit      int      `json:"limit,omitempty"`     // maximum number of recommendations, at most the hard limit of the server
+}
+
+// limit returns the number of recommendations to return for a request.
+func (input *RecommenderRequest) limit(hardLimit int) int {
+	if input.Limit > 0 && input.Limit < hardLimit {
+		return input.Limit
+	}
+	return hardLimit
+}
+
+// assess makes an assessment of the input properties. Only the top recommendations are computed, unless they
+// are filtered by direction afterwards.
+func (input *RecommenderRequest) assess(model *schematree.SchemaTree, direction schematree.Direction, hardLimit int) *assessment.Instance {
+	asm := assessment.NewInstanceFromInput(input.Properties, input.Types, model, true)
+	if direction == schematree.BothDirections {
+		asm.Limit = input.limit(hardLimit)
+	}
+	return asm
 }
 
 // RecommenderResponse is the data representation of the json.
@@ -27,9 +50,84 @@ type RecommenderResponse struct {
 // RecommendationOutputEntry is each entry that is return from the server.
 type RecommendationOutputEntry struct {
 	PropertyStr *string `json:"property"`
+	Value       *string `json:"value,omitempty"` // only set for value recommendations
 	Label       *string `json:"label"`
 	Description *string `json:"description"`
 	Probability float64 `json:"probability"`
+	// Support is the number of subjects with the input and the property, SetSupport of those with the input
+	Support    uint64 `json:"support"`
+	SetSupport uint64 `json:"setSupport"`
+
+	// Objects tells what kind of objects the property usually has, if the model has statistics for it
+	Objects *schematree.ObjectStats `json:"objects,omitempty"`
+	// Cardinality is the histogram of the number of values per subject, Multiplicity describes it
+	Cardinality  *schematree.Cardinality `json:"cardinality,omitempty"`
+	Multiplicity string                  `json:"multiplicity,omitempty"`
+}
+
+// addStatistics adds what the model knows about the objects and the number of values of a recommended property.
+func (entry *RecommendationOutputEntry) addStatistics(model *schematree.SchemaTree, property *schematree.IItem) {
+	entry.Objects = model.ObjectStatsOf(property)
+	if cardinality := model.CardinalityOf(property); cardinality != nil {
+		entry.Cardinality = cardinality
+		entry.Multiplicity = cardinality.String()
+	}
+}
+
+// recommend computes the output of the /recommender endpoint for a request. The error tells what is wrong with
+// the request.
+func recommend(
+	input *RecommenderRequest,
+	model *schematree.SchemaTree,
+	glos *glossary.Glossary,
+	workflow *strategy.Workflow,
+	cache *strategy.Cache,
+	hardLimit int,
+) ([]RecommendationOutputEntry, error) {
+	direction, err := schematree.ParseDirection(input.Direction)
+	if err != nil {
+		return nil, err
+	}
+
+	// TODO: Probably some more input sanitization is required.
+
+	// Make an assessment of the input properties.
+	assessment := input.assess(model, direction, hardLimit)
+
+	// Make a recommendation based on the assessed input and chosen strategy. Values of a predicate are
+	// recommended from the property=value items of the tree, see schematree.BuildConfig.ValuePredicates.
+	var origRecs schematree.PropertyRecommendations
+	if input.ValuesFor != "" {
+		if !model.Config().IsValuePredicate(input.ValuesFor) {
+			return nil, fmt.Errorf("The model has no values for %v", input.ValuesFor)
+		}
+		origRecs = model.RecommendValues(assessment.Props, input.ValuesFor)
+	} else {
+		origRecs = cache.Recommend(workflow, assessment).FilterDirection(direction)
+	}
+
+	// Put a hard limit on the recommendations returned.
+	if limit := input.limit(hardLimit); len(origRecs) > limit {
+		origRecs = origRecs[:limit]
+	}
+
+	// For each recommendation, add a mapping from the glossary.
+	labRecs := glossary.TranslateRecommendations(glos, input.Lang, origRecs)
+
+	// Prepare the recommendation list. The structure of the output is flatter than the labeled recommendations.
+	outputRecs := make([]RecommendationOutputEntry, len(labRecs), len(labRecs))
+	for i, rec := range labRecs {
+		outputRecs[i].PropertyStr = rec.Property.Str
+		if predicate, value, ok := rec.Property.ValueOf(); ok {
+			outputRecs[i].PropertyStr, outputRecs[i].Value = &predicate, &value
+		}
+		outputRecs[i].Label = &rec.Content.Label
+		outputRecs[i].Description = &rec.Content.Description
+		outputRecs[i].Probability = rec.Probability
+		outputRecs[i].Support, outputRecs[i].SetSupport = origRecs[i].Support, origRecs[i].SetSupport
+		outputRecs[i].addStatistics(model, rec.Property)
+	}
+	return outputRecs, nil
 }
 
 // setupRecommender will setup a handler to recommend properties based on the list of properties and types. It
@@ -39,6 +137,7 @@ func setupMappedRecommender(
 	model *schematree.SchemaTree,
 	glos *glossary.Glossary,
 	workflow *strategy.Workflow,
+	cache *strategy.Cache, // shared cache of the recommendations of the workflow, may be nil
 	hardLimit int, // Hard limit of recommendations to output
 ) func(http.ResponseWriter, *http.Request) {
 
@@ -89,47 +188,91 @@ func setupMappedRecommender(
 		}
 		fmt.Println(input) // debug: output the request
 
-		// TODO: Probably some more input sanitization is required.
+		// Make a recommendation based on the input and chosen strategy.
+		t1 := time.Now()
+		outputRecs, err := recommend(&input, model, glos, workflow, cache, hardLimit)
+		if err != nil {
+			res.Write([]byte("Malformed Request. " + err.Error()))
+			return
+		}
+		fmt.Println(time.Since(t1))
 
-		// Make an assessment of the input properties.
-		assessment := assessment.NewInstanceFromInput(input.Properties, input.Types, model, true)
+		// Pack everything into the response
+		recResp := RecommenderResponse{Recommendations: outputRecs}
 
-		// Make a recommendation based on the assessed input and chosen strategy.
+		// Write the recommendations as a JSON array.
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(recResp)
+	}
+
+}
+
+// TypeRecommenderResponse is the data representation of the json returned by the type recommender.
+type TypeRecommenderResponse struct {
+	Recommendations []TypeRecommendationOutputEntry `json:"recommendations"`
+}
+
+// TypeRecommendationOutputEntry is a recommended type (class) returned from the server.
+type TypeRecommendationOutputEntry struct {
+	Type        string  `json:"type"`
+	Label       *string `json:"label"`
+	Description *string `json:"description"`
+	Probability float64 `json:"probability"`
+}
+
+// setupTypeRecommender will setup a handler to recommend types based on the list of properties and types of a
+// subject. It returns the ranked types with their conditional probabilities, labels and descriptions. The model
+// has to be typed.
+func setupTypeRecommender(
+	model *schematree.SchemaTree,
+	glos *glossary.Glossary,
+	hardLimit int, // Hard limit of recommendations to output
+) func(http.ResponseWriter, *http.Request) {
+	return func(res http.ResponseWriter, req *http.Request) {
+
+		// Decode the JSON input and build a list of input strings
+		var input = RecommenderRequest{}
+		err := json.NewDecoder(req.Body).Decode(&input)
+		if err != nil {
+			res.Write([]byte("Malformed Request."))
+			return
+		}
+		if !model.Typed {
+			res.Write([]byte("The model has no type information, it has to be built with build-tree-typed."))
+			return
+		}
+
+		// Make a recommendation for the input properties and types.
 		t1 := time.Now()
-		origRecs := workflow.Recommend(assessment)
+		origRecs := model.RecommendTypes(input.Properties, input.Types)
 		fmt.Println(time.Since(t1))
 
-		// Put a hard limit on the recommendations returned.
-		if len(origRecs) > hardLimit {
-			origRecs = origRecs[:hardLimit]
+		// Put a limit on the recommendations returned.
+		if limit := input.limit(hardLimit); len(origRecs) > limit {
+			origRecs = origRecs[:limit]
 		}
 
 		// For each recommendation, add a mapping from the glossary.
 		labRecs := glossary.TranslateRecommendations(glos, input.Lang, origRecs)
 
-		// Prepare the recommendation list. The structure of the output is flatter than the labeled recommendations.
-		outputRecs := make([]RecommendationOutputEntry, len(labRecs), len(labRecs))
+		outputRecs := make([]TypeRecommendationOutputEntry, len(labRecs), len(labRecs))
 		for i, rec := range labRecs {
-			outputRecs[i].PropertyStr = rec.Property.Str
+			outputRecs[i].Type, _ = rec.Property.TypeOf()
 			outputRecs[i].Label = &rec.Content.Label
 			outputRecs[i].Description = &rec.Content.Description
 			outputRecs[i].Probability = rec.Probability
 		}
 
-		// Pack everything into the response
-		recResp := RecommenderResponse{Recommendations: outputRecs}
-
 		// Write the recommendations as a JSON array.
 		res.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(res).Encode(recResp)
+		json.NewEncoder(res).Encode(TypeRecommenderResponse{Recommendations: outputRecs})
 	}
-
 }
 
 // setupRecommender will setup a handler to recommend properties based on the list of properties and types.
 // It will return an array of recommendations with their respective probabilities.
 // No gloassary information is added to the response.
-func setupLeanRecommender(tree *schematree.SchemaTree, workflow *strategy.Workflow) func(http.ResponseWriter, *http.Request) {
+func setupLeanRecommender(tree *schematree.SchemaTree, workflow *strategy.Workflow, cache *strategy.Cache) func(http.ResponseWriter, *http.Request) {
 
 	// Fetch the map of all properties in the SchemaTree
 	pMap := tree.PropMap
@@ -157,10 +300,11 @@ func setupLeanRecommender(tree *schematree.SchemaTree, workflow *strategy.Workfl
 
 		// Make an assessment of the input properties.
 		assessment := assessment.NewInstance(list, tree, true)
+		assessment.Limit = 500
 
 		// Make a recommendation based on the assessed input and chosen strategy.
 		t1 := time.Now()
-		rec := workflow.Recommend(assessment)
+		rec := cache.Recommend(workflow, assessment)
 		fmt.Println(time.Since(t1))
 
 		// Put a hard limit on the recommendations returned.
@@ -213,6 +357,7 @@ func setupSupportComputation(tree *schematree.SchemaTree) func(http.ResponseWrit
 
 // hacked together for gregors thesis
 // recommends both missing properties and missing types
+// (types alone are recommended by the /type-recommender endpoint)
 func setupPropTypeRec(
 	model *schematree.SchemaTree,
 ) func(http.ResponseWriter, *http.Request) {
@@ -226,13 +371,18 @@ func setupPropTypeRec(
 			return
 		}
 		fmt.Println(input) // debug: output the request
+		direction, err := schematree.ParseDirection(input.Direction)
+		if err != nil {
+			res.Write([]byte("Malformed Request. " + err.Error()))
+			return
+		}
 
 		// TODO: Probably some more input sanitization is required.
 
 		// Make a recommendation based on the assessed input and chosen strategy.
 		properties := model.BuildPropertyList(input.Properties, input.Types)
 		t1 := time.Now()
-		labRecs := model.RecommendPropertiesAndTypes(properties)
+		labRecs := model.RecommendPropertiesAndTypes(properties).FilterDirection(direction)
 		fmt.Println(time.Since(t1))
 
 		// Prepare the recommendation list. The structure of the output is flatter than the labeled recommendations.
@@ -241,6 +391,8 @@ func setupPropTypeRec(
 			// if rec.Property.IsType() {
 			outputRecs[i].PropertyStr = rec.Property.Str
 			outputRecs[i].Probability = rec.Probability
+			outputRecs[i].Support, outputRecs[i].SetSupport = rec.Support, rec.SetSupport
+			outputRecs[i].addStatistics(model, rec.Property)
 		}
 
 		// Pack everything into the response
@@ -253,13 +405,94 @@ func setupPropTypeRec(
 
 }
 
-// SetupEndpoints configures a router with all necessary endpoints and their corresponding handlers.
-func SetupEndpoints(model *schematree.SchemaTree, glossary *glossary.Glossary, workflow *strategy.Workflow, hardLimit int) http.Handler {
+// ValidationRequest is a complete subject description that is checked by the /validate endpoint. The
+// thresholds are optional, see schematree.DefaultValidationOptions.
+type ValidationRequest struct {
+	Types          []string `json:"types"`
+	Properties     []string `json:"properties"`
+	MinProbability float64  `json:"minProbability,omitempty"` // properties at least this likely are expected
+	MaxProbability float64  `json:"maxProbability,omitempty"` // present properties less likely than this are surprising
+	MaxShare       float64  `json:"maxShare,omitempty"`       // numbers of values rarer than this are suspicious
+}
+
+// setupValidation will setup a handler that reports which expected properties a subject description lacks
+// and which of its properties are surprising given the rest of it.
+func setupValidation(model *schematree.SchemaTree) func(http.ResponseWriter, *http.Request) {
+	return func(res http.ResponseWriter, req *http.Request) {
+
+		// Decode the JSON input
+		var input = ValidationRequest{}
+		err := json.NewDecoder(req.Body).Decode(&input)
+		if err != nil {
+			res.Write([]byte("Malformed Request."))
+			return
+		}
+
+		options := schematree.DefaultValidationOptions
+		if input.MinProbability > 0 {
+			options.MinProbability = input.MinProbability
+		}
+		if input.MaxProbability > 0 {
+			options.MaxProbability = input.MaxProbability
+		}
+		if input.MaxShare > 0 {
+			options.MaxShare = input.MaxShare
+		}
+
+		t1 := time.Now()
+		report := model.Validate(input.Properties, input.Types, options)
+		fmt.Println(time.Since(t1))
+
+		// Write the report as JSON.
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(report)
+	}
+}
+
+// setupCacheStatistics will setup a handler that returns the metrics of the recommendation cache.
+func setupCacheStatistics(cache *strategy.Cache) func(http.ResponseWriter, *http.Request) {
+	return func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(cache.Stats())
+	}
+}
+
+// WarmUp fills the cache from a query log with one request of the /recommender endpoint per line, as the endpoint
+// would compute them. Requests for values are skipped. It returns the number of cached queries.
+func WarmUp(cache *strategy.Cache, model *schematree.SchemaTree, workflow *strategy.Workflow, log io.Reader, hardLimit int) (queries int, err error) {
+	scanner := bufio.NewScanner(log)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var input RecommenderRequest
+		if err = json.Unmarshal(scanner.Bytes(), &input); err != nil {
+			return
+		}
+		direction, dirErr := schematree.ParseDirection(input.Direction)
+		if dirErr != nil || input.ValuesFor != "" {
+			continue
+		}
+		cache.Recommend(workflow, input.assess(model, direction, hardLimit))
+		queries++
+	}
+	err = scanner.Err()
+	return
+}
+
+// SetupEndpoints configures a router with all necessary endpoints and their corresponding handlers. The
+// recommendations of the workflow are shared between requests through the cache, if it is not nil.
+func SetupEndpoints(model *schematree.SchemaTree, glossary *glossary.Glossary, workflow *strategy.Workflow, cache *strategy.Cache, hardLimit int) http.Handler {
 	router := http.NewServeMux()
-	router.HandleFunc("/lean-recommender", setupLeanRecommender(model, workflow))
-	router.HandleFunc("/recommender", setupMappedRecommender(model, glossary, workflow, hardLimit))
+	router.HandleFunc("/lean-recommender", setupLeanRecommender(model, workflow, cache))
+	router.HandleFunc("/recommender", setupMappedRecommender(model, glossary, workflow, cache, hardLimit))
+	router.HandleFunc("/batch-recommender", setupBatchRecommender(model, glossary, workflow, cache, hardLimit, 0))
+	router.HandleFunc("/type-recommender", setupTypeRecommender(model, glossary, hardLimit))
 	router.HandleFunc("/support", setupSupportComputation(model))
 	router.HandleFunc("/propType", setupPropTypeRec(model))
+	router.HandleFunc("/validate", setupValidation(model))
+	router.HandleFunc("/cache", setupCacheStatistics(cache))
 	// router.HandleFunc("/wikiRecommender", wikiRecommender)
 	return router
 }
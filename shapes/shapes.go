@@ -0,0 +1,254 @@
+package shapes
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	rio "recommender/io"
+	"recommender/schematree"
+	"sort"
+	"strings"
+)
+
+const (
+	shNamespace  = "http://www.w3.org/ns/shacl#"
+	xsdNamespace = "http://www.w3.org/2001/XMLSchema#"
+)
+
+// Options control which shapes and constraints are generated.
+type Options struct {
+	MinSupport     uint64  // types with fewer subjects get no shape
+	MinProbability float64 // properties that a smaller share of the subjects of a type has are left out
+	Counts         bool    // add sh:minCount and sh:maxCount
+	Datatypes      bool    // add sh:datatype, sh:nodeKind and sh:class
+	MinShare       float64 // share of the subjects that have to satisfy a derived count or datatype constraint
+	Namespace      string  // namespace of the IRIs of the shapes
+}
+
+// DefaultOptions are the options of the generate-shapes command.
+var DefaultOptions = Options{
+	MinSupport:     100,
+	MinProbability: 0.1,
+	Counts:         true,
+	Datatypes:      true,
+	MinShare:       0.95,
+	Namespace:      "http://example.org/shapes/",
+}
+
+// NodeShape describes the subjects of one type.
+type NodeShape struct {
+	IRI         string
+	TargetClass string
+	Support     uint64 // number of subjects of the type
+	Properties  []PropertyShape
+}
+
+// PropertyShape describes a property of the subjects of a type. Zero values are constraints that are not set.
+type PropertyShape struct {
+	Path        string
+	Inverse     bool    // the path is the inverse of Path, i.e. an incoming relation
+	Probability float64 // share of the subjects of the type with the property
+	MinCount    uint32
+	MaxCount    uint32
+	Datatype    string
+	NodeKind    string // local name in the SHACL namespace, e.g. IRI or Literal
+	Class       string
+}
+
+// Generate derives a NodeShape for every type of a typed tree that has at least MinSupport subjects. The
+// shapes list the properties whose probability given the type reaches MinProbability. A property gets a
+// minimum count of one if at least MinShare of the subjects of the type have it. The maximum count and the
+// datatypes come from the cardinalities and object statistics of the property, which are kept for all
+// subjects instead of per type, so they are only set if at least MinShare of all its subjects and objects
+// agree on them.
+func Generate(tree *schematree.SchemaTree, options Options) []NodeShape {
+	typePredicates := make(map[string]bool)
+	for _, predicate := range tree.Config().TypePredicates {
+		typePredicates[predicate] = true
+	}
+
+	var shapes []NodeShape
+	names := make(map[string]int)
+	for _, item := range typesOf(tree) {
+		class, _ := item.TypeOf()
+		if !isIRI(class) {
+			continue // sh:targetClass needs an IRI
+		}
+		support := tree.Support(schematree.IList{item})
+		if support < options.MinSupport || support == 0 {
+			continue
+		}
+		shape := NodeShape{TargetClass: class, Support: support}
+
+		// make the local names of equally named classes of different vocabularies unique
+		name := localName(class) + "Shape"
+		names[name]++
+		if names[name] > 1 {
+			name += fmt.Sprint(names[name])
+		}
+		shape.IRI = options.Namespace + name
+
+		for _, rec := range tree.RecommendProperty(schematree.IList{item}) {
+			if rec.Probability < options.MinProbability {
+				break // the recommendations are sorted by probability
+			}
+			property := PropertyShape{
+				Path:        strings.TrimPrefix(*rec.Property.Str, "^"),
+				Inverse:     rec.Property.IsInverse(),
+				Probability: rec.Probability,
+			}
+			if !property.Inverse && typePredicates[property.Path] {
+				continue
+			}
+			if options.Counts {
+				if rec.Probability >= options.MinShare {
+					property.MinCount = 1
+				}
+				if c := tree.CardinalityOf(rec.Property); c != nil {
+					property.MaxCount = c.Bound(options.MinShare)
+				}
+			}
+			if options.Datatypes && !property.Inverse {
+				property.setObjectConstraints(tree.ObjectStatsOf(rec.Property), options.MinShare)
+			}
+			shape.Properties = append(shape.Properties, property)
+		}
+		shapes = append(shapes, shape)
+	}
+	return shapes
+}
+
+// typesOf lists the type items of the tree, the most frequent first.
+func typesOf(tree *schematree.SchemaTree) schematree.IList {
+	var types schematree.IList
+	for _, item := range tree.PropMap {
+		if item.IsType() {
+			types = append(types, item)
+		}
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].TotalCount != types[j].TotalCount {
+			return types[i].TotalCount > types[j].TotalCount
+		}
+		return *types[i].Str < *types[j].Str
+	})
+	return types
+}
+
+// setObjectConstraints sets the datatype, node kind and class that at least minShare of the objects have. Of
+// several datatypes or classes with enough objects, the most frequent one is taken. Blank node classes are left
+// out, sh:class needs an IRI.
+func (p *PropertyShape) setObjectConstraints(stats *schematree.ObjectStats, minShare float64) {
+	if stats == nil {
+		return
+	}
+	total := float64(stats.IRIs + stats.BlankNodes + stats.Literals)
+	if total == 0 {
+		return
+	}
+	switch {
+	case float64(stats.Literals) >= minShare*total:
+		datatype, n := mostFrequent(stats.Datatypes, func(string) bool { return true })
+		if n > 0 && float64(n) >= minShare*float64(stats.Literals) {
+			p.Datatype = datatype
+			return
+		}
+		p.NodeKind = "Literal"
+		return
+	case float64(stats.IRIs) >= minShare*total:
+		p.NodeKind = "IRI"
+	case float64(stats.BlankNodes) >= minShare*total:
+		p.NodeKind = "BlankNode"
+	case float64(stats.IRIs+stats.BlankNodes) >= minShare*total:
+		p.NodeKind = "BlankNodeOrIRI"
+	default:
+		return
+	}
+	class, n := mostFrequent(stats.ObjectTypes, isIRI)
+	if stats.Sampled > 0 && n > 0 && float64(n) >= minShare*float64(stats.Sampled) {
+		p.Class = class
+	}
+}
+
+// isIRI is false for blank node labels and literals.
+func isIRI(term string) bool {
+	return !strings.HasPrefix(term, "_:") && !strings.HasPrefix(term, `"`)
+}
+
+// mostFrequent returns the accepted key with the highest count, ties are broken by the smaller key.
+func mostFrequent(counts map[string]uint64, accept func(string) bool) (best string, count uint64) {
+	for key, n := range counts {
+		if accept(key) && (count == 0 || n > count || n == count && key < best) {
+			best, count = key, n
+		}
+	}
+	return
+}
+
+// localName returns the part of an IRI after the last '/' or '#', reduced to characters that are valid in IRIs.
+func localName(iri string) string {
+	name := iri[strings.LastIndexAny(iri, "/#")+1:]
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return -1
+	}, name)
+	if name == "" {
+		return "Type"
+	}
+	return name
+}
+
+// WriteTurtle writes the shapes as a Turtle document.
+func WriteTurtle(w io.Writer, shapes []NodeShape) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "@prefix sh: <%v> .\n", shNamespace)
+	fmt.Fprintf(b, "@prefix xsd: <%v> .\n", xsdNamespace)
+
+	for _, shape := range shapes {
+		fmt.Fprintf(b, "\n# %v subjects\n", shape.Support)
+		fmt.Fprintf(b, "%v\n", iriRef(shape.IRI))
+		fmt.Fprintf(b, "\ta sh:NodeShape ;\n")
+		fmt.Fprintf(b, "\tsh:targetClass %v", iriRef(shape.TargetClass))
+		for _, p := range shape.Properties {
+			fmt.Fprintf(b, " ;\n\tsh:property [ # probability %.4f\n", p.Probability)
+			if p.Inverse {
+				fmt.Fprintf(b, "\t\tsh:path [ sh:inversePath %v ] ;\n", iriRef(p.Path))
+			} else {
+				fmt.Fprintf(b, "\t\tsh:path %v ;\n", iriRef(p.Path))
+			}
+			if p.MinCount > 0 {
+				fmt.Fprintf(b, "\t\tsh:minCount %v ;\n", p.MinCount)
+			}
+			if p.MaxCount > 0 {
+				fmt.Fprintf(b, "\t\tsh:maxCount %v ;\n", p.MaxCount)
+			}
+			if p.Datatype != "" {
+				fmt.Fprintf(b, "\t\tsh:datatype %v ;\n", turtleIRI(p.Datatype))
+			}
+			if p.NodeKind != "" {
+				fmt.Fprintf(b, "\t\tsh:nodeKind sh:%v ;\n", p.NodeKind)
+			}
+			if p.Class != "" && isIRI(p.Class) {
+				fmt.Fprintf(b, "\t\tsh:class %v ;\n", iriRef(p.Class))
+			}
+			fmt.Fprintf(b, "\t]")
+		}
+		fmt.Fprintf(b, " .\n")
+	}
+	return b.Flush()
+}
+
+// turtleIRI abbreviates IRIs in the XML Schema namespace.
+func turtleIRI(iri string) string {
+	if local := strings.TrimPrefix(iri, xsdNamespace); local != iri && localName(local) == local {
+		return "xsd:" + local
+	}
+	return iriRef(iri)
+}
+
+// iriRef writes an IRI in angle brackets, escaping the characters that are not allowed in an IRIREF.
+func iriRef(iri string) string {
+	return string(rio.NewIRI(iri).Raw)
+}
@@ -0,0 +1,163 @@
+package shapes
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	rio "recommender/io"
+	"recommender/schematree"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	lines := []string{
+		`<http://ex.org/Germany> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/Country> .`,
+		`<http://ex.org/Germany> <http://ex.org/name> "Germany" .`,
+	}
+	lines = append(lines,
+		`<http://ex.org/a> <http://ex.org/mayor> <http://ex.org/x> .`,
+		`<http://ex.org/a> <http://ex.org/population> "5"^^<http://www.w3.org/2001/XMLSchema#integer> .`,
+		`<http://ex.org/a> <http://ex.org/population> "6"^^<http://www.w3.org/2001/XMLSchema#integer> .`,
+	)
+	for _, city := range []string{"a", "b", "c", "d"} {
+		lines = append(lines,
+			`<http://ex.org/`+city+`> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/City> .`,
+			`<http://ex.org/`+city+`> <http://ex.org/name> "`+city+`" .`,
+			`<http://ex.org/`+city+`> <http://ex.org/country> <http://ex.org/Germany> .`,
+		)
+	}
+	dataset := filepath.Join(t.TempDir(), "dataset.nt")
+	assert.NoError(t, os.WriteFile(dataset, []byte(strings.Join(lines, "\n")), 0644))
+
+	tree := schematree.New(true, 1)
+	tree.TwoPass(dataset, 0)
+
+	options := DefaultOptions
+	options.MinSupport = 2
+	options.MinProbability = 0.25
+	shapes := Generate(tree, options)
+
+	t.Run("node shapes", func(t *testing.T) {
+		if !assert.Len(t, shapes, 1) {
+			return
+		}
+		city := shapes[0]
+		assert.Equal(t, "http://example.org/shapes/CityShape", city.IRI)
+		assert.Equal(t, "http://ex.org/City", city.TargetClass)
+		assert.EqualValues(t, 4, city.Support)
+		assert.Equal(t, []PropertyShape{
+			{Path: "http://ex.org/country", Probability: 1, MinCount: 1, MaxCount: 1, NodeKind: "IRI", Class: "http://ex.org/Country"},
+			{Path: "http://ex.org/name", Probability: 1, MinCount: 1, MaxCount: 1, Datatype: "http://www.w3.org/2001/XMLSchema#string"},
+			{Path: "http://ex.org/mayor", Probability: 0.25, MaxCount: 1, NodeKind: "IRI"},
+			{Path: "http://ex.org/population", Probability: 0.25, MaxCount: 2, Datatype: "http://www.w3.org/2001/XMLSchema#integer"},
+		}, sortedByPath(city.Properties))
+	})
+
+	t.Run("without constraints", func(t *testing.T) {
+		options := options
+		options.Counts = false
+		options.Datatypes = false
+		options.MinProbability = 0.5
+		shapes := Generate(tree, options)
+		assert.Equal(t, []PropertyShape{
+			{Path: "http://ex.org/country", Probability: 1},
+			{Path: "http://ex.org/name", Probability: 1},
+		}, sortedByPath(shapes[0].Properties))
+	})
+
+	t.Run("object constraints", func(t *testing.T) {
+		constraints := func(stats schematree.ObjectStats, minShare float64) PropertyShape {
+			p := PropertyShape{}
+			p.setObjectConstraints(&stats, minShare)
+			return p
+		}
+
+		// the most frequent datatype wins, ties go to the smaller IRI
+		literals := schematree.ObjectStats{Literals: 10, Datatypes: map[string]uint64{"b": 4, "c": 4, "a": 2}}
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, "b", constraints(literals, 0.2).Datatype)
+		}
+		assert.Equal(t, PropertyShape{NodeKind: "Literal"}, constraints(literals, 0.5))
+
+		// blank node classes are no IRIs for sh:class
+		iris := schematree.ObjectStats{IRIs: 10, Sampled: 10, ObjectTypes: map[string]uint64{"_:x": 8, "http://ex.org/C": 3}}
+		assert.Equal(t, PropertyShape{NodeKind: "IRI", Class: "http://ex.org/C"}, constraints(iris, 0.3))
+		assert.Equal(t, PropertyShape{NodeKind: "IRI"}, constraints(iris, 0.5))
+	})
+
+	t.Run("turtle", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.NoError(t, WriteTurtle(&out, shapes))
+		assert.Contains(t, out.String(), "sh:datatype xsd:string ;")
+		assert.Contains(t, out.String(), "sh:nodeKind sh:IRI ;")
+
+		parser := rio.NewTurtleParserFromReader(ioutil.NopCloser(&out))
+		parser.Mode = rio.Strict
+		defer parser.Close()
+		count := 0
+		for {
+			trip, err := parser.NextTriple()
+			if !assert.NoError(t, err) || trip == nil {
+				break
+			}
+			count++
+		}
+		// 2 triples of the node shape, 4 sh:property triples and 15 triples of the property shapes
+		assert.Equal(t, 21, count)
+	})
+
+	t.Run("types without IRI", func(t *testing.T) {
+		lines := []string{}
+		for _, s := range []string{"a", "b"} {
+			lines = append(lines,
+				`<http://ex.org/`+s+`> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> "Literal type" .`,
+				`<http://ex.org/`+s+`> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> _:class .`,
+				`<http://ex.org/`+s+`> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://ex.org/A\u0020B> .`,
+				`<http://ex.org/`+s+`> <http://ex.org/p\u007Cq> "1" .`,
+			)
+		}
+		dataset := filepath.Join(t.TempDir(), "dataset.nt")
+		assert.NoError(t, os.WriteFile(dataset, []byte(strings.Join(lines, "\n")), 0644))
+		tree := schematree.New(true, 1)
+		tree.TwoPass(dataset, 0)
+
+		shapes := Generate(tree, options)
+		if !assert.Len(t, shapes, 1) {
+			return
+		}
+		assert.Equal(t, "http://ex.org/A B", shapes[0].TargetClass)
+
+		var out bytes.Buffer
+		assert.NoError(t, WriteTurtle(&out, shapes))
+		assert.Contains(t, out.String(), `sh:targetClass <http://ex.org/A\u0020B>`)
+		assert.Contains(t, out.String(), `sh:path <http://ex.org/p\u007Cq>`)
+
+		parser := rio.NewTurtleParserFromReader(ioutil.NopCloser(&out))
+		parser.Mode = rio.Strict
+		defer parser.Close()
+		for {
+			trip, err := parser.NextTriple()
+			if !assert.NoError(t, err) || trip == nil {
+				break
+			}
+		}
+	})
+}
+
+// sortedByPath orders properties of equal probability by their path.
+func sortedByPath(properties []PropertyShape) []PropertyShape {
+	for i := 1; i < len(properties); i++ {
+		for j := i; j > 0; j-- {
+			a, b := properties[j-1], properties[j]
+			if a.Probability > b.Probability || a.Probability == b.Probability && a.Path < b.Path {
+				break
+			}
+			properties[j-1], properties[j] = b, a
+		}
+	}
+	return properties
+}
@@ -0,0 +1,158 @@
+package strategy
+
+import (
+	"container/list"
+	"encoding/binary"
+	"recommender/assessment"
+	"recommender/internal/parallel"
+	"recommender/schematree"
+	"sort"
+	"sync"
+)
+
+// Cache is a bounded LRU cache of workflow recommendations that is shared by all requests, so that identical
+// queries from different users are computed once. Entries are keyed by the workflow, the sorted input items and
+// the limit of the assessment. The cache belongs to a model: Reload drops all entries, there is no expiry.
+// Cached recommendations are shared and must not be modified.
+type Cache struct {
+	lock     sync.Mutex
+	model    *schematree.SchemaTree
+	capacity int
+	entries  map[cacheKey]*list.Element
+	order    *list.List // of *cacheEntry, the most recently used first
+	stats    CacheStats
+}
+
+type cacheKey struct {
+	workflow *Workflow
+	items    string // sort orders of the sorted and deduplicated input items
+	limit    int
+}
+
+type cacheEntry struct {
+	key  cacheKey
+	recs schematree.PropertyRecommendations
+}
+
+// CacheStats are the metrics of a Cache.
+type CacheStats struct {
+	Capacity      int     `json:"capacity"`
+	Entries       int     `json:"entries"`
+	Hits          uint64  `json:"hits"`
+	Misses        uint64  `json:"misses"`
+	Evictions     uint64  `json:"evictions"`
+	Invalidations uint64  `json:"invalidations"` // number of reloads of the model
+	HitRate       float64 `json:"hitRate"`
+}
+
+// NewCache creates a cache for recommendations of the model that holds up to capacity entries.
+func NewCache(model *schematree.SchemaTree, capacity int) *Cache {
+	return &Cache{
+		model:    model,
+		capacity: capacity,
+		entries:  make(map[cacheKey]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+// Recommend returns the recommendations of the workflow for the assessment from the cache, or runs the workflow
+// and caches its result. Concurrent misses of the same query may both run the workflow. A nil cache always runs
+// the workflow.
+func (c *Cache) Recommend(wf *Workflow, asm *assessment.Instance) schematree.PropertyRecommendations {
+	if c == nil {
+		return wf.Recommend(asm)
+	}
+	key := cacheKey{wf, canonicalItems(asm.Props), asm.Limit}
+	c.lock.Lock()
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		c.stats.Hits++
+		c.lock.Unlock()
+		return element.Value.(*cacheEntry).recs
+	}
+	c.stats.Misses++
+	model := c.model
+	c.lock.Unlock()
+
+	recs := wf.Recommend(asm)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.model != model || c.capacity <= 0 {
+		return recs // the model has been reloaded in the meantime
+	}
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		return recs
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key, recs})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.order.Remove(oldest)
+		c.stats.Evictions++
+	}
+	return recs
+}
+
+// Reload drops all entries, as they were computed with the previous model. Workflows have to be rebuilt for the
+// new model, so entries of old workflows would never be hit again, but they would keep the previous model
+// reachable until they are evicted. Results of the previous model that are computed while the cache is
+// reloaded are not cached. A nil cache ignores reloads.
+func (c *Cache) Reload(model *schematree.SchemaTree) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.model = model
+	c.entries = make(map[cacheKey]*list.Element, c.capacity)
+	c.order.Init()
+	c.stats.Invalidations++
+}
+
+// Stats returns the current metrics of the cache, all zero for a nil cache.
+func (c *Cache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stats := c.stats
+	stats.Capacity = c.capacity
+	stats.Entries = c.order.Len()
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+// canonicalItems encodes the sort orders of the items in ascending order without duplicates.
+func canonicalItems(items schematree.IList) string {
+	orders := make([]uint32, len(items))
+	for i, item := range items {
+		orders[i] = item.SortOrder
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i] < orders[j] })
+	b := make([]byte, 4*len(orders))
+	n := 0
+	for i, order := range orders {
+		if i == 0 || order != orders[i-1] {
+			binary.LittleEndian.PutUint32(b[n:], order)
+			n += 4
+		}
+	}
+	return string(b[:n])
+}
+
+// RecommendBatch runs the workflow for each of the assessments through the cache, concurrently on up to workers
+// goroutines, and calls emit with the recommendations in the order of the assessments.
+func (c *Cache) RecommendBatch(wf *Workflow, asms []*assessment.Instance, workers int, emit func(i int, recs schematree.PropertyRecommendations)) {
+	results := make([]schematree.PropertyRecommendations, len(asms))
+	parallel.InOrder(len(asms), workers, func(i int) {
+		results[i] = c.Recommend(wf, asms[i])
+	}, func(i int) {
+		emit(i, results[i])
+		results[i] = nil
+	})
+}
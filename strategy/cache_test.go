@@ -0,0 +1,89 @@
+package strategy
+
+import (
+	"recommender/assessment"
+	"recommender/schematree"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	schema, err := schematree.Load(treePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	p31 := schema.PropMap["http://www.wikidata.org/prop/direct/P31"]
+	p21 := schema.PropMap["http://www.wikidata.org/prop/direct/P21"]
+	p17 := schema.PropMap["http://www.wikidata.org/prop/direct/P17"]
+
+	runs := 0
+	wf := &Workflow{}
+	wf.Push(MakeAlwaysCondition(), func(asm *assessment.Instance) schematree.PropertyRecommendations {
+		runs++
+		return asm.CalcTopRecommendations(asm.Limit)
+	}, "counting")
+	asm := func(limit int, items ...*schematree.IItem) *assessment.Instance {
+		a := assessment.NewInstance(items, schema, true)
+		a.Limit = limit
+		return a
+	}
+
+	t.Run("hits", func(t *testing.T) {
+		cache := NewCache(schema, 2)
+		recs := cache.Recommend(wf, asm(10, p31, p21))
+		assert.Len(t, recs, 10)
+		assert.Equal(t, recs, cache.Recommend(wf, asm(10, p21, p31, p21))) // order and duplicates do not matter
+		assert.Equal(t, 1, runs)
+		cache.Recommend(wf, asm(20, p31, p21)) // other limit
+		assert.Equal(t, 2, runs)
+		other := &Workflow{}
+		other.Push(MakeAlwaysCondition(), MakeAssessmentAwareDirectProcedure(), "other")
+		cache.Recommend(other, asm(10, p31, p21)) // other workflow
+		assert.Equal(t, CacheStats{Capacity: 2, Entries: 2, Hits: 1, Misses: 3, Evictions: 1, HitRate: 0.25}, cache.Stats())
+
+		// the first query has been evicted, the second is still there
+		cache.Recommend(wf, asm(20, p31, p21))
+		assert.Equal(t, 2, runs)
+		cache.Recommend(wf, asm(10, p31, p21))
+		assert.Equal(t, 3, runs)
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		cache := NewCache(schema, 10)
+		runs = 0
+		cache.Recommend(wf, asm(0, p17))
+		cache.Reload(schema)
+		cache.Recommend(wf, asm(0, p17))
+		assert.Equal(t, 2, runs)
+		stats := cache.Stats()
+		assert.EqualValues(t, 1, stats.Invalidations)
+		assert.Equal(t, 1, stats.Entries)
+	})
+
+	t.Run("nil cache", func(t *testing.T) {
+		var cache *Cache
+		runs = 0
+		cache.Recommend(wf, asm(0, p17))
+		cache.Recommend(wf, asm(0, p17))
+		assert.Equal(t, 2, runs)
+		assert.Equal(t, CacheStats{}, cache.Stats())
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		cache := NewCache(schema, 10)
+		direct := MakePresetWorkflow("direct", schema)
+		asms := []*assessment.Instance{asm(5, p17), asm(5, p31), asm(5, p17)}
+		var emitted []int
+		cache.RecommendBatch(direct, asms, 2, func(i int, recs schematree.PropertyRecommendations) {
+			emitted = append(emitted, i)
+			assert.Len(t, recs, 5)
+		})
+		assert.Equal(t, []int{0, 1, 2}, emitted)
+		assert.EqualValues(t, 3, cache.Stats().Hits+cache.Stats().Misses)
+
+		emitted = nil
+		direct.RecommendBatch(asms, 0, func(i int, recs schematree.PropertyRecommendations) { emitted = append(emitted, i) })
+		assert.Equal(t, []int{0, 1, 2}, emitted)
+	})
+}
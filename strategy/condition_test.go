@@ -33,6 +33,21 @@ func TestConditions(t *testing.T) {
 		t.Errorf("'TooManyRecommendationsCondition' failed.")
 	}
 
+	// the limit of an assessment only applies to the final procedure, conditions still see enough recommendations
+	limited1 := assessment.NewInstance(schematree.IList{item1}, schema, true)
+	limited1.Limit = 10
+	limited2 := assessment.NewInstance(schematree.IList{item2}, schema, true)
+	limited2.Limit = 10
+	if countTooLessProperties(limited1) || !countTooManyProperties(limited1) || countTooManyProperties(limited2) {
+		t.Errorf("Conditions depend on the limit of the assessment.")
+	}
+	if recs := MakeAssessmentAwareDirectProcedure()(limited1); len(recs) != 10 {
+		t.Errorf("The direct procedure returned %v instead of 10 recommendations.", len(recs))
+	}
+	if recs := limited1.CalcRecommendations(); len(recs) != len(asm1.CalcRecommendations()) {
+		t.Errorf("All recommendations have to be computed for a limited assessment.")
+	}
+
 	aboveThreshholdCondition := MakeAboveThresholdCondition(1)
 	if aboveThreshholdCondition(asm1) || !aboveThreshholdCondition(asm21) {
 		t.Errorf("'aboveThreshholdCondition' failed.")
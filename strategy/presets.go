@@ -34,7 +34,7 @@ func MakeBelowThresholdCondition(threshold int) Condition {
 // Helper Method to create too-many-recommendations-condition: When the standard recommender returns more than count many recommendations the condition is true, else false
 func MakeTooManyRecommendationsCondition(threshold int) Condition {
 	return func(asm *assessment.Instance) bool {
-		recommendation := asm.CalcRecommendations()
+		recommendation := asm.CalcTopRecommendations(threshold + 1)
 		if len(recommendation) > threshold {
 			return true
 		}
@@ -45,7 +45,7 @@ func MakeTooManyRecommendationsCondition(threshold int) Condition {
 // Helper Method to create too-few-recommendations-condition: When the standard recommender returns less than count many recommendations the condition is true, else false
 func MakeTooFewRecommendationsCondition(threshold int) Condition {
 	return func(asm *assessment.Instance) bool {
-		recommendation := asm.CalcRecommendations()
+		recommendation := asm.CalcTopRecommendations(threshold)
 		if len(recommendation) < threshold {
 			return true
 		}
@@ -56,7 +56,7 @@ func MakeTooFewRecommendationsCondition(threshold int) Condition {
 // Helper Method to create too-unlikely-recommendations-condition: When the standard recommender returns a recommendation where the top 10 has lower probability than threshhold (in decimal percentage eg 0.5)
 func MakeTooUnlikelyRecommendationsCondition(threshold float32) Condition {
 	return func(asm *assessment.Instance) bool {
-		recommendation := asm.CalcRecommendations()
+		recommendation := asm.CalcTopRecommendations(10)
 		if recommendation.Top10AvgProbibility() < threshold {
 			return true
 		}
@@ -71,10 +71,17 @@ func MakeTooUnlikelyRecommendationsCondition(threshold float32) Condition {
 //	}
 //}
 
-// Helper method to create the direct SchemaTree procedure call.
+// Helper method to create the direct SchemaTree procedure call. Only the top Limit recommendations are computed.
 func MakeAssessmentAwareDirectProcedure() Procedure {
 	return func(asm *assessment.Instance) schematree.PropertyRecommendations {
-		return asm.CalcRecommendations()
+		return asm.CalcTopRecommendations(asm.Limit)
+	}
+}
+
+// Helper method to create the direct SchemaTree procedure call with smoothed probabilities of the estimator.
+func MakeEstimatorProcedure(tree *schematree.SchemaTree, estimator schematree.Estimator) Procedure {
+	return func(asm *assessment.Instance) schematree.PropertyRecommendations {
+		return tree.RecommendPropertyWith(asm.Props, estimator)
 	}
 }
 
@@ -51,3 +51,10 @@ func (wf *Workflow) Recommend(asm *assessment.Instance) schematree.PropertyRecom
 	//log.Printf("  Failed to select any entry of the strategy workflow.")
 	return nil
 }
+
+// RecommendBatch : Run the workflow for each of the assessments, concurrently on up to workers goroutines (one
+// per CPU if workers is not positive), and call emit with the recommendations in the order of the assessments.
+func (wf *Workflow) RecommendBatch(asms []*assessment.Instance, workers int, emit func(i int, recs schematree.PropertyRecommendations)) {
+	var noCache *Cache
+	noCache.RecommendBatch(wf, asms, workers, emit)
+}